@@ -0,0 +1,18 @@
+package client
+
+import (
+	"fmt"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// Activity returns a page of the account's audit log, newest first. Pages
+// start at 1.
+func (cc *Client) Activity(page int) ([]*charm.AuditEvent, error) {
+	var es []*charm.AuditEvent
+	err := cc.AuthedJSONRequest("GET", fmt.Sprintf("/v1/activity?page=%d", page), nil, &es)
+	if err != nil {
+		return nil, err
+	}
+	return es, nil
+}
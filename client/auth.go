@@ -29,6 +29,12 @@ func (cc *Client) Auth() (*charm.Auth, error) {
 		if err != nil {
 			return nil, charm.ErrAuthFailed{Err: err}
 		}
+		if auth.JWT == "" {
+			var msg charm.Message
+			if err := json.Unmarshal(b, &msg); err == nil && msg.Message != "" {
+				return nil, charm.ErrAuthFailed{Err: apiError(msg.Message)}
+			}
+		}
 		cc.httpScheme = auth.HTTPScheme
 		p := &jwt.Parser{}
 		token, _, err := p.ParseUnverified(auth.JWT, &jwt.RegisteredClaims{})
@@ -221,6 +221,68 @@ func (cc *Client) UnlinkAuthorizedKey(key string) error {
 	return nil
 }
 
+// DeleteAccount permanently deletes the user's Charm account, including all
+// linked keys, encrypt keys and stored files.
+func (cc *Client) DeleteAccount() error {
+	s, err := cc.sshSession()
+	if err != nil {
+		return err
+	}
+	defer s.Close() // nolint:errcheck
+	b, err := s.Output("api-delete-account")
+	if err != nil {
+		return err
+	}
+	if len(b) != 0 {
+		var m charm.Message
+		if err := json.Unmarshal(b, &m); err == nil && m.Message != "" {
+			return fmt.Errorf("%w: %s", charm.ErrCouldNotDeleteAccount, m.Message)
+		}
+		return charm.ErrCouldNotDeleteAccount
+	}
+	cc.InvalidateAuth()
+	return nil
+}
+
+// RedeemInvite creates an account for the client's key with an invite code
+// on a server with invite-only registration.
+func (cc *Client) RedeemInvite(code string) error {
+	s, err := cc.sshSession()
+	if err != nil {
+		return err
+	}
+	defer s.Close() // nolint:errcheck
+	b, err := s.Output("api-invite " + code)
+	if err != nil {
+		return err
+	}
+	if len(b) != 0 {
+		var m charm.Message
+		if err := json.Unmarshal(b, &m); err == nil && m.Message != "" {
+			return apiError(m.Message)
+		}
+		return fmt.Errorf("unexpected response: %s", b)
+	}
+	cc.InvalidateAuth()
+	return nil
+}
+
+// apiError returns the proto error for an error message sent by the server,
+// so callers can match it with errors.Is.
+func apiError(msg string) error {
+	for _, err := range []error{
+		charm.ErrAccountSuspended,
+		charm.ErrRegistrationClosed,
+		charm.ErrInvalidInvite,
+		charm.ErrUserExists,
+	} {
+		if msg == err.Error() {
+			return err
+		}
+	}
+	return errors.New(msg)
+}
+
 // KeygenType returns the keygen key type.
 func (cfg *Config) KeygenType() keygen.KeyType {
 	kt := strings.ToLower(cfg.KeyType)
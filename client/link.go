@@ -167,7 +167,7 @@ func checkLinkStatus(lh charm.LinkHandler, l *charm.Link) bool {
 		lh.TokenSent(l)
 	case charm.LinkStatusValidTokenRequest:
 		lh.ValidToken(l)
-	case charm.LinkStatusInvalidTokenRequest:
+	case charm.LinkStatusInvalidTokenRequest, charm.LinkStatusTooManyAttempts:
 		lh.InvalidToken(l)
 		return false
 	case charm.LinkStatusRequestDenied:
@@ -0,0 +1,14 @@
+package client
+
+import (
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// Usage returns the account's storage usage and quota.
+func (cc *Client) Usage() (*charm.Usage, error) {
+	u := &charm.Usage{}
+	if err := cc.AuthedJSONRequest("GET", "/v1/usage", nil, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
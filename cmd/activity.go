@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var activityPage int
+
+// ActivityCmd is the cobra.Command to print the account's audit log.
+var ActivityCmd = &cobra.Command{
+	Use:   "activity",
+	Short: "Review your account activity",
+	Long:  paragraph("Print the security log for your account: when keys were " + keyword("linked") + ", " + keyword("unlinked") + " or " + keyword("merged") + " and when tokens were issued, along with the key and address responsible. If you don’t recognize a key, unlink it with " + code("charm") + "."),
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cc := initCharmClient()
+		es, err := cc.Activity(activityPage)
+		if err != nil {
+			return err
+		}
+		if len(es) == 0 {
+			fmt.Println("No activity.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, e := range es {
+			var t string
+			if e.CreatedAt != nil {
+				t = e.CreatedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t, e.Type, e.KeyFingerprint, e.RemoteAddr, e.Detail)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	ActivityCmd.Flags().IntVarP(&activityPage, "page", "p", 1, "page of results to show, newest first")
+}
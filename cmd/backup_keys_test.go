@@ -13,10 +13,10 @@ import (
 )
 
 func TestBackupKeysCmd(t *testing.T) {
-	backupFilePath := "./charm-keys-backup.tar"
-	_ = os.RemoveAll(backupFilePath)
+	backupFilePath := filepath.Join(t.TempDir(), "charm-keys-backup.tar")
 	_ = testserver.SetupTestServer(t)
 
+	BackupKeysCmd.SetArgs([]string{"-o", backupFilePath})
 	if err := BackupKeysCmd.Execute(); err != nil {
 		t.Fatalf("command failed: %s", err)
 	}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/charm/ui/common"
+	"github.com/charmbracelet/charm/ui/deleteaccount"
+	"github.com/spf13/cobra"
+)
+
+var forceDeleteAccount bool
+
+// DeleteAccountCmd is the cobra.Command to permanently delete a user's Charm
+// account.
+var DeleteAccountCmd = &cobra.Command{
+	Use:   "delete-account",
+	Short: "Permanently delete your Charm account",
+	Long:  paragraph(fmt.Sprintf("%s your Charm account, including your linked keys, encryption keys and all of your files. This can’t be undone. Your local keys are kept, and using them again creates a new, empty account.", keyword("Permanently delete"))),
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !forceDeleteAccount {
+			if !common.IsTTY() {
+				return fmt.Errorf("not deleting your account without confirmation; to force, use -f")
+			}
+			cfg := getCharmConfig()
+			if cfg.Logfile != "" {
+				f, err := tea.LogToFile(cfg.Logfile, "charm")
+				if err != nil {
+					return err
+				}
+				defer f.Close() // nolint:errcheck
+			}
+			_, err := deleteaccount.NewProgram(cfg).Run()
+			return err
+		}
+
+		cc := initCharmClient()
+		if err := cc.DeleteAccount(); err != nil {
+			return err
+		}
+		fmt.Println("Your account has been deleted.")
+		return nil
+	},
+}
+
+func init() {
+	DeleteAccountCmd.Flags().BoolVarP(&forceDeleteAccount, "force", "f", false, "delete without asking for confirmation")
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -8,10 +10,14 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
 	cfs "github.com/charmbracelet/charm/fs"
 	charm "github.com/charmbracelet/charm/proto"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
@@ -32,7 +38,9 @@ type localRemoteFS struct {
 }
 
 var (
-	isRecursive bool
+	isRecursive     bool
+	copyConcurrency int
+	restoreVersion  string
 
 	// FSCmd is the cobra.Command to use the Charm file system.
 	FSCmd = &cobra.Command{
@@ -82,6 +90,22 @@ var (
 		RunE:   fsList,
 	}
 
+	fsVersionsCmd = &cobra.Command{
+		Use:    "versions [charm:]PATH",
+		Hidden: false,
+		Short:  "List the previous versions of the file at path.",
+		Args:   cobra.ExactArgs(1),
+		RunE:   fsVersions,
+	}
+
+	fsRestoreCmd = &cobra.Command{
+		Use:    "restore [charm:]PATH",
+		Hidden: false,
+		Short:  "Restore a previous version of the file at path, the most recent one by default.",
+		Args:   cobra.ExactArgs(1),
+		RunE:   fsRestore,
+	}
+
 	fsTreeCmd = &cobra.Command{
 		Use:    "tree [charm:]PATH",
 		Hidden: false,
@@ -148,43 +172,79 @@ func (lrfs *localRemoteFS) ReadDir(name string) ([]fs.DirEntry, error) {
 	}
 }
 
+// walkDir walks a local or remote file tree. Remote trees are listed in one
+// go rather than a directory at a time.
+func (lrfs *localRemoteFS) walkDir(name string, fn fs.WalkDirFunc) error {
+	p := newLocalRemotePath(name)
+	if p.pathType == localPath {
+		return fs.WalkDir(lrfs, name, fn)
+	}
+	return lrfs.cfs.WalkDir(p.path, func(wp string, d fs.DirEntry, err error) error {
+		return fn("charm:"+wp, d, err)
+	})
+}
+
 func (lrfs *localRemoteFS) write(name string, src fs.File) error {
 	stat, err := src.Stat()
 	if err != nil {
 		return err
 	}
+	if stat.IsDir() {
+		return lrfs.mkdir(name, stat.Mode(), stat.ModTime())
+	}
 	p := newLocalRemotePath(name)
 	switch p.pathType {
 	case localPath:
-		dir := filepath.Dir(p.path)
-		if stat.IsDir() {
-			dir = dir + "/"
+		err = os.MkdirAll(filepath.Dir(p.path), charm.AddExecPermsForMkDir(stat.Mode()))
+		if err != nil {
+			return err
 		}
-		err = os.MkdirAll(dir, charm.AddExecPermsForMkDir(stat.Mode()))
+		f, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, stat.Mode().Perm())
 		if err != nil {
 			return err
 		}
-		if !stat.IsDir() {
-			f, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE, stat.Mode().Perm())
-			if err != nil {
-				return err
-			}
-			defer f.Close() // nolint:errcheck
-			_, err = io.Copy(f, src)
-			if err != nil {
-				return err
-			}
+		defer f.Close() // nolint:errcheck
+		_, err = io.Copy(f, src)
+		if err != nil {
+			return err
 		}
+		return os.Chtimes(p.path, stat.ModTime(), stat.ModTime())
 	case remotePath:
-		if !stat.IsDir() {
-			return lrfs.cfs.WriteFile(p.path, src)
+		return lrfs.cfs.WriteFile(p.path, src)
+	default:
+		return fmt.Errorf("invalid path type")
+	}
+}
+
+// mkdir creates the directory at name, along with any missing parents, and
+// sets its mode and modification time.
+func (lrfs *localRemoteFS) mkdir(name string, mode fs.FileMode, mtime time.Time) error {
+	p := newLocalRemotePath(name)
+	switch p.pathType {
+	case localPath:
+		if err := os.MkdirAll(p.path, charm.AddExecPermsForMkDir(mode)); err != nil {
+			return err
 		}
+		return os.Chtimes(p.path, mtime, mtime)
+	case remotePath:
+		return lrfs.cfs.MkdirModTime(p.path, mode, mtime)
 	default:
 		return fmt.Errorf("invalid path type")
 	}
-	return nil
 }
 
+// copyJob is a file to copy. If the file is already open it's copied from
+// file, otherwise it's opened from src.
+type copyJob struct {
+	src  string
+	dst  string
+	size int64
+	file fs.File
+}
+
+// copy copies a file, or a directory and everything in it if recursive is
+// set. Directories are created as they're found, then the files in them are
+// copied copyConcurrency at a time.
 func (lrfs *localRemoteFS) copy(srcName string, dstName string, recursive bool) error {
 	src, err := lrfs.Open(srcName)
 	if err != nil {
@@ -198,28 +258,165 @@ func (lrfs *localRemoteFS) copy(srcName string, dstName string, recursive bool)
 	if stat.IsDir() && !recursive {
 		return fmt.Errorf("recursive copy not specified, omitting directory '%s'", srcName)
 	}
-	if stat.IsDir() && recursive {
-		dp := newLocalRemotePath(dstName)
-		dstRoot := filepath.Clean(dstName) + dp.separator()
-		sp := newLocalRemotePath(srcName)
-		parents := len(strings.Split(filepath.Clean(sp.path), sp.separator())) - 1
-		return fs.WalkDir(lrfs, srcName, func(wps string, d fs.DirEntry, err error) error {
-			if err != nil {
-				fmt.Printf("error walking directory %s: %s", srcName, err)
-				return err
+	if !stat.IsDir() {
+		return lrfs.copyFiles([]copyJob{{src: srcName, dst: dstName, size: stat.Size(), file: src}})
+	}
+	dp := newLocalRemotePath(dstName)
+	dstRoot := filepath.Clean(dstName) + dp.separator()
+	sp := newLocalRemotePath(srcName)
+	parents := len(strings.Split(filepath.Clean(sp.path), sp.separator())) - 1
+	type dirTime struct {
+		path  string
+		mode  fs.FileMode
+		mtime time.Time
+	}
+	var dirs []dirTime
+	var jobs []copyJob
+	err = lrfs.walkDir(srcName, func(wps string, d fs.DirEntry, err error) error {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error walking directory %s: %s\n", srcName, err)
+			return err
+		}
+		wp := newLocalRemotePath(wps)
+		wpp := strings.Split(filepath.Clean(wp.path), wp.separator())
+		rp := path.Join(wpp[parents:]...)
+		dst := path.Join(dstRoot, rp)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			jobs = append(jobs, copyJob{src: wps, dst: dst, size: info.Size()})
+			return nil
+		}
+		dirs = append(dirs, dirTime{dst, info.Mode(), info.ModTime()})
+		return lrfs.mkdir(dst, info.Mode(), info.ModTime())
+	})
+	if err != nil {
+		return err
+	}
+	if err := lrfs.copyFiles(jobs); err != nil {
+		return err
+	}
+	// Writing files into a directory changes its modification time, so
+	// directories get theirs back last, deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := lrfs.mkdir(d.path, d.mode, d.mtime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFiles copies the files while showing the progress on stderr, so it
+// doesn't end up in the output of a command it's piped to. It's a progress
+// bar in a terminal and a status line every few seconds otherwise, where a
+// single file is copied without a word.
+func (lrfs *localRemoteFS) copyFiles(jobs []copyJob) error {
+	cp := newCopyProgress(jobs)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	if !isatty.IsTerminal(os.Stderr.Fd()) {
+		if len(jobs) == 1 {
+			return lrfs.transfer(ctx, jobs, cp)
+		}
+		go func() {
+			done <- lrfs.transfer(ctx, jobs, cp)
+		}()
+		t := time.NewTicker(copyStatusInterval)
+		defer t.Stop()
+		for {
+			select {
+			case err := <-done:
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(os.Stderr, cp.summary())
+				return nil
+			case <-t.C:
+				fmt.Fprintln(os.Stderr, cp.status())
 			}
-			wsrc, err := lrfs.Open(wps)
-			if err != nil {
-				return err
+		}
+	}
+	p := tea.NewProgram(newCopyModel(cp), tea.WithOutput(os.Stderr))
+	go func() {
+		done <- lrfs.transfer(ctx, jobs, cp)
+		p.Send(copyDoneMsg{})
+	}()
+	m, err := p.Run()
+	cancel()
+	terr := <-done
+	if err != nil {
+		return err
+	}
+	if m.(copyModel).interrupted {
+		return errors.New("copy interrupted")
+	}
+	return terr
+}
+
+// transfer copies the files with copyConcurrency workers. It stops at the
+// first file that fails to copy and returns its error.
+func (lrfs *localRemoteFS) transfer(ctx context.Context, jobs []copyJob, cp *copyProgress) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	n := copyConcurrency
+	if n < 1 {
+		n = 1
+	}
+	ch := make(chan copyJob)
+	errc := make(chan error, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				if err := lrfs.copyFile(j, cp); err != nil {
+					select {
+					case errc <- err:
+					default:
+					}
+					cancel()
+				}
 			}
-			defer wsrc.Close() // nolint:errcheck
-			wp := newLocalRemotePath(wps)
-			wpp := strings.Split(filepath.Clean(wp.path), wp.separator())
-			rp := path.Join(wpp[parents:]...)
-			return lrfs.write(path.Join(dstRoot, rp), wsrc)
-		})
-	}
-	return lrfs.write(dstName, src)
+		}()
+	}
+feed:
+	for _, j := range jobs {
+		select {
+		case ch <- j:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ch)
+	wg.Wait()
+	select {
+	case err := <-errc:
+		return err
+	default:
+	}
+	return ctx.Err()
+}
+
+func (lrfs *localRemoteFS) copyFile(j copyJob, cp *copyProgress) error {
+	src := j.file
+	if src == nil {
+		f, err := lrfs.Open(j.src)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint:errcheck
+		src = f
+	}
+	if err := lrfs.write(j.dst, newProgressFile(src, cp)); err != nil {
+		return err
+	}
+	cp.addFile()
+	return nil
 }
 
 func fsCat(_ *cobra.Command, args []string) error {
@@ -247,10 +444,35 @@ func fsCat(_ *cobra.Command, args []string) error {
 	return err
 }
 
+// rename moves a remote file or directory on the server. Like mv, moving onto
+// a directory moves into it and moving onto a file replaces it.
+func (lrfs *localRemoteFS) rename(srcName string, dstName string) error {
+	fi, err := fs.Stat(lrfs.cfs, dstName)
+	switch {
+	case err == nil && fi.IsDir():
+		dstName = path.Join(dstName, path.Base(srcName))
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	return lrfs.cfs.Replace(srcName, dstName)
+}
+
 func fsMove(cmd *cobra.Command, args []string) error {
+	src := newLocalRemotePath(args[0])
+	dst := newLocalRemotePath(args[1])
+	if src.pathType == remotePath && dst.pathType == remotePath {
+		lrfs, err := newLocalRemoteFS()
+		if err != nil {
+			return err
+		}
+		return lrfs.rename(src.path, dst.path)
+	}
 	if err := fsCopy(cmd, args); err != nil {
 		return err
 	}
+	if src.pathType == localPath {
+		return os.RemoveAll(src.path)
+	}
 	return fsRemove(cmd, args[:1])
 }
 
@@ -317,7 +539,7 @@ func fsTree(_ *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	err = fs.WalkDir(lsfs, args[0], func(path string, d fs.DirEntry, err error) error {
+	err = lsfs.WalkDir(args[0], func(path string, d fs.DirEntry, err error) error {
 		fmt.Println(path)
 		return nil
 	})
@@ -327,6 +549,50 @@ func fsTree(_ *cobra.Command, args []string) error {
 	return nil
 }
 
+func fsVersions(_ *cobra.Command, args []string) error {
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	vs, err := lsfs.Versions(args[0])
+	if err != nil {
+		return err
+	}
+	if len(vs) == 0 {
+		fmt.Printf("No previous versions of %s.\n", args[0])
+		return nil
+	}
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 1, 1, ' ', tabwriter.AlignRight)
+	for _, v := range vs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t\n", v.ID, v.Size, v.ModTime.Format("Jan _2 15:04"))
+	}
+	return w.Flush()
+}
+
+func fsRestore(_ *cobra.Command, args []string) error {
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	id := restoreVersion
+	if id == "" {
+		vs, err := lsfs.Versions(args[0])
+		if err != nil {
+			return err
+		}
+		if len(vs) == 0 {
+			return fmt.Errorf("no previous versions of %s", args[0])
+		}
+		id = vs[0].ID
+	}
+	if err := lsfs.Restore(args[0], id); err != nil {
+		return err
+	}
+	fmt.Printf("Restored version %s of %s.\n", id, args[0])
+	return nil
+}
+
 func printFileInfo(fi fs.FileInfo) {
 	fmt.Printf("%s %d %s %s\n", fi.Mode(), fi.Size(), fi.ModTime().Format("Jan 2 15:04"), fi.Name())
 }
@@ -354,7 +620,10 @@ func printDir(f fs.ReadDirFile) error {
 
 func init() {
 	fsCopyCmd.Flags().BoolVarP(&isRecursive, "recursive", "r", false, "copy directories recursively")
+	fsCopyCmd.Flags().IntVarP(&copyConcurrency, "concurrency", "j", 8, "number of files to copy at once")
 	fsMoveCmd.Flags().BoolVarP(&isRecursive, "recursive", "r", false, "move directories recursively")
+	fsMoveCmd.Flags().IntVarP(&copyConcurrency, "concurrency", "j", 8, "number of files to move at once")
+	fsRestoreCmd.Flags().StringVar(&restoreVersion, "version", "", "ID of the version to restore, as listed by versions")
 
 	FSCmd.AddCommand(fsCatCmd)
 	FSCmd.AddCommand(fsCopyCmd)
@@ -362,4 +631,10 @@ func init() {
 	FSCmd.AddCommand(fsMoveCmd)
 	FSCmd.AddCommand(fsListCmd)
 	FSCmd.AddCommand(fsTreeCmd)
+	FSCmd.AddCommand(fsVersionsCmd)
+	FSCmd.AddCommand(fsRestoreCmd)
+	FSCmd.AddCommand(fsTrashCmd)
+	FSCmd.AddCommand(fsSyncCmd)
+	FSCmd.AddCommand(fsWatchCmd)
+	FSCmd.AddCommand(fsServeCmd)
 }
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	copyTickInterval   = 100 * time.Millisecond
+	copyStatusInterval = 5 * time.Second
+)
+
+// copyProgress counts the files and bytes copied so far. The counts are
+// updated by the copy workers and read by whatever's showing the progress.
+type copyProgress struct {
+	doneBytes  int64
+	doneFiles  int64
+	totalBytes int64
+	totalFiles int64
+	start      time.Time
+}
+
+// progressFile is an fs.File that counts the bytes read from it.
+type progressFile struct {
+	fs.File
+	progress *copyProgress
+	pos      int64
+}
+
+// seekingProgressFile is a progressFile for a file that can seek. Writing a
+// file that can seek doesn't need a copy of it to know its size.
+type seekingProgressFile struct {
+	*progressFile
+}
+
+type copyTickMsg struct{}
+
+type copyDoneMsg struct{}
+
+// copyModel is the Bubble Tea model that shows the progress of a copy.
+type copyModel struct {
+	progress    *copyProgress
+	bar         progress.Model
+	done        bool
+	interrupted bool
+}
+
+func newCopyProgress(jobs []copyJob) *copyProgress {
+	cp := &copyProgress{totalFiles: int64(len(jobs)), start: time.Now()}
+	for _, j := range jobs {
+		cp.totalBytes += j.size
+	}
+	return cp
+}
+
+func (cp *copyProgress) addBytes(n int) {
+	atomic.AddInt64(&cp.doneBytes, int64(n))
+}
+
+func (cp *copyProgress) addFile() {
+	atomic.AddInt64(&cp.doneFiles, 1)
+}
+
+// fraction returns how much of the copy is done, going by bytes, or by files
+// when they're all empty.
+func (cp *copyProgress) fraction() float64 {
+	if cp.totalBytes > 0 {
+		return float64(atomic.LoadInt64(&cp.doneBytes)) / float64(cp.totalBytes)
+	}
+	if cp.totalFiles > 0 {
+		return float64(atomic.LoadInt64(&cp.doneFiles)) / float64(cp.totalFiles)
+	}
+	return 1
+}
+
+// rate returns the bytes copied per second so far.
+func (cp *copyProgress) rate() float64 {
+	elapsed := time.Since(cp.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&cp.doneBytes)) / elapsed
+}
+
+// status describes how far along the copy is, how fast it's going and how
+// long it has left.
+func (cp *copyProgress) status() string {
+	done := atomic.LoadInt64(&cp.doneBytes)
+	parts := []string{
+		fmt.Sprintf("%s of %s files", humanize.Comma(atomic.LoadInt64(&cp.doneFiles)), humanize.Comma(cp.totalFiles)),
+		fmt.Sprintf("%s of %s", humanize.Bytes(uint64(done)), humanize.Bytes(uint64(cp.totalBytes))),
+	}
+	if rate := cp.rate(); rate > 0 {
+		parts = append(parts, humanize.Bytes(uint64(rate))+"/s")
+		left := time.Duration(float64(cp.totalBytes-done) / rate * float64(time.Second))
+		if left > 0 {
+			parts = append(parts, "about "+left.Round(time.Second).String()+" left")
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// summary describes the finished copy.
+func (cp *copyProgress) summary() string {
+	n := atomic.LoadInt64(&cp.doneFiles)
+	files := "files"
+	if n == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("Copied %s %s (%s) in %s.",
+		humanize.Comma(n), files,
+		humanize.Bytes(uint64(atomic.LoadInt64(&cp.doneBytes))),
+		time.Since(cp.start).Round(time.Millisecond))
+}
+
+// newProgressFile returns f counting the bytes read from it toward cp. It
+// can seek if f can.
+func newProgressFile(f fs.File, cp *copyProgress) fs.File {
+	pf := &progressFile{File: f, progress: cp}
+	if _, ok := f.(io.Seeker); ok {
+		return seekingProgressFile{pf}
+	}
+	return pf
+}
+
+// Read reads from the file, counting the bytes read.
+func (pf *progressFile) Read(b []byte) (int, error) {
+	n, err := pf.File.Read(b)
+	pf.pos += int64(n)
+	pf.progress.addBytes(n)
+	return n, err
+}
+
+// Seek seeks in the file. Seeking back takes the bytes that will be read
+// again off the count.
+func (spf seekingProgressFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := spf.File.(io.Seeker).Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	spf.progress.addBytes(int(pos - spf.pos))
+	spf.pos = pos
+	return pos, nil
+}
+
+func newCopyModel(cp *copyProgress) copyModel {
+	return copyModel{
+		progress: cp,
+		bar:      progress.New(progress.WithDefaultGradient(), progress.WithWidth(56)),
+	}
+}
+
+func copyTick() tea.Cmd {
+	return tea.Tick(copyTickInterval, func(time.Time) tea.Msg {
+		return copyTickMsg{}
+	})
+}
+
+// Init starts refreshing the view.
+func (m copyModel) Init() tea.Cmd {
+	return copyTick()
+}
+
+// Update is the Bubble Tea update loop.
+func (m copyModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			m.interrupted = true
+			return m, tea.Quit
+		}
+	case copyTickMsg:
+		return m, copyTick()
+	case copyDoneMsg:
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// View renders the progress bar and the copy status.
+func (m copyModel) View() string {
+	status := subtle(m.progress.status())
+	if m.done {
+		status = keyword(m.progress.summary())
+	}
+	return "\n  " + m.bar.ViewAs(m.progress.fraction()) + "\n  " + status + "\n\n"
+}
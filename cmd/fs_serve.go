@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	csubtle "crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/webdav"
+
+	cfs "github.com/charmbracelet/charm/fs"
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// davUser is the user name to log in to the WebDAV server with.
+const davUser = "charm"
+
+var (
+	serveWebDAVAddr  string
+	serveAllowRemote bool
+
+	fsServeCmd = &cobra.Command{
+		Use:    "serve",
+		Hidden: false,
+		Short:  "Serve your decrypted files locally over WebDAV.",
+		Long:   paragraph("Serve your Charm file system over WebDAV, so editors, file managers and backup tools can use it. Files are decrypted and encrypted on this machine. Log in with the user name and password printed at startup. Only addresses on this machine can be served on, unless --allow-remote is set, as anyone who can reach the server and has the password can read and change your files."),
+		Args:   cobra.NoArgs,
+		RunE:   fsServe,
+	}
+)
+
+func fsServe(_ *cobra.Command, _ []string) error {
+	if serveWebDAVAddr == "" {
+		return errors.New("nothing to serve, pass an address with --webdav")
+	}
+	host, port, err := net.SplitHostPort(serveWebDAVAddr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	if !isLoopback(host) && !serveAllowRemote {
+		return fmt.Errorf("%s isn't an address on this machine, pass --allow-remote to serve your files on it anyway", host)
+	}
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	password := hex.EncodeToString(b)
+	s := &http.Server{
+		Addr:    net.JoinHostPort(host, port),
+		Handler: newWebDAVHandler(lsfs, password, host),
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.ListenAndServe()
+	}()
+	log.Info("Serving WebDAV", "addr", "http://"+s.Addr, "user", davUser, "password", password)
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.Shutdown(sctx)
+}
+
+// newWebDAVHandler returns the handler serving the file system over WebDAV to
+// requests logged in with davUser and password. Requests for any host other
+// than localhost or the one served on are refused, so a web page can't reach
+// the server through a DNS name of its own that resolves to this machine.
+func newWebDAVHandler(lsfs *cfs.FS, password string, host string) http.Handler {
+	dav := &webdav.Handler{
+		FileSystem: &davFS{cfs: lsfs},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			// Clients look for plenty of files that aren't there.
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Error("WebDAV request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+			}
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validDAVHost(r.Host, host) {
+			http.Error(w, "invalid host", http.StatusForbidden)
+			return
+		}
+		u, p, ok := r.BasicAuth()
+		if !ok || csubtle.ConstantTimeCompare([]byte(u), []byte(davUser)) != 1 || csubtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="charm", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		dav.ServeHTTP(w, r)
+	})
+}
+
+// validDAVHost reports whether a request for the Host header hh is for
+// localhost or the host served on. When serving on every address, any IP
+// address is fine too, only names have to match.
+func validDAVHost(hh string, host string) bool {
+	h, _, err := net.SplitHostPort(hh)
+	if err != nil {
+		h = strings.Trim(hh, "[]")
+	}
+	if strings.EqualFold(h, host) || isLoopback(h) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified() && net.ParseIP(h) != nil
+}
+
+// isLoopback reports whether host is localhost or a loopback IP address.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// davFS is a webdav.FileSystem backed by the Charm file system.
+type davFS struct {
+	cfs *cfs.FS
+}
+
+// davFile is a webdav.File for reading a file or listing a directory. Only
+// the fs.FileInfo is there to start with, the file is downloaded on the first
+// Read or Seek and the directory is listed on the first Readdir. Listing a
+// directory opens every file in it, which mustn't download them.
+type davFile struct {
+	cfs     *cfs.FS
+	name    string
+	info    fs.FileInfo
+	data    *bytes.Reader
+	entries []fs.DirEntry
+	listed  bool
+}
+
+// davUpload is a webdav.File that's being written. Writes go to a temporary
+// file, which is uploaded when it's closed.
+type davUpload struct {
+	cfs   *cfs.FS
+	name  string
+	tmp   *os.File
+	dirty bool
+}
+
+// davInfo is the fs.FileInfo for a file served over WebDAV. It tells the
+// content type from the file name, as otherwise listing a directory would
+// read every file in it to sniff them.
+type davInfo struct {
+	fs.FileInfo
+	name string
+}
+
+// Mkdir creates a directory. Like a WebDAV MKCOL, its parent has to exist.
+func (dfs *davFS) Mkdir(_ context.Context, name string, _ os.FileMode) error {
+	if _, err := dfs.stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if _, err := dfs.stat(path.Dir(name)); err != nil {
+		return err
+	}
+	// Directories are private on the server, whatever the client asks for.
+	return dfs.cfs.Mkdir(name, 0o700)
+}
+
+// OpenFile opens a file or directory. Files opened for writing are uploaded
+// when they're closed.
+func (dfs *davFS) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		return dfs.openUpload(name, flag)
+	}
+	fi, err := dfs.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return &davFile{cfs: dfs.cfs, name: name, info: fi}, nil
+}
+
+func (dfs *davFS) openUpload(name string, flag int) (webdav.File, error) {
+	fi, err := dfs.stat(name)
+	switch {
+	case err == nil && fi.IsDir():
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case errors.Is(err, fs.ErrNotExist) && flag&os.O_CREATE == 0:
+		return nil, err
+	case errors.Is(err, fs.ErrNotExist):
+		// Like a WebDAV PUT, the file's directory has to exist.
+		if _, err := dfs.stat(path.Dir(name)); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "charm-webdav-")
+	if err != nil {
+		return nil, err
+	}
+	du := &davUpload{cfs: dfs.cfs, name: name, tmp: tmp, dirty: fi == nil || flag&os.O_TRUNC != 0}
+	if fi != nil && flag&os.O_TRUNC == 0 {
+		// Keep the existing data, it's being changed rather than replaced.
+		b, err := dfs.cfs.ReadFile(name)
+		if err == nil {
+			_, err = tmp.Write(b)
+		}
+		if err == nil {
+			_, err = tmp.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			du.remove()
+			return nil, err
+		}
+	}
+	return du, nil
+}
+
+// RemoveAll deletes a file or a directory and everything in it.
+func (dfs *davFS) RemoveAll(_ context.Context, name string) error {
+	if name == "/" {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	return dfs.cfs.Remove(name)
+}
+
+// Rename moves a file or directory on the server.
+func (dfs *davFS) Rename(_ context.Context, oldName string, newName string) error {
+	return dfs.cfs.Rename(oldName, newName)
+}
+
+// Stat returns the fs.FileInfo for a file or directory.
+func (dfs *davFS) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	return dfs.stat(name)
+}
+
+// stat returns the fs.FileInfo for a file, without downloading it.
+func (dfs *davFS) stat(name string) (fs.FileInfo, error) {
+	if name == "/" || name == "" {
+		// The root is there even before anything's been stored.
+		return davInfo{
+			FileInfo: &cfs.FileInfo{FileInfo: charm.FileInfo{Name: "/", IsDir: true, Mode: fs.ModeDir | 0o700}},
+			name:     "/",
+		}, nil
+	}
+	fi, err := dfs.cfs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return davInfo{FileInfo: fi, name: path.Base(name)}, nil
+}
+
+// Read reads from the file.
+func (df *davFile) Read(p []byte) (int, error) {
+	if err := df.download("read"); err != nil {
+		return 0, err
+	}
+	return df.data.Read(p)
+}
+
+// Seek sets the offset for the next Read.
+func (df *davFile) Seek(offset int64, whence int) (int64, error) {
+	if err := df.download("seek"); err != nil {
+		return 0, err
+	}
+	return df.data.Seek(offset, whence)
+}
+
+// download downloads the file, unless it's been downloaded already.
+func (df *davFile) download(op string) error {
+	if df.info.IsDir() {
+		return &fs.PathError{Op: op, Path: df.name, Err: errors.New("is a directory")}
+	}
+	if df.data != nil {
+		return nil
+	}
+	b, err := df.cfs.ReadFile(df.name)
+	if err != nil {
+		return err
+	}
+	df.data = bytes.NewReader(b)
+	return nil
+}
+
+// Readdir returns the next count entries in the directory, or all of them if
+// count isn't positive.
+func (df *davFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !df.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: df.name, Err: errors.New("not a directory")}
+	}
+	if !df.listed {
+		des, err := df.cfs.ReadDir(df.name)
+		if err != nil {
+			return nil, err
+		}
+		df.entries = des
+		df.listed = true
+	}
+	if count > 0 && len(df.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := len(df.entries)
+	if count > 0 && count < n {
+		n = count
+	}
+	fis := make([]fs.FileInfo, 0, n)
+	for _, de := range df.entries[:n] {
+		fi, err := de.Info()
+		if err != nil {
+			return nil, err
+		}
+		fis = append(fis, davInfo{FileInfo: fi, name: de.Name()})
+	}
+	df.entries = df.entries[n:]
+	return fis, nil
+}
+
+// Stat returns the fs.FileInfo for the file.
+func (df *davFile) Stat() (fs.FileInfo, error) {
+	return df.info, nil
+}
+
+// Write fails, the file was opened for reading.
+func (df *davFile) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: df.info.Name(), Err: fs.ErrPermission}
+}
+
+// Close drops the downloaded data, if any.
+func (df *davFile) Close() error {
+	df.data = nil
+	df.entries = nil
+	return nil
+}
+
+// Read reads from the file being written.
+func (du *davUpload) Read(p []byte) (int, error) {
+	return du.tmp.Read(p)
+}
+
+// Seek sets the offset for the next Read or Write.
+func (du *davUpload) Seek(offset int64, whence int) (int64, error) {
+	return du.tmp.Seek(offset, whence)
+}
+
+// Write writes to the file, it's uploaded when it's closed.
+func (du *davUpload) Write(p []byte) (int, error) {
+	du.dirty = true
+	return du.tmp.Write(p)
+}
+
+// Readdir fails, a directory can't be written to.
+func (du *davUpload) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: du.name, Err: errors.New("not a directory")}
+}
+
+// Stat returns the fs.FileInfo for what's been written so far.
+func (du *davUpload) Stat() (fs.FileInfo, error) {
+	fi, err := du.tmp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return davInfo{FileInfo: fi, name: path.Base(du.name)}, nil
+}
+
+// Close uploads the file if it was changed.
+func (du *davUpload) Close() error {
+	defer du.remove()
+	if !du.dirty {
+		return nil
+	}
+	if _, err := du.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return du.cfs.WriteFile(du.name, du.tmp)
+}
+
+func (du *davUpload) remove() {
+	du.tmp.Close()           // nolint:errcheck
+	os.Remove(du.tmp.Name()) // nolint:errcheck
+}
+
+// Name returns the base name of the file.
+func (fi davInfo) Name() string {
+	return fi.name
+}
+
+// ContentType returns the content type for the file's extension.
+func (fi davInfo) ContentType(context.Context) (string, error) {
+	if ct := mime.TypeByExtension(path.Ext(fi.name)); ct != "" {
+		return ct, nil
+	}
+	return "application/octet-stream", nil
+}
+
+func init() {
+	fsServeCmd.Flags().StringVar(&serveWebDAVAddr, "webdav", "", "address to serve WebDAV on, such as :8080")
+	fsServeCmd.Flags().BoolVar(&serveAllowRemote, "allow-remote", false, "allow serving on an address other machines can reach")
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/charmbracelet/charm/client"
+	cfs "github.com/charmbracelet/charm/fs"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestWebDAV(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	lsfs, err := cfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(newWebDAVHandler(lsfs, "secret", "localhost"))
+	t.Cleanup(ts.Close)
+
+	do := func(method string, p string, body string, headers map[string]string, status int) string {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+p, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth(davUser, "secret")
+		for k, v := range headers {
+			switch k {
+			case "Host":
+				req.Host = v
+			case "Authorization":
+				req.Header.Del(k)
+				if v != "" {
+					req.SetBasicAuth(davUser, v)
+				}
+			default:
+				req.Header.Set(k, v)
+			}
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() // nolint:errcheck
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", method, p, status, resp.StatusCode, b)
+		}
+		return string(b)
+	}
+
+	do("PROPFIND", "/", "", map[string]string{"Depth": "0"}, http.StatusMultiStatus)
+	do("PROPFIND", "/", "", map[string]string{"Depth": "0", "Authorization": ""}, http.StatusUnauthorized)
+	do("PROPFIND", "/", "", map[string]string{"Depth": "0", "Authorization": "wrong"}, http.StatusUnauthorized)
+	do("PROPFIND", "/", "", map[string]string{"Depth": "0", "Host": "evil.example:8080"}, http.StatusForbidden)
+	do("PROPFIND", "/", "", map[string]string{"Depth": "0", "Host": "localhost:8080"}, http.StatusMultiStatus)
+	do("MKCOL", "/docs/notes", "", nil, http.StatusConflict)
+	do("MKCOL", "/docs", "", nil, http.StatusCreated)
+	do("MKCOL", "/docs", "", nil, http.StatusMethodNotAllowed)
+	do("PUT", "/missing/a.txt", "hello", nil, http.StatusConflict)
+	do("PUT", "/docs/a.txt", "hello", nil, http.StatusCreated)
+	if b := do("GET", "/docs/a.txt", "", nil, http.StatusOK); b != "hello" {
+		t.Fatalf("expected hello, got %q", b)
+	}
+	b := do("PROPFIND", "/docs", "", map[string]string{"Depth": "1"}, http.StatusMultiStatus)
+	if !strings.Contains(b, "/docs/a.txt") || !strings.Contains(b, "<D:getcontentlength>5</D:getcontentlength>") {
+		t.Fatalf("expected a.txt with a length of 5 in the listing, got %s", b)
+	}
+	if b, err := lsfs.ReadFile("docs/a.txt"); err != nil || string(b) != "hello" {
+		t.Fatalf("expected the file in the Charm FS, got %q (%v)", b, err)
+	}
+	if b := do("PROPFIND", "/", "", map[string]string{"Depth": "1"}, http.StatusMultiStatus); !strings.Contains(b, "/docs/") {
+		t.Fatalf("expected docs in the root listing, got %s", b)
+	}
+
+	do("MOVE", "/docs/a.txt", "", map[string]string{"Destination": ts.URL + "/docs/b.txt"}, http.StatusCreated)
+	do("GET", "/docs/a.txt", "", nil, http.StatusNotFound)
+	if b := do("GET", "/docs/b.txt", "", nil, http.StatusOK); b != "hello" {
+		t.Fatalf("expected hello, got %q", b)
+	}
+	do("DELETE", "/docs", "", nil, http.StatusNoContent)
+	do("GET", "/docs/b.txt", "", nil, http.StatusNotFound)
+	do("DELETE", "/", "", nil, http.StatusMethodNotAllowed)
+}
+
+func TestWebDAVListing(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	// Count the files downloaded through a proxy in front of the server.
+	target, err := url.Parse(fmt.Sprintf("http://localhost:%d", cl.Config.HTTPPort))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var downloads int64
+	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.ModifyResponse = func(resp *http.Response) error {
+		if resp.Request.Method == http.MethodGet && resp.Header.Get("Content-Type") == "application/octet-stream" {
+			atomic.AddInt64(&downloads, 1)
+		}
+		return nil
+	}
+	proxy := httptest.NewServer(rp)
+	t.Cleanup(proxy.Close)
+	pu, err := url.Parse(proxy.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(pu.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccfg := *cl.Config
+	ccfg.HTTPPort = port
+	pcl, err := client.NewClient(&ccfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lsfs, err := cfs.NewFSWithClient(pcl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"docs/a.txt", "docs/b.txt", "docs/sub/c.txt"} {
+		lp := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(lp, []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = lsfs.WriteFile(name, f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+
+	ts := httptest.NewServer(newWebDAVHandler(lsfs, "secret", "localhost"))
+	t.Cleanup(ts.Close)
+	req, err := http.NewRequest("PROPFIND", ts.URL+"/docs", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth(davUser, "secret")
+	req.Header.Set("Depth", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(string(b), "/docs/b.txt") || !strings.Contains(string(b), "/docs/sub/") {
+		t.Fatalf("expected the directory listing, got %d: %s", resp.StatusCode, b)
+	}
+	if n := atomic.LoadInt64(&downloads); n != 0 {
+		t.Fatalf("expected listing not to download any file, got %d downloads", n)
+	}
+}
+
+func TestWebDAVHost(t *testing.T) {
+	for _, tc := range []struct {
+		hh   string
+		host string
+		ok   bool
+	}{
+		{"localhost:8080", "localhost", true},
+		{"127.0.0.1:8080", "localhost", true},
+		{"[::1]:8080", "localhost", true},
+		{"LOCALHOST", "localhost", true},
+		{"evil.example:8080", "localhost", false},
+		{"192.168.1.2:8080", "localhost", false},
+		{"192.168.1.2:8080", "192.168.1.2", true},
+		{"192.168.1.3:8080", "192.168.1.2", false},
+		{"192.168.1.3:8080", "0.0.0.0", true},
+		{"nas.local:8080", "0.0.0.0", false},
+	} {
+		if ok := validDAVHost(tc.hh, tc.host); ok != tc.ok {
+			t.Errorf("expected %s serving on %s to be %t, got %t", tc.hh, tc.host, tc.ok, ok)
+		}
+	}
+
+	addr, allow := serveWebDAVAddr, serveAllowRemote
+	t.Cleanup(func() { serveWebDAVAddr, serveAllowRemote = addr, allow })
+	serveWebDAVAddr = "0.0.0.0:0"
+	serveAllowRemote = false
+	if err := fsServe(nil, nil); err == nil || !strings.Contains(err.Error(), "--allow-remote") {
+		t.Fatalf("expected serving on every address to be refused, got %v", err)
+	}
+}
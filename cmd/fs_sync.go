@@ -0,0 +1,591 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	cfs "github.com/charmbracelet/charm/fs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncDelete bool
+	syncDryRun bool
+	syncPrefer string
+
+	fsSyncCmd = &cobra.Command{
+		Use:    "sync LOCAL charm:REMOTE",
+		Hidden: false,
+		Short:  "Keep a local directory and a remote directory in sync.",
+		Long:   paragraph("Copy the files that changed since the last sync in either direction. A file changed on both sides is a conflict and is left alone unless --prefer says which side wins. Deleted files are copied back unless --delete is set."),
+		Args:   cobra.ExactArgs(2),
+		RunE:   fsSync,
+	}
+)
+
+type syncOpKind int
+
+const (
+	syncUpload syncOpKind = iota
+	syncDownload
+	syncDeleteLocal
+	syncDeleteRemote
+	syncCompare
+	syncConflict
+	syncForget
+)
+
+func (k syncOpKind) String() string {
+	switch k {
+	case syncUpload:
+		return "upload"
+	case syncDownload:
+		return "download"
+	case syncDeleteLocal:
+		return "delete local"
+	case syncDeleteRemote:
+		return "delete remote"
+	case syncCompare:
+		return "compare"
+	case syncConflict:
+		return "conflict"
+	default:
+		return "forget"
+	}
+}
+
+type syncOp struct {
+	kind syncOpKind
+	path string
+}
+
+// syncFile is the state of a file when it was last synced.
+type syncFile struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modtime"`
+	ETag    string    `json:"etag"`
+}
+
+// syncManifest records the files of a local and remote directory pair as of
+// the last sync, so changes on either side can be told apart.
+type syncManifest struct {
+	Local  string              `json:"local"`
+	Remote string              `json:"remote"`
+	Files  map[string]syncFile `json:"files"`
+	path   string
+}
+
+type fileSyncer struct {
+	cfs      *cfs.FS
+	local    string
+	remote   string
+	manifest *syncManifest
+	locals   map[string]fs.FileInfo
+	remotes  map[string]fs.FileInfo
+	prefer   string
+}
+
+func fsSync(_ *cobra.Command, args []string) error {
+	lp := newLocalRemotePath(args[0])
+	rp := newLocalRemotePath(args[1])
+	if lp.pathType != localPath || rp.pathType != remotePath {
+		return fmt.Errorf("expected a local path and a charm: path")
+	}
+	if syncPrefer != "" && syncPrefer != "local" && syncPrefer != "remote" {
+		return fmt.Errorf("invalid --prefer %q, expected local or remote", syncPrefer)
+	}
+	local, err := filepath.Abs(lp.path)
+	if err != nil {
+		return err
+	}
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	fsr := &fileSyncer{cfs: lsfs, local: local, remote: rp.path, prefer: syncPrefer}
+	if err := fsr.load(); err != nil {
+		return err
+	}
+	ops, err := fsr.plan()
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		fmt.Println("Everything is up to date.")
+		return nil
+	}
+	if syncDryRun {
+		for _, op := range ops {
+			if op.kind != syncForget {
+				fmt.Printf("%s %s\n", op.kind, op.path)
+			}
+		}
+		fmt.Println("Dry run, nothing was changed.")
+		return nil
+	}
+	conflicts := 0
+	var errs []error
+	for _, op := range ops {
+		kind, err := fsr.apply(op)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", op.path, err))
+			continue
+		}
+		if kind == syncConflict {
+			conflicts++
+		}
+		if kind != syncForget {
+			fmt.Printf("%s %s\n", kind, op.path)
+		}
+	}
+	if err := fsr.manifest.save(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return fmt.Errorf("%d files couldn't be synced", len(errs))
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d files changed on both sides, sync again with --prefer local or --prefer remote to resolve them", conflicts)
+	}
+	return nil
+}
+
+// load reads the manifest and lists the local and remote files.
+func (fsr *fileSyncer) load() error {
+	dp, err := fsr.cfs.Client().DataPath()
+	if err != nil {
+		return err
+	}
+	fsr.manifest, err = loadSyncManifest(dp, fsr.local, fsr.remote)
+	if err != nil {
+		return err
+	}
+	fsr.locals, err = localSyncFiles(fsr.local)
+	if err != nil {
+		return err
+	}
+	fsr.remotes, err = remoteSyncFiles(fsr.cfs, fsr.remote)
+	return err
+}
+
+// plan works out what needs to happen to every file to bring both sides in
+// sync, in path order.
+func (fsr *fileSyncer) plan() ([]syncOp, error) {
+	paths := make(map[string]bool)
+	for p := range fsr.locals {
+		paths[p] = true
+	}
+	for p := range fsr.remotes {
+		paths[p] = true
+	}
+	for p := range fsr.manifest.Files {
+		paths[p] = true
+	}
+	ops := make([]syncOp, 0)
+	for p := range paths {
+		kind, ok, err := fsr.planFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ops = append(ops, syncOp{kind: kind, path: p})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].path < ops[j].path
+	})
+	return ops, nil
+}
+
+func (fsr *fileSyncer) planFile(p string) (syncOpKind, bool, error) {
+	lfi, lok := fsr.locals[p]
+	rfi, rok := fsr.remotes[p]
+	last, synced := fsr.manifest.Files[p]
+	if !synced {
+		switch {
+		case lok && rok:
+			return syncCompare, true, nil
+		case lok:
+			return syncUpload, true, nil
+		default:
+			return syncDownload, true, nil
+		}
+	}
+	lchanged := false
+	if lok {
+		var err error
+		lchanged, err = fsr.localChanged(p, lfi, last)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+	rchanged := rok && remoteChanged(rfi, last)
+	switch {
+	case lok && rok:
+		switch {
+		case lchanged && rchanged:
+			return syncConflict, true, nil
+		case lchanged:
+			return syncUpload, true, nil
+		case rchanged:
+			return syncDownload, true, nil
+		}
+		return 0, false, nil
+	case lok:
+		if syncDelete && !lchanged {
+			return syncDeleteLocal, true, nil
+		}
+		return syncUpload, true, nil
+	case rok:
+		if syncDelete && !rchanged {
+			return syncDeleteRemote, true, nil
+		}
+		return syncDownload, true, nil
+	default:
+		return syncForget, true, nil
+	}
+}
+
+// localChanged reports whether the local file changed since the last sync. A
+// file that was only touched, with the same content, hasn't changed.
+func (fsr *fileSyncer) localChanged(p string, fi fs.FileInfo, last syncFile) (bool, error) {
+	if fi.Size() == last.Size && fi.ModTime().Equal(last.ModTime) {
+		return false, nil
+	}
+	if fi.Size() != last.Size {
+		return true, nil
+	}
+	h, err := hashFile(fsr.localPath(p))
+	if err != nil {
+		return false, err
+	}
+	return h != last.Hash, nil
+}
+
+// remoteChanged reports whether the remote file changed since the last sync,
+// going by its ETag, or by its size and time on servers that don't send one.
+func remoteChanged(fi fs.FileInfo, last syncFile) bool {
+	if etag := fileETag(fi); etag != "" || last.ETag != "" {
+		return etag != last.ETag
+	}
+	return fi.Size() != last.Size || !fi.ModTime().Equal(last.ModTime)
+}
+
+// apply carries out the operation and records the result in the manifest. It
+// returns what was actually done, which is a conflict if the remote file
+// changed in the meantime.
+func (fsr *fileSyncer) apply(op syncOp) (syncOpKind, error) {
+	kind := op.kind
+	if kind == syncConflict {
+		switch fsr.prefer {
+		case "local":
+			kind = syncUpload
+		case "remote":
+			kind = syncDownload
+		default:
+			return syncConflict, nil
+		}
+	}
+	switch kind {
+	case syncUpload:
+		return fsr.upload(op.path)
+	case syncDownload:
+		return kind, fsr.download(op.path)
+	case syncCompare:
+		return fsr.compare(op.path)
+	case syncDeleteLocal:
+		if err := os.Remove(fsr.localPath(op.path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return kind, err
+		}
+	case syncDeleteRemote:
+		if err := fsr.cfs.Remove(fsr.remotePath(op.path)); err != nil {
+			return kind, err
+		}
+	}
+	delete(fsr.manifest.Files, op.path)
+	return kind, nil
+}
+
+// upload writes the local file over the remote file, as long as the remote
+// file is still the version it was when the sync started.
+func (fsr *fileSyncer) upload(p string) (syncOpKind, error) {
+	lp := fsr.localPath(p)
+	h, err := hashFile(lp)
+	if err != nil {
+		return syncUpload, err
+	}
+	f, err := os.Open(lp)
+	if err != nil {
+		return syncUpload, err
+	}
+	defer f.Close() // nolint:errcheck
+	fi, err := f.Stat()
+	if err != nil {
+		return syncUpload, err
+	}
+	var etag string
+	rfi, exists := fsr.remotes[p]
+	switch {
+	case !exists:
+		etag, err = fsr.cfs.WriteFileIf(fsr.remotePath(p), f, "")
+	case fileETag(rfi) != "":
+		etag, err = fsr.cfs.WriteFileIf(fsr.remotePath(p), f, fileETag(rfi))
+	default:
+		err = fsr.cfs.WriteFile(fsr.remotePath(p), f)
+	}
+	var ce *cfs.ConflictError
+	if errors.As(err, &ce) {
+		return syncConflict, nil
+	}
+	if err != nil {
+		return syncUpload, err
+	}
+	fsr.manifest.Files[p] = syncFile{Hash: h, Size: fi.Size(), ModTime: fi.ModTime(), ETag: etag}
+	return syncUpload, nil
+}
+
+// download writes the remote file over the local file, keeping its
+// modification time.
+func (fsr *fileSyncer) download(p string) error {
+	f, err := fsr.cfs.Open(fsr.remotePath(p))
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	lp := fsr.localPath(p)
+	if err := os.MkdirAll(filepath.Dir(lp), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(lp), ".charm-sync-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	hw := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hw), f)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), fi.ModTime(), fi.ModTime()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), lp); err != nil {
+		return err
+	}
+	lfi, err := os.Stat(lp)
+	if err != nil {
+		return err
+	}
+	fsr.manifest.Files[p] = syncFile{
+		Hash:    hex.EncodeToString(hw.Sum(nil)),
+		Size:    lfi.Size(),
+		ModTime: lfi.ModTime(),
+		ETag:    fileETag(fi),
+	}
+	return nil
+}
+
+// compare checks a file that showed up on both sides since the last sync. If
+// both copies are the same it's recorded as synced, otherwise it's a
+// conflict.
+func (fsr *fileSyncer) compare(p string) (syncOpKind, error) {
+	lfi := fsr.locals[p]
+	rfi := fsr.remotes[p]
+	if lfi.Size() != rfi.Size() {
+		return fsr.apply(syncOp{kind: syncConflict, path: p})
+	}
+	lh, err := hashFile(fsr.localPath(p))
+	if err != nil {
+		return syncCompare, err
+	}
+	rh, err := fsr.remoteHash(p, rfi)
+	if err != nil {
+		return syncCompare, err
+	}
+	if rh != lh {
+		return fsr.apply(syncOp{kind: syncConflict, path: p})
+	}
+	fsr.manifest.Files[p] = syncFile{Hash: lh, Size: lfi.Size(), ModTime: lfi.ModTime(), ETag: fileETag(rfi)}
+	return syncForget, nil
+}
+
+// remoteHash returns the hash of the remote file. If a file with the same
+// ETag was synced before, like a file that was since moved on both sides, the
+// hash is taken from the manifest. Otherwise the file is downloaded to hash
+// it.
+func (fsr *fileSyncer) remoteHash(p string, fi fs.FileInfo) (string, error) {
+	if etag := fileETag(fi); etag != "" {
+		for _, f := range fsr.manifest.Files {
+			if f.ETag == etag && f.Size == fi.Size() {
+				return f.Hash, nil
+			}
+		}
+	}
+	f, err := fsr.cfs.Open(fsr.remotePath(p))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() // nolint:errcheck
+	hw := sha256.New()
+	if _, err := io.Copy(hw, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hw.Sum(nil)), nil
+}
+
+func (fsr *fileSyncer) localPath(p string) string {
+	return filepath.Join(fsr.local, filepath.FromSlash(p))
+}
+
+func (fsr *fileSyncer) remotePath(p string) string {
+	if p == "." {
+		return fsr.remote
+	}
+	return path.Join(fsr.remote, p)
+}
+
+// localSyncFiles returns the regular files in the local directory by their
+// slash separated path relative to it.
+func localSyncFiles(root string) (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == root {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".charm-sync-") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = fi
+		return nil
+	})
+	return files, err
+}
+
+// remoteSyncFiles returns the files in the remote directory by their path
+// relative to it.
+func remoteSyncFiles(lsfs *cfs.FS, root string) (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	err := lsfs.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == root {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		files[rel] = fi
+		return nil
+	})
+	return files, err
+}
+
+// loadSyncManifest reads the manifest for a local and remote directory pair
+// from the data directory, or returns an empty one for a first sync.
+func loadSyncManifest(dataPath string, local string, remote string) (*syncManifest, error) {
+	key := sha256.Sum256([]byte(local + "\n" + remote))
+	m := &syncManifest{
+		Local:  local,
+		Remote: remote,
+		Files:  make(map[string]syncFile),
+		path:   filepath.Join(dataPath, "sync", hex.EncodeToString(key[:8])+".json"),
+	}
+	b, err := os.ReadFile(m.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("invalid sync manifest %s: %w", m.path, err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]syncFile)
+	}
+	return m, nil
+}
+
+func (m *syncManifest) save() error {
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() // nolint:errcheck
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileETag returns the ETag of a remote file, if the server sent one.
+func fileETag(fi fs.FileInfo) string {
+	if cfi, ok := fi.(*cfs.FileInfo); ok {
+		return cfi.FileInfo.ETag
+	}
+	return ""
+}
+
+func init() {
+	fsSyncCmd.Flags().BoolVar(&syncDelete, "delete", false, "delete files that were deleted on the other side")
+	fsSyncCmd.Flags().BoolVarP(&syncDryRun, "dry-run", "n", false, "show what would be done without changing anything")
+	fsSyncCmd.Flags().StringVar(&syncPrefer, "prefer", "", "resolve conflicts with the local or remote copy")
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cfs "github.com/charmbracelet/charm/fs"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestSync(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	lsfs, err := cfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	del, dryRun, prefer := syncDelete, syncDryRun, syncPrefer
+	t.Cleanup(func() { syncDelete, syncDryRun, syncPrefer = del, dryRun, prefer })
+
+	local := t.TempDir()
+	writeLocal := func(name string, content string) {
+		t.Helper()
+		p := filepath.Join(local, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// Make sure the change is seen even on coarse file system clocks.
+		mt := time.Now().Add(time.Duration(len(content)) * time.Second)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeRemote := func(name string, content string) {
+		t.Helper()
+		p := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close() // nolint:errcheck
+		if err := lsfs.WriteFile("backup/"+name, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(name string, content string) {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(local, name))
+		if err != nil || string(b) != content {
+			t.Fatalf("expected local %s to be %q, got %q (%v)", name, content, b, err)
+		}
+		b, err = lsfs.ReadFile("backup/" + name)
+		if err != nil || string(b) != content {
+			t.Fatalf("expected remote %s to be %q, got %q (%v)", name, content, b, err)
+		}
+	}
+	sync := func() error {
+		return fsSync(nil, []string{local, "charm:backup"})
+	}
+
+	writeLocal("a", "one")
+	writeLocal("dir/b", "two")
+	writeRemote("c", "three")
+	if err := sync(); err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	expect("a", "one")
+	expect("dir/b", "two")
+	expect("c", "three")
+
+	writeLocal("a", "local change")
+	writeRemote("dir/b", "remote change")
+	if err := sync(); err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	expect("a", "local change")
+	expect("dir/b", "remote change")
+
+	writeLocal("c", "changed here")
+	writeRemote("c", "and changed there")
+	if err := sync(); err == nil {
+		t.Fatal("expected a conflict")
+	}
+	if b, _ := os.ReadFile(filepath.Join(local, "c")); string(b) != "changed here" {
+		t.Fatalf("expected a conflict to be left alone, got %q", b)
+	}
+	syncPrefer = "remote"
+	if err := sync(); err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	syncPrefer = ""
+	expect("c", "and changed there")
+
+	if err := os.Remove(filepath.Join(local, "a")); err != nil {
+		t.Fatal(err)
+	}
+	syncDryRun = true
+	syncDelete = true
+	if err := sync(); err != nil {
+		t.Fatalf("dry run error: %s", err)
+	}
+	if _, err := lsfs.ReadFile("backup/a"); err != nil {
+		t.Fatalf("expected a dry run to leave the remote file, got %v", err)
+	}
+	syncDryRun = false
+	if err := sync(); err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	if _, err := lsfs.ReadFile("backup/a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the remote file to be deleted, got %v", err)
+	}
+}
+
+func TestSyncIdentical(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	lsfs, err := cfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := t.TempDir()
+	p := filepath.Join(local, "same")
+	if err := os.WriteFile(p, []byte("on both sides"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint:errcheck
+	if err := lsfs.WriteFile("backup/same", f); err != nil {
+		t.Fatal(err)
+	}
+	// There's no manifest yet, so the files have to be compared.
+	if err := fsSync(nil, []string{local, "charm:backup"}); err != nil {
+		t.Fatalf("expected identical files not to conflict, got %s", err)
+	}
+}
+
+func TestSyncUploadETag(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	lsfs, err := cfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := t.TempDir()
+	if err := os.WriteFile(filepath.Join(local, "file"), []byte("uploaded"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsSync(nil, []string{local, "charm:backup"}); err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	dp, err := cl.DataPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadSyncManifest(dp, local, "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := lsfs.Open("backup/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint:errcheck
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etag := fileETag(fi); etag == "" || m.Files["file"].ETag != etag {
+		t.Fatalf("expected the manifest to record ETag %q, got %q", etag, m.Files["file"].ETag)
+	}
+}
+
+func TestSyncCompareKnownETag(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	lsfs, err := cfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := t.TempDir()
+	if err := os.WriteFile(filepath.Join(local, "moved"), []byte("content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	lfi, err := os.Stat(filepath.Join(local, "moved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hashFile(filepath.Join(local, "moved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rfi := &cfs.FileInfo{}
+	rfi.FileInfo.Size = lfi.Size()
+	rfi.FileInfo.ETag = `"known"`
+	// The remote file doesn't exist, so comparing only works if it isn't
+	// downloaded.
+	fsr := &fileSyncer{
+		cfs:    lsfs,
+		local:  local,
+		remote: "backup",
+		manifest: &syncManifest{Files: map[string]syncFile{
+			"original": {Hash: h, Size: lfi.Size(), ETag: `"known"`},
+		}},
+		locals:  map[string]fs.FileInfo{"moved": lfi},
+		remotes: map[string]fs.FileInfo{"moved": rfi},
+	}
+	kind, err := fsr.compare("moved")
+	if err != nil {
+		t.Fatalf("compare error: %s", err)
+	}
+	if kind != syncForget || fsr.manifest.Files["moved"].Hash != h {
+		t.Fatalf("expected the file to be recorded as synced, got %s %+v", kind, fsr.manifest.Files["moved"])
+	}
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestCopyRoundTrip(t *testing.T) {
+	_ = testserver.SetupTestServer(t)
+	recursive := isRecursive
+	t.Cleanup(func() { isRecursive = recursive })
+	isRecursive = true
+
+	src := filepath.Join(t.TempDir(), "tree")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	if err := os.MkdirAll(filepath.Join(src, "empty"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(src, "dir", "file")
+	if err := os.WriteFile(file, []byte("hello"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	dirMtime := mtime.Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "dir"), dirMtime, dirMtime); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fsCopy(nil, []string{src, "charm:backup"}); err != nil {
+		t.Fatalf("upload error: %s", err)
+	}
+	dst := t.TempDir()
+	if err := fsCopy(nil, []string{"charm:backup/tree", dst}); err != nil {
+		t.Fatalf("download error: %s", err)
+	}
+
+	fi, err := os.Stat(filepath.Join(dst, "tree", "empty"))
+	if err != nil || !fi.IsDir() {
+		t.Fatalf("expected the empty directory to round trip, got %v", err)
+	}
+	fi, err = os.Stat(filepath.Join(dst, "tree", "dir", "file"))
+	if err != nil {
+		t.Fatalf("expected the file to round trip, got %v", err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Fatalf("expected the modification time %s, got %s", mtime, fi.ModTime())
+	}
+	if fi.Mode().Perm() != 0o640 {
+		t.Fatalf("expected mode %s, got %s", os.FileMode(0o640), fi.Mode())
+	}
+	fi, err = os.Stat(filepath.Join(dst, "tree", "dir"))
+	if err != nil {
+		t.Fatalf("expected the directory to round trip, got %v", err)
+	}
+	if !fi.ModTime().Equal(dirMtime) {
+		t.Fatalf("expected the directory modification time %s, got %s", dirMtime, fi.ModTime())
+	}
+}
+
+func TestParallelCopy(t *testing.T) {
+	_ = testserver.SetupTestServer(t)
+	recursive, concurrency := isRecursive, copyConcurrency
+	t.Cleanup(func() { isRecursive, copyConcurrency = recursive, concurrency })
+	isRecursive = true
+	copyConcurrency = 4
+
+	src := filepath.Join(t.TempDir(), "many")
+	for i := 0; i < 30; i++ {
+		p := filepath.Join(src, fmt.Sprintf("dir%d", i%3), fmt.Sprintf("file%d", i))
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(p), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fsCopy(nil, []string{src, "charm:parallel"}); err != nil {
+		t.Fatalf("upload error: %s", err)
+	}
+	dst := t.TempDir()
+	if err := fsCopy(nil, []string{"charm:parallel/many", dst}); err != nil {
+		t.Fatalf("download error: %s", err)
+	}
+	for i := 0; i < 30; i++ {
+		p := filepath.Join(src, fmt.Sprintf("dir%d", i%3), fmt.Sprintf("file%d", i))
+		b, err := os.ReadFile(filepath.Join(dst, "many", fmt.Sprintf("dir%d", i%3), fmt.Sprintf("file%d", i)))
+		if err != nil || string(b) != p {
+			t.Fatalf("expected file%d to round trip, got %q (%v)", i, b, err)
+		}
+	}
+
+	// Progress is counted per file and byte, and a failed file stops the copy.
+	lrfs, err := newLocalRemoteFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := filepath.Join(src, "dir0", "file0")
+	jobs := []copyJob{
+		{src: ok, dst: "charm:progress/ok", size: int64(len(ok))},
+		{src: filepath.Join(src, "missing"), dst: "charm:progress/missing"},
+	}
+	copyConcurrency = 1
+	cp := newCopyProgress(jobs)
+	if err := lrfs.transfer(context.Background(), jobs, cp); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the missing file to fail the copy, got %v", err)
+	}
+	if cp.doneFiles != 1 || cp.doneBytes != int64(len(ok)) || cp.totalFiles != 2 {
+		t.Fatalf("expected 1 of 2 files and %d bytes done, got %d of %d files and %d bytes", len(ok), cp.doneFiles, cp.totalFiles, cp.doneBytes)
+	}
+}
+
+func TestCopyOutput(t *testing.T) {
+	_ = testserver.SetupTestServer(t)
+	recursive := isRecursive
+	t.Cleanup(func() { isRecursive = recursive })
+	isRecursive = true
+
+	src := filepath.Join(t.TempDir(), "out")
+	if err := os.MkdirAll(src, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// run runs fsCopy, returning what it wrote to stdout and stderr.
+	run := func(args ...string) (string, string) {
+		t.Helper()
+		stdout, stderr := os.Stdout, os.Stderr
+		t.Cleanup(func() { os.Stdout, os.Stderr = stdout, stderr })
+		dir := t.TempDir()
+		outf, err := os.Create(filepath.Join(dir, "stdout"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errf, err := os.Create(filepath.Join(dir, "stderr"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdout, os.Stderr = outf, errf
+		err = fsCopy(nil, args)
+		os.Stdout, os.Stderr = stdout, stderr
+		_ = outf.Close()
+		_ = errf.Close()
+		if err != nil {
+			t.Fatalf("copy error: %s", err)
+		}
+		o, _ := os.ReadFile(outf.Name())
+		e, _ := os.ReadFile(errf.Name())
+		return string(o), string(e)
+	}
+
+	if o, e := run(filepath.Join(src, "a"), "charm:out/a"); o != "" || e != "" {
+		t.Fatalf("expected a single file to be copied quietly, got %q and %q", o, e)
+	}
+	o, e := run(src, "charm:out")
+	if o != "" {
+		t.Fatalf("expected nothing on stdout, got %q", o)
+	}
+	if !strings.Contains(e, "Copied 2 files") {
+		t.Fatalf("expected the summary on stderr, got %q", e)
+	}
+}
+
+func TestCopyDoesNotSpool(t *testing.T) {
+	_ = testserver.SetupTestServer(t)
+	src := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(src, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Spooling the file to a temporary copy would fail.
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	if err := fsCopy(nil, []string{src, "charm:file"}); err != nil {
+		t.Fatalf("copy error: %s", err)
+	}
+}
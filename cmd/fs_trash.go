@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	cfs "github.com/charmbracelet/charm/fs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fsTrashCmd = &cobra.Command{
+		Use:    "trash",
+		Hidden: false,
+		Short:  "Manage deleted files.",
+		Long:   paragraph("Deleted files and directories are kept in the trash for a while before they're removed for good. List, restore or remove them."),
+	}
+
+	fsTrashListCmd = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List deleted files and directories.",
+		Args:    cobra.NoArgs,
+		RunE:    fsTrashList,
+	}
+
+	fsTrashRestoreCmd = &cobra.Command{
+		Use:   "restore ID",
+		Short: "Restore a deleted file or directory to where it was deleted from.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  fsTrashRestore,
+	}
+
+	fsTrashRemoveCmd = &cobra.Command{
+		Use:   "rm ID",
+		Short: "Remove a deleted file or directory for good.",
+		Args:  cobra.ExactArgs(1),
+		RunE:  fsTrashRemove,
+	}
+
+	fsTrashEmptyCmd = &cobra.Command{
+		Use:   "empty",
+		Short: "Remove everything in the trash for good.",
+		Args:  cobra.NoArgs,
+		RunE:  fsTrashEmpty,
+	}
+)
+
+func fsTrashList(_ *cobra.Command, _ []string) error {
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	items, err := lsfs.Trash()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("The trash is empty.")
+		return nil
+	}
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 1, 1, ' ', 0)
+	for _, item := range items {
+		p := item.Path
+		if item.IsDir {
+			p += "/"
+		}
+		var deleted string
+		if item.DeletedAt != nil {
+			deleted = item.DeletedAt.Local().Format("Jan _2 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t %s\n", item.ID, item.Size, deleted, p)
+	}
+	return w.Flush()
+}
+
+func fsTrashRestore(_ *cobra.Command, args []string) error {
+	id, err := trashItemID(args[0])
+	if err != nil {
+		return err
+	}
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	err = lsfs.RestoreTrash(id)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("can't restore %d, something else is at its path now; move it away and try again", id)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("there's no %d in the trash", id)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d from the trash.\n", id)
+	return nil
+}
+
+func fsTrashRemove(_ *cobra.Command, args []string) error {
+	id, err := trashItemID(args[0])
+	if err != nil {
+		return err
+	}
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	err = lsfs.DeleteTrash(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("there's no %d in the trash", id)
+	}
+	return err
+}
+
+func fsTrashEmpty(_ *cobra.Command, _ []string) error {
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	if err := lsfs.EmptyTrash(); err != nil {
+		return err
+	}
+	fmt.Println("Emptied the trash.")
+	return nil
+}
+
+func trashItemID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid trash item %q, expected an ID from trash ls", s)
+	}
+	return id, nil
+}
+
+func init() {
+	fsTrashCmd.AddCommand(fsTrashListCmd)
+	fsTrashCmd.AddCommand(fsTrashRestoreCmd)
+	fsTrashCmd.AddCommand(fsTrashRemoveCmd)
+	fsTrashCmd.AddCommand(fsTrashEmptyCmd)
+}
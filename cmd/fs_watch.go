@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+
+	cfs "github.com/charmbracelet/charm/fs"
+)
+
+const (
+	watchRetryDelay    = time.Second
+	watchMaxRetryDelay = time.Minute
+)
+
+var (
+	watchDebounce time.Duration
+
+	fsWatchCmd = &cobra.Command{
+		Use:    "watch DIR charm:PATH",
+		Hidden: false,
+		Short:  "Back up a directory continuously, uploading files as they change.",
+		Long:   paragraph("Watch a directory and upload files to the remote path when they change. Uploads that fail are retried, files waiting to be uploaded are remembered across restarts, and files modified while the watch wasn't running are uploaded when it starts. Deleting a local file doesn't delete the remote copy."),
+		Args:   cobra.ExactArgs(2),
+		RunE:   fsWatch,
+	}
+)
+
+// uploadQueue is the set of files waiting to be uploaded, by their slash
+// separated path relative to the watched directory. It's saved to disk
+// periodically so pending uploads survive a restart, along with the time
+// changes were watched until.
+type uploadQueue struct {
+	mu      sync.Mutex
+	path    string
+	pending map[string]time.Time
+	dirty   bool
+	// since is when the last watch stopped watching for changes.
+	since time.Time
+}
+
+// uploadQueueFile is how the upload queue is saved to disk.
+type uploadQueueFile struct {
+	Files   []string  `json:"files"`
+	Watched time.Time `json:"watched"`
+}
+
+// fileWatcher uploads the files in a local directory to a remote directory
+// as they change.
+type fileWatcher struct {
+	cfs      *cfs.FS
+	local    string
+	remote   string
+	debounce time.Duration
+	queue    *uploadQueue
+	watcher  *fsnotify.Watcher
+}
+
+func fsWatch(_ *cobra.Command, args []string) error {
+	lp := newLocalRemotePath(args[0])
+	rp := newLocalRemotePath(args[1])
+	if lp.pathType != localPath || rp.pathType != remotePath {
+		return errors.New("expected a local directory and a charm: path")
+	}
+	lsfs, err := cfs.NewFS()
+	if err != nil {
+		return err
+	}
+	fw, err := newFileWatcher(lsfs, lp.path, rp.path, watchDebounce)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Info("Watching for changes", "dir", fw.local, "remote", "charm:"+fw.remote)
+	return fw.run(ctx)
+}
+
+func newFileWatcher(lsfs *cfs.FS, local string, remote string, debounce time.Duration) (*fileWatcher, error) {
+	local, err := filepath.Abs(local)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(local)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, errors.New("can only watch a directory")
+	}
+	dp, err := lsfs.Client().DataPath()
+	if err != nil {
+		return nil, err
+	}
+	q, err := loadUploadQueue(dp, local, remote)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWatcher{cfs: lsfs, local: local, remote: remote, debounce: debounce, queue: q}, nil
+}
+
+// run watches the directory and uploads changed files once they've been left
+// alone for the debounce period, until the context is done.
+func (fw *fileWatcher) run(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close() // nolint:errcheck
+	fw.watcher = w
+	// Files that changed while nothing was watching are uploaded too.
+	if err := fw.watchDir(fw.local, fw.queue.since); err != nil {
+		return err
+	}
+	defer func() {
+		if err := fw.queue.close(); err != nil {
+			log.Error("Cannot save upload queue", "err", err)
+		}
+	}()
+
+	tick := fw.debounce / 2
+	if tick <= 0 {
+		tick = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	delay := watchRetryDelay
+	var retryAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			fw.handleEvent(ev)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			log.Error("Watch error", "err", err)
+		case <-ticker.C:
+			if err := fw.queue.flush(); err != nil {
+				log.Error("Cannot save upload queue", "err", err)
+			}
+			if time.Now().Before(retryAt) {
+				continue
+			}
+			if err := fw.uploadReady(ctx); err != nil {
+				log.Error("Upload failed, retrying", "err", err, "in", delay)
+				retryAt = time.Now().Add(delay)
+				delay *= 2
+				if delay > watchMaxRetryDelay {
+					delay = watchMaxRetryDelay
+				}
+				continue
+			}
+			delay = watchRetryDelay
+		}
+	}
+}
+
+// watchDir watches the directory and its subdirectories, and queues the files
+// in them that were modified after since. A zero since queues all of them,
+// for directories that showed up after the watch started.
+func (fw *fileWatcher) watchDir(dir string, since time.Time) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return fw.watcher.Add(p)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if fi.ModTime().After(since) {
+			fw.enqueue(p)
+		}
+		return nil
+	})
+}
+
+func (fw *fileWatcher) handleEvent(ev fsnotify.Event) {
+	if !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Chmod) {
+		return
+	}
+	fi, err := os.Lstat(ev.Name)
+	if err != nil {
+		return
+	}
+	if fi.IsDir() {
+		if ev.Has(fsnotify.Create) {
+			if err := fw.watchDir(ev.Name, time.Time{}); err != nil {
+				log.Error("Cannot watch directory", "dir", ev.Name, "err", err)
+			}
+		}
+		return
+	}
+	if fi.Mode().IsRegular() {
+		fw.enqueue(ev.Name)
+	}
+}
+
+func (fw *fileWatcher) enqueue(name string) {
+	rel, err := filepath.Rel(fw.local, name)
+	if err != nil {
+		return
+	}
+	fw.queue.add(filepath.ToSlash(rel))
+}
+
+// uploadReady uploads the queued files that haven't changed for the debounce
+// period. It stops at the first failed upload, leaving it and the rest of
+// the files queued.
+func (fw *fileWatcher) uploadReady(ctx context.Context) error {
+	for _, p := range fw.queue.ready(fw.debounce) {
+		if ctx.Err() != nil {
+			return nil
+		}
+		queued := fw.queue.queuedAt(p)
+		f, err := os.Open(filepath.Join(fw.local, filepath.FromSlash(p)))
+		if err != nil {
+			// The file is gone or can't be read, there's nothing to upload.
+			log.Warn("Skipping file", "path", p, "err", err)
+			fw.queue.remove(p, queued)
+			continue
+		}
+		err = fw.cfs.WriteFile(path.Join(fw.remote, p), f)
+		f.Close() // nolint:errcheck
+		if err != nil {
+			return err
+		}
+		log.Info("Uploaded", "path", p)
+		fw.queue.remove(p, queued)
+	}
+	return nil
+}
+
+// loadUploadQueue reads the queue for a local and remote directory pair from
+// the data directory. Files that were queued when the last watch stopped are
+// ready to upload right away. Without an earlier watch, only changes from now
+// on are uploaded.
+func loadUploadQueue(dataPath string, local string, remote string) (*uploadQueue, error) {
+	key := sha256.Sum256([]byte(local + "\n" + remote))
+	q := &uploadQueue{
+		path:    filepath.Join(dataPath, "watch", hex.EncodeToString(key[:8])+".json"),
+		pending: make(map[string]time.Time),
+		since:   time.Now(),
+	}
+	b, err := os.ReadFile(q.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var qf uploadQueueFile
+	if err := json.Unmarshal(b, &qf); err != nil {
+		return nil, err
+	}
+	for _, p := range qf.Files {
+		q.pending[p] = time.Time{}
+	}
+	q.since = qf.Watched
+	return q, nil
+}
+
+// add queues the file, or pushes back its upload if it's already queued.
+func (q *uploadQueue) add(p string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, queued := q.pending[p]; !queued {
+		q.dirty = true
+	}
+	q.pending[p] = time.Now()
+}
+
+// remove takes the file off the queue, unless it changed again since it was
+// queued at the given time.
+func (q *uploadQueue) remove(p string, queued time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if t, ok := q.pending[p]; !ok || !t.Equal(queued) {
+		return
+	}
+	delete(q.pending, p)
+	q.dirty = true
+}
+
+func (q *uploadQueue) queuedAt(p string) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending[p]
+}
+
+// ready returns the queued files that haven't changed for d, in path order.
+func (q *uploadQueue) ready(d time.Duration) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ps := make([]string, 0)
+	for p, t := range q.pending {
+		if time.Since(t) >= d {
+			ps = append(ps, p)
+		}
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// flush saves the queue if files were added or removed since it was last
+// saved. Files changed after the last save are found again by their
+// modification time if the watch doesn't stop cleanly.
+func (q *uploadQueue) flush() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.dirty {
+		return nil
+	}
+	return q.save()
+}
+
+// close saves the queue when the watch stops, so the next one knows when it
+// stopped watching.
+func (q *uploadQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.save()
+}
+
+// save writes the queued paths to disk. It must be called with the lock held.
+func (q *uploadQueue) save() error {
+	qf := uploadQueueFile{
+		Files:   make([]string, 0, len(q.pending)),
+		Watched: time.Now(),
+	}
+	for p := range q.pending {
+		qf.Files = append(qf.Files, p)
+	}
+	sort.Strings(qf.Files)
+	b, err := json.Marshal(qf)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o700); err != nil {
+		return err
+	}
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	q.dirty = false
+	return nil
+}
+
+func init() {
+	fsWatchCmd.Flags().DurationVar(&watchDebounce, "debounce", 2*time.Second, "how long a file has to be left alone before it's uploaded")
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cfs "github.com/charmbracelet/charm/fs"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestWatch(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	lsfs, err := cfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := t.TempDir()
+	writeLocal := func(name string, content string, mtime time.Time) {
+		t.Helper()
+		p := filepath.Join(local, name)
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeLocal("queued.txt", "queued", time.Now().Add(-time.Hour))
+	writeLocal("old.txt", "old", time.Now().Add(-time.Hour))
+
+	// A file left in the queue by an earlier watch is uploaded on start, and
+	// so is a file modified after the earlier watch stopped.
+	fw, err := newFileWatcher(lsfs, local, "backup", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.queue.add("queued.txt")
+	if err := fw.queue.close(); err != nil {
+		t.Fatal(err)
+	}
+	writeLocal("offline.txt", "offline", time.Now().Add(time.Minute))
+	fw, err = newFileWatcher(lsfs, local, "backup", 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fw.queue.ready(time.Hour)) != 1 {
+		t.Fatal("expected the queued file to be loaded and ready")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- fw.run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+
+	expect := func(name string, content string) {
+		t.Helper()
+		var got string
+		for i := 0; i < 100; i++ {
+			if f, err := lsfs.Open("backup/" + name); err == nil {
+				b, _ := io.ReadAll(f)
+				f.Close() // nolint:errcheck
+				got = string(b)
+				if got == content {
+					return
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		t.Fatalf("expected remote %s to be %q, got %q", name, content, got)
+	}
+	expect("queued.txt", "queued")
+	expect("offline.txt", "offline")
+	if _, err := lsfs.Open("backup/old.txt"); err == nil {
+		t.Fatal("expected a file that didn't change since the last watch to be left alone")
+	}
+
+	if err := os.WriteFile(filepath.Join(local, "a.txt"), []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expect("a.txt", "hello")
+	if err := os.WriteFile(filepath.Join(local, "a.txt"), []byte("hello again"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expect("a.txt", "hello again")
+
+	// Files in new directories are picked up too.
+	if err := os.MkdirAll(filepath.Join(local, "sub", "dir"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(local, "sub", "dir", "b.txt"), []byte("nested"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	expect("sub/dir/b.txt", "nested")
+}
+
+func TestUploadQueueSave(t *testing.T) {
+	dp := t.TempDir()
+	q, err := loadUploadQueue(dp, "/local", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.add("a.txt")
+	if _, err := os.Stat(q.path); err == nil {
+		t.Fatal("expected adding a file not to save the queue right away")
+	}
+	if err := q.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(q.path); err != nil {
+		t.Fatalf("expected the queue to be saved, got %v", err)
+	}
+
+	// Pushing back a queued file doesn't change what's saved.
+	if err := os.Remove(q.path); err != nil {
+		t.Fatal(err)
+	}
+	q.add("a.txt")
+	if err := q.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(q.path); err == nil {
+		t.Fatal("expected an unchanged queue not to be saved again")
+	}
+
+	q.remove("a.txt", q.queuedAt("a.txt"))
+	if err := q.flush(); err != nil {
+		t.Fatal(err)
+	}
+	q, err = loadUploadQueue(dp, "/local", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.pending) != 0 {
+		t.Fatalf("expected the removed file to be saved, got %v", q.pending)
+	}
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// JoinCmd is the cobra.Command to create an account with an invite code.
+var JoinCmd = &cobra.Command{
+	Use:   "join CODE",
+	Short: "Create an account with an invite code",
+	Long:  paragraph("Create a Charm account on a server that only accepts new users by " + keyword("invite") + ". Ask the server’s admin for a code. To add this machine to an account you already have, use " + code("charm link") + " instead."),
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cc := initCharmClient()
+		if err := cc.RedeemInvite(strings.ToUpper(args[0])); err != nil {
+			return err
+		}
+		id, err := cc.ID()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Welcome! Your Charm ID is %s.\n", id)
+		return nil
+	},
+}
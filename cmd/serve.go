@@ -21,6 +21,7 @@ var (
 	serverStatsPort  int
 	serverHealthPort int
 	serverDataDir    string
+	serverDBDSN      string
 
 	// ServeCmd is the cobra.Command to self-host the Charm Cloud.
 	ServeCmd = &cobra.Command{
@@ -28,7 +29,7 @@ var (
 		Aliases: []string{"server"},
 		Hidden:  false,
 		Short:   "Start a self-hosted Charm Cloud server.",
-		Long:    paragraph("Start the SSH and HTTP servers needed to power a SQLite-backed Charm Cloud."),
+		Long:    paragraph("Start the SSH and HTTP servers needed to power a SQLite or PostgreSQL-backed Charm Cloud."),
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg := server.DefaultConfig()
@@ -47,6 +48,9 @@ var (
 			if serverDataDir != "" {
 				cfg.DataDir = serverDataDir
 			}
+			if serverDBDSN != "" {
+				cfg.DBDSN = serverDBDSN
+			}
 			sp := filepath.Join(cfg.DataDir, ".ssh")
 			kp, err := keygen.New(filepath.Join(sp, "charm_server_ed25519"), keygen.WithKeyType(keygen.Ed25519), keygen.WithWrite())
 			if err != nil {
@@ -79,10 +83,12 @@ var (
 func init() {
 	ServeCmd.AddCommand(
 		ServeMigrationCmd,
+		ServeAdminCmd,
 	)
 	ServeCmd.Flags().IntVar(&serverHTTPPort, "http-port", 0, "HTTP port to listen on")
 	ServeCmd.Flags().IntVar(&serverSSHPort, "ssh-port", 0, "SSH port to listen on")
 	ServeCmd.Flags().IntVar(&serverStatsPort, "stats-port", 0, "Stats port to listen on")
 	ServeCmd.Flags().IntVar(&serverHealthPort, "health-port", 0, "Health port to listen on")
-	ServeCmd.Flags().StringVar(&serverDataDir, "data-dir", "", "Directory to store SQLite db, SSH keys and file data")
+	ServeCmd.PersistentFlags().StringVar(&serverDataDir, "data-dir", "", "Directory to store SQLite db, SSH keys and file data")
+	ServeCmd.PersistentFlags().StringVar(&serverDBDSN, "db-dsn", "", "PostgreSQL connection URL, uses SQLite in the data directory if empty")
 }
@@ -0,0 +1,448 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/charm/client"
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/server/db"
+	"github.com/dustin/go-humanize"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var (
+	adminPage          int
+	adminForce         bool
+	adminInviteExpires time.Duration
+	adminInviteCount   int
+
+	// ServeAdminCmd is the cobra.Command to administer a self-hosted Charm
+	// server.
+	ServeAdminCmd = &cobra.Command{
+		Use:   "admin",
+		Short: "Administer the self-hosted Charm server.",
+		Long:  paragraph("Manage the accounts on a self-hosted Charm server. These commands work directly on the server’s database and file storage, so run them with the same settings as " + code("charm serve") + "."),
+		Args:  cobra.NoArgs,
+	}
+
+	serveAdminUsersCmd = &cobra.Command{
+		Use:   "users [SEARCH]",
+		Short: "List users, optionally searching by Charm ID or name.",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if adminPage < 1 {
+				return fmt.Errorf("page must be a positive number")
+			}
+			return withAdmin(func(cfg *server.Config) error {
+				var search string
+				if len(args) > 0 {
+					search = args[0]
+				}
+				us, err := cfg.DB.ListUsers(search, (adminPage-1)*50)
+				if err != nil {
+					return err
+				}
+				if len(us) == 0 {
+					fmt.Println("No users found.")
+					return nil
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				for _, u := range us {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", u.CharmID, u.Name, formatTime(u.CreatedAt), userStatus(u))
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	serveAdminUserCmd = &cobra.Command{
+		Use:   "user ID|NAME",
+		Short: "Show a user’s details, keys and storage usage.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdmin(func(cfg *server.Config) error {
+				u, err := findUser(cfg.DB, args[0])
+				if err != nil {
+					return err
+				}
+				keys, err := cfg.DB.KeysForUser(u)
+				if err != nil {
+					return err
+				}
+				var size int64
+				fi, err := cfg.FileStore.Stat(u.CharmID, "")
+				if err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+				if err == nil {
+					size = fi.Size()
+				}
+				quota := cfg.UserMaxStorage
+				if u.StorageQuota != nil {
+					quota = *u.StorageQuota
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintf(w, "Charm ID:\t%s\n", u.CharmID)
+				fmt.Fprintf(w, "Name:\t%s\n", u.Name)
+				fmt.Fprintf(w, "Created:\t%s\n", formatTime(u.CreatedAt))
+				fmt.Fprintf(w, "Status:\t%s\n", userStatus(u))
+				fmt.Fprintf(w, "Storage:\t%s\n", humanize.Bytes(uint64(size)))
+				fmt.Fprintf(w, "Quota:\t%s\n", quotaString(quota, u.StorageQuota == nil))
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				fmt.Printf("\nKeys:\n")
+				w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				for _, k := range keys {
+					fmt.Fprintf(w, "  %s\t%s\n", keyFingerprint(k), formatTime(k.CreatedAt))
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	serveAdminSuspendCmd = &cobra.Command{
+		Use:   "suspend ID|NAME",
+		Short: "Suspend an account, blocking all of its keys.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setSuspended(args[0], true)
+		},
+	}
+
+	serveAdminUnsuspendCmd = &cobra.Command{
+		Use:   "unsuspend ID|NAME",
+		Short: "Reinstate a suspended account.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setSuspended(args[0], false)
+		},
+	}
+
+	serveAdminDeleteCmd = &cobra.Command{
+		Use:   "delete ID|NAME",
+		Short: "Permanently delete an account and all of its data.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdmin(func(cfg *server.Config) error {
+				u, err := findUser(cfg.DB, args[0])
+				if err != nil {
+					return err
+				}
+				if !adminForce {
+					return fmt.Errorf("this permanently deletes account %s with its keys and files, run again with --force to continue", u.CharmID)
+				}
+				if err := server.DeleteAccount(cfg, u); err != nil {
+					return err
+				}
+				fmt.Printf("Deleted account %s.\n", u.CharmID)
+				return nil
+			})
+		},
+	}
+
+	serveAdminRevokeKeyCmd = &cobra.Command{
+		Use:   "revoke-key ID|NAME KEY|FINGERPRINT",
+		Short: "Unlink a key from an account.",
+		Long:  paragraph("Unlink a key from an account. The key can be given as an authorized key or as its " + code("SHA256:") + " fingerprint. Revoking the last key of an account deletes the account, which requires " + code("--force") + "."),
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdmin(func(cfg *server.Config) error {
+				u, err := findUser(cfg.DB, args[0])
+				if err != nil {
+					return err
+				}
+				keys, err := cfg.DB.KeysForUser(u)
+				if err != nil {
+					return err
+				}
+				var key *charm.PublicKey
+				for _, k := range keys {
+					fp := keyFingerprint(k)
+					if k.Key == args[1] || fp == args[1] || strings.TrimPrefix(fp, "SHA256:") == args[1] {
+						key = k
+						break
+					}
+				}
+				if key == nil {
+					return fmt.Errorf("key not found for account %s", u.CharmID)
+				}
+				if len(keys) == 1 && !adminForce {
+					return fmt.Errorf("this is the last key of account %s and revoking it deletes the account, run again with --force to continue", u.CharmID)
+				}
+				if len(keys) == 1 {
+					// Unlinking the last key would leave the files behind.
+					if err := server.DeleteAccount(cfg, u); err != nil {
+						return err
+					}
+					fmt.Printf("Revoked key %s and deleted account %s.\n", keyFingerprint(key), u.CharmID)
+					return nil
+				}
+				if err := cfg.DB.UnlinkUserKey(u, key.Key); err != nil {
+					return err
+				}
+				_ = cfg.DB.AddAuditEvent(u, &charm.AuditEvent{
+					Type:   charm.AuditEventUnlink,
+					Detail: "revoked by an admin: " + keyFingerprint(key),
+				})
+				fmt.Printf("Revoked key %s.\n", keyFingerprint(key))
+				return nil
+			})
+		},
+	}
+
+	serveAdminQuotaCmd = &cobra.Command{
+		Use:   "quota",
+		Short: "Manage storage quotas.",
+		Long:  paragraph("Manage per-account storage quotas. Accounts without a quota of their own use the server default, set with " + code("CHARM_SERVER_USER_MAX_STORAGE") + "."),
+		Args:  cobra.NoArgs,
+	}
+
+	serveAdminQuotaSetCmd = &cobra.Command{
+		Use:   "set ID|NAME SIZE",
+		Short: "Set an account’s storage quota, e.g. 50GB, or unlimited.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var quota int64
+			if args[1] != "unlimited" {
+				b, err := humanize.ParseBytes(args[1])
+				if err != nil {
+					return fmt.Errorf("invalid size %q: %w", args[1], err)
+				}
+				quota = int64(b)
+			}
+			return withAdmin(func(cfg *server.Config) error {
+				u, err := findUser(cfg.DB, args[0])
+				if err != nil {
+					return err
+				}
+				if err := cfg.DB.SetUserStorageQuota(u, &quota); err != nil {
+					return err
+				}
+				fmt.Printf("Storage quota for %s is %s.\n", u.CharmID, quotaString(quota, false))
+				return nil
+			})
+		},
+	}
+
+	serveAdminQuotaResetCmd = &cobra.Command{
+		Use:   "reset ID|NAME",
+		Short: "Reset an account to the default storage quota.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdmin(func(cfg *server.Config) error {
+				u, err := findUser(cfg.DB, args[0])
+				if err != nil {
+					return err
+				}
+				if err := cfg.DB.SetUserStorageQuota(u, nil); err != nil {
+					return err
+				}
+				fmt.Printf("Storage quota for %s is %s.\n", u.CharmID, quotaString(cfg.UserMaxStorage, true))
+				return nil
+			})
+		},
+	}
+
+	serveAdminInviteCmd = &cobra.Command{
+		Use:   "invite",
+		Short: "Manage invite codes.",
+		Long:  paragraph("Manage the invite codes used to create accounts when " + code("CHARM_SERVER_REGISTRATION") + " is set to " + keyword("invite") + ". New users redeem a code with " + code("charm join CODE") + "."),
+		Args:  cobra.NoArgs,
+	}
+
+	serveAdminInviteCreateCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create invite codes.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if adminInviteCount < 1 {
+				return fmt.Errorf("count must be a positive number")
+			}
+			return withAdmin(func(cfg *server.Config) error {
+				for i := 0; i < adminInviteCount; i++ {
+					inv, err := server.NewInvite(cfg.DB, adminInviteExpires)
+					if err != nil {
+						return err
+					}
+					fmt.Println(inv.Code)
+				}
+				return nil
+			})
+		},
+	}
+
+	serveAdminInviteListCmd = &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List invite codes.",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdmin(func(cfg *server.Config) error {
+				invs, err := cfg.DB.GetInvites()
+				if err != nil {
+					return err
+				}
+				if len(invs) == 0 {
+					fmt.Println("No invites.")
+					return nil
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				for _, inv := range invs {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", inv.Code, formatTime(inv.CreatedAt), inviteExpiry(inv), inviteStatus(inv))
+				}
+				return w.Flush()
+			})
+		},
+	}
+
+	serveAdminInviteRemoveCmd = &cobra.Command{
+		Use:     "rm CODE",
+		Aliases: []string{"delete"},
+		Short:   "Delete an invite code.",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAdmin(func(cfg *server.Config) error {
+				c := strings.ToUpper(args[0])
+				if err := cfg.DB.DeleteInvite(c); err != nil {
+					return err
+				}
+				fmt.Printf("Deleted invite %s.\n", c)
+				return nil
+			})
+		},
+	}
+)
+
+// withAdmin runs fn with a Config holding the server's database and file
+// store.
+func withAdmin(fn func(cfg *server.Config) error) error {
+	cfg, d, err := openServerDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close() // nolint:errcheck
+	if err := server.CheckSchemaVersion(d); err != nil {
+		return err
+	}
+	fstore, err := server.OpenFileStore(cfg)
+	if err != nil {
+		return err
+	}
+	return fn(cfg.WithFileStore(fstore))
+}
+
+// findUser looks up a user by Charm ID or name.
+func findUser(d db.DB, s string) (*charm.User, error) {
+	var u *charm.User
+	var err error
+	if _, perr := uuid.Parse(s); perr == nil {
+		u, err = d.GetUserWithID(s)
+	} else {
+		u, err = d.GetUserWithName(s)
+	}
+	if err == charm.ErrMissingUser {
+		return nil, fmt.Errorf("user not found: %s", s)
+	}
+	return u, err
+}
+
+func setSuspended(s string, suspended bool) error {
+	return withAdmin(func(cfg *server.Config) error {
+		u, err := findUser(cfg.DB, s)
+		if err != nil {
+			return err
+		}
+		if err := cfg.DB.SetUserSuspended(u, suspended); err != nil {
+			return err
+		}
+		u.Suspended = suspended
+		fmt.Printf("Account %s is %s.\n", u.CharmID, userStatus(u))
+		return nil
+	})
+}
+
+func userStatus(u *charm.User) string {
+	if u.Suspended {
+		return "suspended"
+	}
+	return "active"
+}
+
+func quotaString(quota int64, isDefault bool) string {
+	s := "unlimited"
+	if quota > 0 {
+		s = humanize.Bytes(uint64(quota))
+	}
+	if isDefault {
+		s += " (default)"
+	}
+	return s
+}
+
+func inviteExpiry(inv *charm.Invite) string {
+	if inv.ExpiresAt == nil {
+		return "never expires"
+	}
+	if inv.UsedAt == nil && inv.ExpiresAt.Before(time.Now()) {
+		return "expired " + formatTime(inv.ExpiresAt)
+	}
+	return "expires " + formatTime(inv.ExpiresAt)
+}
+
+func inviteStatus(inv *charm.Invite) string {
+	if inv.UsedAt == nil {
+		return "unused"
+	}
+	return fmt.Sprintf("used %s by %s", formatTime(inv.UsedAt), inv.UsedBy)
+}
+
+func keyFingerprint(k *charm.PublicKey) string {
+	fp, err := client.FingerprintSHA256(*k)
+	if err != nil {
+		return k.Key
+	}
+	return fp.Type + ":" + fp.Value
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func init() {
+	serveAdminUsersCmd.Flags().IntVarP(&adminPage, "page", "p", 1, "page of results to show")
+	serveAdminDeleteCmd.Flags().BoolVarP(&adminForce, "force", "f", false, "confirm deleting the account")
+	serveAdminRevokeKeyCmd.Flags().BoolVarP(&adminForce, "force", "f", false, "allow revoking the last key, deleting the account")
+	serveAdminInviteCreateCmd.Flags().DurationVarP(&adminInviteExpires, "expires", "e", 0, "how long the codes are valid, they never expire by default")
+	serveAdminInviteCreateCmd.Flags().IntVarP(&adminInviteCount, "count", "n", 1, "number of codes to create")
+	serveAdminQuotaCmd.AddCommand(
+		serveAdminQuotaSetCmd,
+		serveAdminQuotaResetCmd,
+	)
+	serveAdminInviteCmd.AddCommand(
+		serveAdminInviteCreateCmd,
+		serveAdminInviteListCmd,
+		serveAdminInviteRemoveCmd,
+	)
+	ServeAdminCmd.AddCommand(
+		serveAdminUsersCmd,
+		serveAdminUserCmd,
+		serveAdminSuspendCmd,
+		serveAdminUnsuspendCmd,
+		serveAdminDeleteCmd,
+		serveAdminRevokeKeyCmd,
+		serveAdminQuotaCmd,
+		serveAdminInviteCmd,
+	)
+}
@@ -1,55 +1,179 @@
 package cmd
 
 import (
-	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"text/tabwriter"
 
 	"github.com/charmbracelet/log"
 
 	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/server/db"
+	"github.com/charmbracelet/charm/server/db/migrate"
 	"github.com/charmbracelet/charm/server/db/sqlite"
-	"github.com/charmbracelet/charm/server/db/sqlite/migration"
 	"github.com/spf13/cobra"
-
-	_ "modernc.org/sqlite" // sqlite driver
 )
 
-// ServeMigrationCmd migrate server db.
-var ServeMigrationCmd = &cobra.Command{
-	Use:     "migrate",
-	Aliases: []string{"migration"},
-	Hidden:  true,
-	Short:   "Run the server migration tool.",
-	Long:    paragraph("Run the server migration tool to migrate the database."),
-	Args:    cobra.NoArgs,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg := server.DefaultConfig()
-		dp := filepath.Join(cfg.DataDir, "db", sqlite.DbName)
-		_, err := os.Stat(dp)
-		if err != nil {
-			return fmt.Errorf("database does not exist: %s", err)
-		}
-		db := sqlite.NewDB(dp)
-		for _, m := range []migration.Migration{
-			migration.Migration0001,
-		} {
-			log.Print("Running migration", "id", fmt.Sprintf("%04d", m.ID), "name", m.Name)
-			err = db.WrapTransaction(func(tx *sql.Tx) error {
-				_, err := tx.Exec(m.SQL)
-				if err != nil {
-					return err
-				}
+var (
+	migrateDryRun bool
+	migrateSteps  int
+
+	// ServeMigrationCmd migrate server db.
+	ServeMigrationCmd = &cobra.Command{
+		Use:     "migrate",
+		Aliases: []string{"migration"},
+		Short:   "Run the server migration tool.",
+		Long:    paragraph("Run the server migration tool to apply pending database migrations. The server won’t start until the database is up to date."),
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, closer, err := openMigrator()
+			if err != nil {
+				return err
+			}
+			defer closer() // nolint:errcheck
+			pending, err := m.Pending()
+			if err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				fmt.Println("Database is up to date.")
+				return nil
+			}
+			if migrateDryRun {
+				printMigrations(pending, false)
 				return nil
-			})
+			}
+			for _, mi := range pending {
+				log.Print("Running migration", "id", fmt.Sprintf("%04d", mi.ID), "name", mi.Name)
+			}
+			_, err = m.Up()
+			return err
+		},
+	}
+
+	serveMigrationStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Print the status of the database migrations.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, closer, err := openMigrator()
 			if err != nil {
-				break
+				return err
 			}
-		}
-		if err != nil {
+			defer closer() // nolint:errcheck
+			ss, err := m.Status()
+			if err != nil {
+				return err
+			}
+			v, err := m.Version()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			for _, s := range ss {
+				applied := "pending"
+				if s.Applied {
+					applied = "applied"
+					if s.AppliedAt != nil {
+						applied += " " + s.AppliedAt.Format("2006-01-02 15:04:05")
+					}
+				}
+				fmt.Fprintf(w, "%04d\t%s\t%s\n", s.ID, s.Name, applied)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Printf("\nDatabase is at version %04d of %04d.\n", v, m.Latest())
+			return nil
+		},
+	}
+
+	serveMigrationDownCmd = &cobra.Command{
+		Use:   "down",
+		Short: "Revert the most recently applied database migrations.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, closer, err := openMigrator()
+			if err != nil {
+				return err
+			}
+			defer closer() // nolint:errcheck
+			ms, err := m.Reversible(migrateSteps)
+			if err != nil {
+				return err
+			}
+			if len(ms) == 0 {
+				fmt.Println("No migrations to revert.")
+				return nil
+			}
+			if migrateDryRun {
+				printMigrations(ms, true)
+				return nil
+			}
+			for _, mi := range ms {
+				log.Print("Reverting migration", "id", fmt.Sprintf("%04d", mi.ID), "name", mi.Name)
+			}
+			_, err = m.Down(migrateSteps)
 			return err
+		},
+	}
+)
+
+// openServerDB opens the existing database of the server configured through
+// the environment and the serve flags.
+func openServerDB() (*server.Config, db.DB, error) {
+	cfg := server.DefaultConfig()
+	if serverDataDir != "" {
+		cfg.DataDir = serverDataDir
+	}
+	if serverDBDSN != "" {
+		cfg.DBDSN = serverDBDSN
+	}
+	if cfg.DBDSN == "" {
+		dp := filepath.Join(cfg.DataDir, "db", sqlite.DbName)
+		if _, err := os.Stat(dp); err != nil {
+			return nil, nil, fmt.Errorf("database does not exist: %s", err)
 		}
-		return nil
-	},
+	}
+	d, err := server.OpenDB(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg.WithDB(d), d, nil
+}
+
+func openMigrator() (*migrate.Migrator, func() error, error) {
+	_, d, err := openServerDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	md, ok := d.(db.MigratableDB)
+	if !ok {
+		_ = d.Close()
+		return nil, nil, fmt.Errorf("database doesn't support migrations")
+	}
+	return md.Migrator(), d.Close, nil
+}
+
+func printMigrations(ms []migrate.Migration, down bool) {
+	for _, mi := range ms {
+		sql := mi.SQL
+		if down {
+			sql = mi.Down
+			if sql == "" {
+				sql = "-- irreversible\n"
+			}
+		}
+		fmt.Printf("-- %04d %s\n%s\n", mi.ID, mi.Name, sql)
+	}
+}
+
+func init() {
+	ServeMigrationCmd.PersistentFlags().BoolVar(&migrateDryRun, "dry-run", false, "print the SQL to be run without applying it")
+	serveMigrationDownCmd.Flags().IntVar(&migrateSteps, "steps", 1, "number of migrations to revert")
+	ServeMigrationCmd.AddCommand(
+		serveMigrationStatusCmd,
+		serveMigrationDownCmd,
+	)
 }
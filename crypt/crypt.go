@@ -2,14 +2,18 @@
 package crypt
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"sync"
 
 	"github.com/charmbracelet/charm/client"
 	charm "github.com/charmbracelet/charm/proto"
 	"github.com/jacobsa/crypto/siv"
 	"github.com/muesli/sasquatch"
+	"github.com/muesli/sasquatch/stream"
+	"golang.org/x/crypto/poly1305"
 )
 
 // ErrIncorrectEncryptKeys is returned when the encrypt keys are missing or
@@ -20,6 +24,10 @@ var ErrIncorrectEncryptKeys = fmt.Errorf("incorrect or missing encrypt keys")
 // decrypting.
 type Crypt struct {
 	keys []*charm.EncryptKey
+
+	headerOnce sync.Once
+	headerSize int64
+	headerErr  error
 }
 
 // EncryptedWriter is an io.WriteCloser. All data written to this writer is
@@ -28,6 +36,13 @@ type EncryptedWriter struct {
 	w io.WriteCloser
 }
 
+// EncryptedReader is an io.ReadCloser that reads the encrypted data of an
+// underlying io.Reader. The size of the encrypted data is known up front.
+type EncryptedReader struct {
+	pr   *io.PipeReader
+	size int64
+}
+
 // DecryptedReader is an io.Reader that decrypts data from an encrypted
 // underlying io.Reader.
 type DecryptedReader struct {
@@ -89,6 +104,86 @@ func (cr *Crypt) NewEncryptedWriter(w io.Writer) (*EncryptedWriter, error) {
 	return ew, nil
 }
 
+// NewEncryptedReader creates a new Reader that encrypts the data read from r,
+// which must be exactly size bytes long. The data is encrypted as it's read,
+// so only a small, fixed amount of it is held in memory.
+func (cr *Crypt) NewEncryptedReader(r io.Reader, size int64) (*EncryptedReader, error) {
+	// The header is written as soon as the encrypted writer is created. Buffer
+	// it to learn its size, then send everything else straight to the pipe.
+	hdr := &bytes.Buffer{}
+	sw := &switchWriter{w: hdr}
+	ew, err := cr.NewEncryptedWriter(sw)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		if _, err := pw.Write(hdr.Bytes()); err != nil {
+			return
+		}
+		sw.w = pw
+		n, err := io.Copy(ew, io.LimitReader(r, size+1))
+		if err == nil && n != size {
+			err = fmt.Errorf("expected %d bytes to encrypt, got %d", size, n)
+		}
+		if err == nil {
+			err = ew.Close()
+		}
+		pw.CloseWithError(err) // nolint:errcheck
+	}()
+	return &EncryptedReader{pr: pr, size: int64(hdr.Len()) + encryptedPayloadSize(size)}, nil
+}
+
+// encryptedPayloadSize returns the size of size bytes of data once encrypted,
+// not counting the header. Data is encrypted in chunks, each of which carries
+// an authentication tag. Empty data is still encrypted as one empty chunk.
+func encryptedPayloadSize(size int64) int64 {
+	chunks := (size + stream.ChunkSize - 1) / stream.ChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return size + chunks*poly1305.TagSize
+}
+
+// EncryptedSize returns the size of size bytes of data once they're encrypted,
+// header included. It's how big a file is when it's stored on the server.
+func (cr *Crypt) EncryptedSize(size int64) (int64, error) {
+	hs, err := cr.headerLen()
+	if err != nil {
+		return 0, err
+	}
+	return hs + encryptedPayloadSize(size), nil
+}
+
+// DecryptedSize returns the size of the data that was encrypted into size
+// bytes. It's how big a stored file is once it's downloaded and decrypted.
+func (cr *Crypt) DecryptedSize(size int64) (int64, error) {
+	hs, err := cr.headerLen()
+	if err != nil {
+		return 0, err
+	}
+	payload := size - hs
+	chunks := (payload + stream.ChunkSize + poly1305.TagSize - 1) / (stream.ChunkSize + poly1305.TagSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+	if payload < chunks*poly1305.TagSize {
+		return 0, fmt.Errorf("%d bytes is too small to be encrypted data", size)
+	}
+	return payload - chunks*poly1305.TagSize, nil
+}
+
+// headerLen returns the size of the header of encrypted data.
+func (cr *Crypt) headerLen() (int64, error) {
+	// Every header is the same size, so learn it by encrypting nothing once.
+	cr.headerOnce.Do(func() {
+		hdr := &bytes.Buffer{}
+		_, cr.headerErr = cr.NewEncryptedWriter(hdr)
+		cr.headerSize = int64(hdr.Len())
+	})
+	return cr.headerSize, cr.headerErr
+}
+
 // Keys returns the EncryptKeys this Crypt is using.
 func (cr *Crypt) Keys() []*charm.EncryptKey {
 	return cr.keys
@@ -137,6 +232,22 @@ func (dr *DecryptedReader) Read(p []byte) (int, error) {
 	return dr.r.Read(p)
 }
 
+// Read reads encrypted data.
+func (er *EncryptedReader) Read(p []byte) (int, error) {
+	return er.pr.Read(p)
+}
+
+// Size returns the total size of the encrypted data.
+func (er *EncryptedReader) Size() int64 {
+	return er.size
+}
+
+// Close stops encrypting. Reading from the underlying io.Reader stops with
+// the next write of encrypted data.
+func (er *EncryptedReader) Close() error {
+	return er.pr.Close()
+}
+
 // Write encrypts data and writes it to the underlying io.WriteCloser.
 func (ew *EncryptedWriter) Write(p []byte) (int, error) {
 	return ew.w.Write(p)
@@ -146,3 +257,12 @@ func (ew *EncryptedWriter) Write(p []byte) (int, error) {
 func (ew *EncryptedWriter) Close() error {
 	return ew.w.Close()
 }
+
+// switchWriter is an io.Writer whose underlying io.Writer can be swapped.
+type switchWriter struct {
+	w io.Writer
+}
+
+func (sw *switchWriter) Write(p []byte) (int, error) {
+	return sw.w.Write(p)
+}
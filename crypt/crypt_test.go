@@ -0,0 +1,55 @@
+package crypt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+func TestEncryptedReader(t *testing.T) {
+	cr := &Crypt{keys: []*charm.EncryptKey{{Key: "0123456789abcdef0123456789abcdef"}}}
+	for _, size := range []int{0, 1, 64 * 1024, 64*1024 + 1, 3 * 64 * 1024} {
+		data := bytes.Repeat([]byte("x"), size)
+		er, err := cr.NewEncryptedReader(bytes.NewReader(data), int64(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, err := io.ReadAll(er)
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if int64(len(ct)) != er.Size() {
+			t.Fatalf("size %d: expected %d bytes of ciphertext, got %d", size, er.Size(), len(ct))
+		}
+		if es, err := cr.EncryptedSize(int64(size)); err != nil || es != er.Size() {
+			t.Fatalf("size %d: expected an encrypted size of %d, got %d (%v)", size, er.Size(), es, err)
+		}
+		if ds, err := cr.DecryptedSize(er.Size()); err != nil || ds != int64(size) {
+			t.Fatalf("size %d: expected a decrypted size of %d, got %d (%v)", size, size, ds, err)
+		}
+		dr, err := cr.NewDecryptedReader(bytes.NewReader(ct))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := io.ReadAll(dr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pt, data) {
+			t.Fatalf("size %d: decrypted data doesn't match", size)
+		}
+	}
+
+	// Data that doesn't match the expected size is an error.
+	for _, size := range []int64{4, 6} {
+		er, err := cr.NewEncryptedReader(bytes.NewReader([]byte("hello")), size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(er); err == nil {
+			t.Fatalf("expected an error encrypting 5 bytes as %d", size)
+		}
+	}
+}
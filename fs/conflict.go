@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+)
+
+// errPreconditionFailed is returned by writes the server refused because the
+// file didn't match the conditional headers.
+var errPreconditionFailed = errors.New("precondition failed")
+
+// ConflictError is returned by WriteFileIf when the file on the server isn't
+// the version the write expected, usually because someone else wrote it in
+// the meantime.
+type ConflictError struct {
+	Path string
+	// ETag is the version the write expected, empty if it expected there to
+	// be no file.
+	ETag string
+}
+
+// Error implements error.
+func (e *ConflictError) Error() string {
+	if e.ETag == "" {
+		return fmt.Sprintf("%s: file already exists", e.Path)
+	}
+	return fmt.Sprintf("%s: file has changed since version %s", e.Path, e.ETag)
+}
+
+// WriteFileIf is a compare-and-swap WriteFile. The file is only written if
+// the file on the server is still the version with the given ETag, as found
+// in its FileInfo. An empty etag only writes the file if it doesn't exist. It
+// returns the ETag of the version it wrote, or a *ConflictError if the file
+// on the server is a different version.
+func (cfs *FS) WriteFileIf(name string, src fs.File, etag string) (string, error) {
+	cond := http.Header{}
+	if etag == "" {
+		cond.Set("If-None-Match", "*")
+	} else {
+		cond.Set("If-Match", etag)
+	}
+	newETag, err := cfs.writeFile(name, src, cond)
+	if errors.Is(err, errPreconditionFailed) {
+		return "", &ConflictError{Path: name, ETag: etag}
+	}
+	return newETag, err
+}
@@ -0,0 +1,44 @@
+package fs
+
+import "io/fs"
+
+// SetChunkedUploadSize sets the size above which files are uploaded in chunks
+// and returns a func restoring the previous size.
+func SetChunkedUploadSize(size int64) func() {
+	old := chunkedUploadSize
+	chunkedUploadSize = size
+	return func() { chunkedUploadSize = old }
+}
+
+// StartUpload starts a chunked upload of src to name and sends its first n
+// chunks without committing it, like an upload that was interrupted by a
+// restart. It returns the ID of the upload session.
+func (cfs *FS) StartUpload(name string, src fs.File, n int) (string, error) {
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return "", err
+	}
+	info, err := src.Stat()
+	if err != nil {
+		return "", err
+	}
+	pu, err := cfs.newPendingUpload(ep, info)
+	if err != nil {
+		return "", err
+	}
+	us, data, err := cfs.startUpload(pu, src)
+	if err != nil {
+		return "", err
+	}
+	defer data.Close() // nolint:errcheck
+	buf := make([]byte, us.ChunkSize)
+	for i := 0; i < n; i++ {
+		if _, err := data.ReadAt(buf, int64(i)*us.ChunkSize); err != nil {
+			return "", err
+		}
+		if err := cfs.putChunk(us, i, buf); err != nil {
+			return "", err
+		}
+	}
+	return us.ID, nil
+}
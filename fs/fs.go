@@ -9,21 +9,21 @@ import (
 	"io/fs"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/charmbracelet/log"
-
 	"github.com/charmbracelet/charm/client"
 	"github.com/charmbracelet/charm/crypt"
 	charm "github.com/charmbracelet/charm/proto"
 )
 
-// FS is an implementation of fs.FS, fs.ReadFileFS and fs.ReadDirFS with
-// additional write methods. Data is stored across the network on a Charm Cloud
-// server, with encryption and decryption happening client-side.
+// FS is an implementation of fs.FS, fs.ReadFileFS, fs.ReadDirFS, fs.StatFS,
+// fs.SubFS and fs.GlobFS with additional write methods. Data is stored across
+// the network on a Charm Cloud server, with encryption and decryption
+// happening client-side.
 type FS struct {
 	cc    *client.Client
 	crypt *crypt.Crypt
@@ -131,6 +131,12 @@ func (cfs *FS) Open(name string) (fs.File, error) {
 				sys:      sf,
 			}
 			dei.FileInfo.Name = dn
+			// The server only knows how big the encrypted file is.
+			if !de.IsDir {
+				if ds, err := cfs.crypt.DecryptedSize(de.Size); err == nil {
+					dei.FileInfo.Size = ds
+				}
+			}
 			des = append(des, &dei)
 		}
 		f.info.sys = des
@@ -142,7 +148,12 @@ func (cfs *FS) Open(name string) (fs.File, error) {
 		}
 		f.info.FileInfo.Mode = fs.FileMode(m)
 		b := bytes.NewBuffer(nil)
-		dec, err := cfs.crypt.NewDecryptedReader(resp.Body)
+		// Resume the download if the connection drops halfway.
+		rr := newResumeReader(resp, func(h http.Header) (*http.Response, error) {
+			return cfs.cc.AuthedRequest("GET", p, h, nil)
+		})
+		defer rr.Close() // nolint:errcheck
+		dec, err := cfs.crypt.NewDecryptedReader(rr)
 		if err != nil {
 			return nil, pathError(name, err)
 		}
@@ -150,13 +161,18 @@ func (cfs *FS) Open(name string) (fs.File, error) {
 		if err != nil {
 			return nil, err
 		}
-		modTime, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+		// Older servers only send the Last-Modified time, to the second.
+		modTime, err := time.Parse(time.RFC3339Nano, resp.Header.Get("X-File-Mtime"))
+		if err != nil {
+			modTime, err = time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+		}
 		if err != nil {
 			return nil, pathError(name, err)
 		}
 		f.data = io.NopCloser(b)
 		f.info.FileInfo.Size = int64(b.Len())
 		f.info.FileInfo.ModTime = modTime
+		f.info.FileInfo.ETag = resp.Header.Get("ETag")
 		f.info.FileInfo.IsDir = false
 	default:
 		return nil, pathError(name, fmt.Errorf("invalid content-type returned from server"))
@@ -179,26 +195,60 @@ func (cfs *FS) ReadFile(name string) ([]byte, error) {
 }
 
 // WriteFile encrypts data from the src io.Reader and stores it on the
-// configured Charm Cloud server. The fs.FileMode is retained. If the file is
-// in a directory that doesn't exist, it and any needed subdirectories are
-// created.
+// configured Charm Cloud server. The fs.FileMode and modification time are
+// retained. If the file is in a directory that doesn't exist, it and any
+// needed subdirectories are created. Data is encrypted while it's uploaded,
+// so memory use doesn't grow with the size of the file. The size src reports
+// is only relied on for regular files that can be read again from the start
+// with Seek, other files are copied to a temporary file first.
 func (cfs *FS) WriteFile(name string, src fs.File) error {
+	_, err := cfs.writeFile(name, src, nil)
+	return err
+}
+
+// writeFile uploads the file, sending the conditional headers along with the
+// request that replaces the file on the server. It returns the ETag the server
+// gave the new version of the file.
+func (cfs *FS) writeFile(name string, src fs.File, cond http.Header) (string, error) {
 	info, err := src.Stat()
 	if err != nil {
-		return err
+		return "", err
+	}
+	rs, ok := src.(io.Seeker)
+	if !ok || !info.Mode().IsRegular() {
+		return cfs.writeSpooled(name, src, info, cond)
+	}
+	sr := &sizeReader{r: src, size: info.Size()}
+	etag, err := cfs.writeSized(name, sr, info, cond)
+	if err != nil && sr.changed() {
+		// The file changed size while it was read, start over with a copy
+		// that stays put.
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		return cfs.writeSpooled(name, src, info, cond)
 	}
-	ebuf := bytes.NewBuffer(nil)
-	eb, err := cfs.crypt.NewEncryptedWriter(ebuf)
+	return etag, err
+}
+
+// writeSized uploads info.Size() bytes of data read from src.
+func (cfs *FS) writeSized(name string, src io.Reader, info fs.FileInfo, cond http.Header) (string, error) {
+	ep, err := cfs.EncryptPath(name)
 	if err != nil {
-		return err
+		return "", err
 	}
-	if _, err := io.Copy(eb, src); err != nil {
-		return err
+	size, err := cfs.crypt.EncryptedSize(info.Size())
+	if err != nil {
+		return "", err
 	}
-	if err := eb.Close(); err != nil {
-		return err
+	if size > chunkedUploadSize {
+		return cfs.writeChunked(ep, src, info, cond)
 	}
-	eb.Close() //nolint:errcheck
+	er, err := cfs.crypt.NewEncryptedReader(src, info.Size())
+	if err != nil {
+		return "", err
+	}
+	defer er.Close() // nolint:errcheck
 	// To calculate the Content Length of a multipart request, we need to split
 	// the multipart into header, data body, and boundary footer and then
 	// calculate the length of each.
@@ -207,63 +257,60 @@ func (cfs *FS) WriteFile(name string, src fs.File) error {
 	databuf := bytes.NewBuffer(nil)
 	w := multipart.NewWriter(databuf)
 	if _, err := w.CreateFormFile("data", name); err != nil {
-		return err
+		return "", err
 	}
-	headlen := databuf.Len()
-	header := make([]byte, headlen)
+	header := make([]byte, databuf.Len())
 	if _, err := databuf.Read(header); err != nil {
-		return err
+		return "", err
 	}
 	if err := w.Close(); err != nil {
-		return err
+		return "", err
 	}
-	w.Close() //nolint:errcheck
-	bounlen := databuf.Len()
-	boun := make([]byte, bounlen)
+	boun := make([]byte, databuf.Len())
 	if _, err := databuf.Read(boun); err != nil {
-		return err
+		return "", err
 	}
-	// headlen is the length of the multipart part header, bounlen is the length of the multipart boundary footer.
-	contentLength := int64(headlen) + int64(ebuf.Len()) + int64(bounlen)
-	// pipe the multipart request to the server
-	rr, rw := io.Pipe()
-	defer rr.Close() // nolint:errcheck
-	go func() {
-		defer rw.Close() // nolint:errcheck
-
-		// write multipart header
-		if _, err := rw.Write(header); err != nil {
-			log.Error("WriteFile", "name", name, "err", err)
-			return
-		}
-		// chunk the read data into 64MB chunks
-		buf := make([]byte, 1024*1024*64)
-		for {
-			n, err := ebuf.Read(buf)
-			if err != nil {
-				break
-			}
-			if _, err := rw.Write(buf[:n]); err != nil {
-				log.Error("WriteFile", "name", name, "err", err)
-				return
-			}
-		}
-		// write multipart boundary
-		if _, err := rw.Write(boun); err != nil {
-			log.Error("WriteFile", "name", name, "err", err)
-			return
-		}
-	}()
+	contentLength := int64(len(header)) + er.Size() + int64(len(boun))
+	body := io.MultiReader(bytes.NewReader(header), er, bytes.NewReader(boun))
+	path := fmt.Sprintf("/v1/fs/%s?mode=%d%s", ep, info.Mode(), mtimeParam("&", info.ModTime()))
+	headers := http.Header{
+		"Content-Type":   []string{w.FormDataContentType()},
+		"Content-Length": []string{fmt.Sprintf("%d", contentLength)},
+	}
+	for k, v := range cond {
+		headers[k] = v
+	}
+	resp, err := cfs.cc.AuthedRequest("POST", path, headers, body)
+	if resp != nil && resp.StatusCode == http.StatusPreconditionFailed {
+		resp.Body.Close() // nolint:errcheck
+		return "", errPreconditionFailed
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), resp.Body.Close()
+}
+
+// Mkdir creates a directory on the Charm Cloud server, along with any missing
+// parents. It's not an error if the directory already exists.
+func (cfs *FS) Mkdir(name string, perm fs.FileMode) error {
+	return cfs.MkdirModTime(name, perm, time.Time{})
+}
+
+// MkdirModTime is like Mkdir, but also sets the modification time of the
+// directory, whether or not it already existed. Writing files to a directory
+// changes its modification time, so a copied tree keeps the times of its
+// directories by setting them again after the files are written.
+func (cfs *FS) MkdirModTime(name string, perm fs.FileMode, mtime time.Time) error {
 	ep, err := cfs.EncryptPath(name)
 	if err != nil {
 		return err
 	}
-	path := fmt.Sprintf("/v1/fs/%s?mode=%d", ep, info.Mode())
-	headers := http.Header{
-		"Content-Type":   []string{w.FormDataContentType()},
-		"Content-Length": []string{fmt.Sprintf("%d", contentLength)},
+	path := fmt.Sprintf("/v1/fs/%s?mode=%d%s", ep, perm.Perm()|fs.ModeDir, mtimeParam("&", mtime))
+	resp, err := cfs.cc.AuthedRequest("MKCOL", path, nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
 	}
-	resp, err := cfs.cc.AuthedRequest("POST", path, headers, rr)
 	if err != nil {
 		return err
 	}
@@ -284,6 +331,47 @@ func (cfs *FS) Remove(name string) error {
 	return resp.Body.Close()
 }
 
+// Rename moves a file or directory to a new path on the Charm Cloud server
+// without downloading it. It returns fs.ErrExist if there's already a file at
+// the new path.
+func (cfs *FS) Rename(oldpath string, newpath string) error {
+	return cfs.rename(oldpath, newpath, false)
+}
+
+// Replace is like Rename, but replaces a file at the new path. The server
+// does that in one step, so no one sees the new path missing in between. It
+// still returns fs.ErrExist if there's a directory at the new path.
+func (cfs *FS) Replace(oldpath string, newpath string) error {
+	return cfs.rename(oldpath, newpath, true)
+}
+
+func (cfs *FS) rename(oldpath string, newpath string, overwrite bool) error {
+	oep, err := cfs.EncryptPath(oldpath)
+	if err != nil {
+		return err
+	}
+	nep, err := cfs.EncryptPath(newpath)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/v1/fs/%s", oep)
+	headers := http.Header{"Destination": []string{nep}}
+	if overwrite {
+		headers.Set("Overwrite", "T")
+	}
+	resp, err := cfs.cc.AuthedRequest("MOVE", path, headers, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return &fs.PathError{Op: "rename", Path: oldpath, Err: fs.ErrNotExist}
+	}
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrExist}
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
 // ReadDir reads the named directory and returns a list of directory entries.
 func (cfs *FS) ReadDir(name string) ([]fs.DirEntry, error) {
 	f, err := cfs.Open(name)
@@ -441,6 +529,15 @@ func (sf sysFuture) resolve() ([]fs.DirEntry, error) {
 	return sys.([]fs.DirEntry), nil
 }
 
+// mtimeParam returns the query parameter that keeps a file's modification
+// time when it's uploaded, prefixed with sep, or nothing for the zero time.
+func mtimeParam(sep string, mt time.Time) string {
+	if mt.IsZero() {
+		return ""
+	}
+	return sep + "mtime=" + url.QueryEscape(mt.UTC().Format(time.RFC3339Nano))
+}
+
 func pathError(path string, err error) *fs.PathError {
 	return &fs.PathError{
 		Op:   "open",
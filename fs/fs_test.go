@@ -0,0 +1,438 @@
+package fs_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	charmfs "github.com/charmbracelet/charm/fs"
+	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestWriteFile(t *testing.T) {
+	chunkSize := server.UploadChunkSize
+	t.Cleanup(func() { server.UploadChunkSize = chunkSize })
+	server.UploadChunkSize = 1000
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		size    int
+		chunked bool
+	}{
+		{"empty", 0, false},
+		{"small", 100, false},
+		{"chunked", 5000, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.chunked {
+				defer charmfs.SetChunkedUploadSize(1000)()
+			}
+			data := bytes.Repeat([]byte(tc.name), tc.size/len(tc.name)+1)[:tc.size]
+			lp := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(lp, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mtime := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+			if err := os.Chtimes(lp, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(lp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close() // nolint:errcheck
+			if err := cfs.WriteFile("/test/"+tc.name, f); err != nil {
+				t.Fatalf("write file error: %s", err)
+			}
+			b, err := cfs.ReadFile("/test/" + tc.name)
+			if err != nil {
+				t.Fatalf("read file error: %s", err)
+			}
+			if !bytes.Equal(b, data) {
+				t.Fatalf("expected %d bytes back, got %d", len(data), len(b))
+			}
+			fi, err := fs.Stat(cfs, "/test/"+tc.name)
+			if err != nil {
+				t.Fatalf("stat error: %s", err)
+			}
+			if !fi.ModTime().Equal(mtime) {
+				t.Fatalf("expected the modification time %s, got %s", mtime, fi.ModTime())
+			}
+			des, err := cfs.ReadDir("/test")
+			if err != nil {
+				t.Fatalf("read dir error: %s", err)
+			}
+			found := false
+			for _, de := range des {
+				if de.Name() != tc.name {
+					continue
+				}
+				found = true
+				di, err := de.Info()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if di.Size() != int64(tc.size) {
+					t.Fatalf("expected the listed size to be %d, got %d", tc.size, di.Size())
+				}
+			}
+			if !found {
+				t.Fatalf("expected %s in the directory listing", tc.name)
+			}
+		})
+	}
+}
+
+// sizedFile is a file that reports the wrong size.
+type sizedFile struct {
+	*os.File
+	size int64
+}
+
+type sizedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+// streamFile is a file that can't seek.
+type streamFile struct {
+	f    *os.File
+	size int64
+}
+
+func (sf *sizedFile) Stat() (fs.FileInfo, error) {
+	fi, err := sf.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &sizedInfo{fi, sf.size}, nil
+}
+
+func (si *sizedInfo) Size() int64 {
+	return si.size
+}
+
+func (sf *streamFile) Read(p []byte) (int, error) {
+	return sf.f.Read(p)
+}
+
+func (sf *streamFile) Close() error {
+	return sf.f.Close()
+}
+
+func (sf *streamFile) Stat() (fs.FileInfo, error) {
+	fi, err := sf.f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &sizedInfo{fi, sf.size}, nil
+}
+
+func TestWriteFileSize(t *testing.T) {
+	chunkSize := server.UploadChunkSize
+	t.Cleanup(func() { server.UploadChunkSize = chunkSize })
+	server.UploadChunkSize = 1000
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		size    int64
+		seek    bool
+		chunked bool
+	}{
+		{"stream", 10, false, false},
+		{"too-big", 500, true, false},
+		{"too-small", 10, true, false},
+		{"chunked-too-big", 5000, true, true},
+		{"chunked-too-small", 1500, true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.chunked {
+				defer charmfs.SetChunkedUploadSize(1000)()
+			}
+			size := 100
+			if tc.chunked {
+				size = 3000
+			}
+			data := bytes.Repeat([]byte("x"), size)
+			lp := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(lp, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(lp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close() // nolint:errcheck
+			var src fs.File = &streamFile{f, tc.size}
+			if tc.seek {
+				src = &sizedFile{f, tc.size}
+			}
+			if err := cfs.WriteFile("/size/"+tc.name, src); err != nil {
+				t.Fatalf("write file error: %s", err)
+			}
+			b, err := cfs.ReadFile("/size/" + tc.name)
+			if err != nil {
+				t.Fatalf("read file error: %s", err)
+			}
+			if !bytes.Equal(b, data) {
+				t.Fatalf("expected %d bytes back, got %d", len(data), len(b))
+			}
+		})
+	}
+}
+
+func TestResumeUpload(t *testing.T) {
+	chunkSize := server.UploadChunkSize
+	t.Cleanup(func() { server.UploadChunkSize = chunkSize })
+	server.UploadChunkSize = 1000
+	defer charmfs.SetChunkedUploadSize(1000)()
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("resume"), 1000)
+	lp := filepath.Join(t.TempDir(), "big")
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(content []byte, fn func(f *os.File) error) {
+		t.Helper()
+		if err := os.WriteFile(lp, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(lp, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close() // nolint:errcheck
+		if err := fn(f); err != nil {
+			t.Fatalf("upload error: %s", err)
+		}
+	}
+	var id string
+	write(data, func(f *os.File) error {
+		id, err = cfs.StartUpload("/big", f, 2)
+		return err
+	})
+
+	// A new FS, like after a restart, carries on with the same session and
+	// the data encrypted before. The file looks the same, so to show the
+	// upload isn't started over its content is different this time.
+	cfs, err = charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(bytes.Repeat([]byte("RESUME"), 1000), func(f *os.File) error {
+		return cfs.WriteFile("/big", f)
+	})
+	b, err := cfs.ReadFile("/big")
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatalf("expected %d bytes back, got %d", len(data), len(b))
+	}
+	resp, err := cl.AuthedRequest("GET", "/v1/uploads/"+id, nil, nil)
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the interrupted upload to be committed, got %v", err)
+	}
+	_ = resp.Body.Close()
+	dp, err := cl.DataPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if des, err := os.ReadDir(filepath.Join(dp, "uploads")); err != nil || len(des) != 0 {
+		t.Fatalf("expected no pending uploads to be left, got %v (%v)", des, err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/a/file", "/b"} {
+		lp := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(lp, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cfs.WriteFile(name, f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+
+	if err := cfs.Rename("/a", "/c/a"); err != nil {
+		t.Fatalf("rename error: %s", err)
+	}
+	b, err := cfs.ReadFile("/c/a/file")
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(b) != "/a/file" {
+		t.Fatalf("expected %q, got %q", "/a/file", b)
+	}
+	if err := cfs.Rename("/b", "/c/a/file"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist, got %v", err)
+	}
+	if err := cfs.Rename("/a", "/d"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	if err := cfs.Replace("/b", "/c/a/file"); err != nil {
+		t.Fatalf("replace error: %s", err)
+	}
+	b, err = cfs.ReadFile("/c/a/file")
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(b) != "/b" {
+		t.Fatalf("expected %q, got %q", "/b", b)
+	}
+	if _, err := fs.Stat(cfs, "/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected the old path to be gone, got %v", err)
+	}
+	if err := cfs.Replace("/c/a/file", "/c"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("expected fs.ErrExist replacing a directory, got %v", err)
+	}
+	if err := cfs.Replace("/b", "/c/a/file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+	if _, err := fs.Stat(cfs, "/c/a/file"); err != nil {
+		t.Fatalf("expected a failed replace to keep the file, got %v", err)
+	}
+}
+
+func TestWriteFileIf(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written string
+	write := func(content string, etag string) error {
+		lp := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(lp, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close() // nolint:errcheck
+		written, err = cfs.WriteFileIf("/file", f, etag)
+		return err
+	}
+	etag := func() string {
+		f, err := cfs.Open("/file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close() // nolint:errcheck
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.(*charmfs.FileInfo).ETag
+	}
+
+	if err := write("one", ""); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	if old := etag(); written != old {
+		t.Fatalf("expected the written ETag %q, got %q", old, written)
+	}
+	var ce *charmfs.ConflictError
+	if err := write("two", ""); !errors.As(err, &ce) || ce.ETag != "" {
+		t.Fatalf("expected a conflict creating an existing file, got %v", err)
+	}
+	old := etag()
+	if err := write("two", old); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	if err := write("three", old); !errors.As(err, &ce) || ce.ETag != old {
+		t.Fatalf("expected a conflict writing over a stale version, got %v", err)
+	}
+
+	defer charmfs.SetChunkedUploadSize(1)()
+	if err := write("three", old); !errors.As(err, &ce) {
+		t.Fatalf("expected a conflict committing a chunked upload, got %v", err)
+	}
+	if err := write("three", etag()); err != nil {
+		t.Fatalf("chunked write error: %s", err)
+	}
+	if cur := etag(); written != cur {
+		t.Fatalf("expected the ETag %q of the chunked write, got %q", cur, written)
+	}
+	b, err := cfs.ReadFile("/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "three" {
+		t.Fatalf("expected %q, got %q", "three", b)
+	}
+}
+
+func TestMkdir(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfs.Mkdir("/a/b", 0o750); err != nil {
+		t.Fatalf("mkdir error: %s", err)
+	}
+	if err := cfs.Mkdir("/a/b", 0o750); err != nil {
+		t.Fatalf("mkdir of an existing directory error: %s", err)
+	}
+	fi, err := fs.Stat(cfs, "/a/b")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if !fi.IsDir() {
+		t.Fatal("expected a directory")
+	}
+	des, err := cfs.ReadDir("/a")
+	if err != nil {
+		t.Fatalf("read dir error: %s", err)
+	}
+	if len(des) != 1 || des[0].Name() != "b" || !des[0].IsDir() {
+		t.Fatalf("expected the empty directory to be listed, got %v", des)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC)
+	if err := cfs.MkdirModTime("/a/b", 0o750, mtime); err != nil {
+		t.Fatalf("mkdir error: %s", err)
+	}
+	fi, err = fs.Stat(cfs, "/a/b")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Fatalf("expected the modification time %s, got %s", mtime, fi.ModTime())
+	}
+}
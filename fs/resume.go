@@ -0,0 +1,86 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxResumeAttempts is the number of times a download is resumed after the
+// connection fails.
+const maxResumeAttempts = 5
+
+// resumeDelay is the delay before the first resume attempt. It doubles with
+// every attempt.
+var resumeDelay = time.Second
+
+// errFileChanged is returned when a download can't be resumed because the
+// file changed on the server.
+var errFileChanged = errors.New("file changed on the server while downloading")
+
+// resumeReader reads a file from the server and resumes the download where it
+// left off when the connection fails. It resumes with a Range request, sending
+// the file's ETag in If-Range so a file that changed in the meantime isn't
+// stitched together from two versions.
+type resumeReader struct {
+	get  func(h http.Header) (*http.Response, error)
+	body io.ReadCloser
+	etag string
+	off  int64
+}
+
+func newResumeReader(resp *http.Response, get func(h http.Header) (*http.Response, error)) *resumeReader {
+	return &resumeReader{
+		get:  get,
+		body: resp.Body,
+		etag: resp.Header.Get("ETag"),
+	}
+}
+
+// Read implements io.Reader.
+func (r *resumeReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.off += int64(n)
+	if err == nil || err == io.EOF || r.etag == "" {
+		return n, err
+	}
+	if rerr := r.resume(err); rerr != nil {
+		return n, rerr
+	}
+	return n, nil
+}
+
+// Close implements io.Closer.
+func (r *resumeReader) Close() error {
+	return r.body.Close()
+}
+
+// resume requests the rest of the file, starting at the current offset.
+func (r *resumeReader) resume(cause error) error {
+	_ = r.body.Close()
+	delay := resumeDelay
+	for i := 0; i < maxResumeAttempts; i++ {
+		time.Sleep(delay)
+		delay *= 2
+		resp, err := r.get(http.Header{
+			"Range":    {fmt.Sprintf("bytes=%d-", r.off)},
+			"If-Range": {r.etag},
+		})
+		if err != nil {
+			if resp != nil {
+				_ = resp.Body.Close()
+			}
+			cause = err
+			continue
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			_ = resp.Body.Close()
+			return errFileChanged
+		}
+		r.body = resp.Body
+		return nil
+	}
+	return fmt.Errorf("could not resume download: %w", cause)
+}
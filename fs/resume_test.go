@@ -0,0 +1,73 @@
+package fs
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// flakyReader returns io.ErrUnexpectedEOF after n bytes, like a dropped
+// connection.
+type flakyReader struct {
+	r io.Reader
+	n int
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= n
+	return n, err
+}
+
+func TestResumeReader(t *testing.T) {
+	defer func(d time.Duration) { resumeDelay = d }(resumeDelay)
+	resumeDelay = time.Millisecond
+	data := bytes.Repeat([]byte("charm"), 1000)
+	etag := `"v1"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+	get := func(h http.Header) (*http.Response, error) {
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header = h
+		return http.DefaultClient.Do(req)
+	}
+	first := func() *http.Response {
+		resp := &http.Response{
+			Header: http.Header{},
+			Body:   io.NopCloser(&flakyReader{r: bytes.NewReader(data), n: 1234}),
+		}
+		resp.Header.Set("ETag", `"v1"`)
+		return resp
+	}
+
+	rr := newResumeReader(first(), get)
+	b, err := io.ReadAll(rr)
+	if err != nil {
+		t.Fatalf("expected download to resume, got %s", err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatalf("expected %d bytes, got %d", len(data), len(b))
+	}
+
+	// The file changed, the server ignores the range and the download fails.
+	etag = `"v2"`
+	rr = newResumeReader(first(), get)
+	if _, err := io.ReadAll(rr); err != errFileChanged {
+		t.Fatalf("expected errFileChanged, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package fs
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// spooledInfo is the fs.FileInfo of a file copied to a temporary file, with
+// the size of the copy.
+type spooledInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+// sizeReader counts the bytes read from r to tell whether it turned out to be
+// a different size than expected.
+type sizeReader struct {
+	r    io.Reader
+	size int64
+	n    int64
+	eof  bool
+}
+
+// writeSpooled copies src to a temporary file and uploads the copy. It's for
+// files whose size can't be relied on, so the size of the copy is uploaded
+// instead.
+func (cfs *FS) writeSpooled(name string, src io.Reader, info fs.FileInfo, cond http.Header) (string, error) {
+	tmp, err := os.CreateTemp("", "charm-upload-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	defer tmp.Close()           // nolint:errcheck
+	n, err := io.Copy(tmp, src)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return cfs.writeSized(name, tmp, &spooledInfo{FileInfo: info, size: n}, cond)
+}
+
+// Size returns the size of the copy.
+func (si *spooledInfo) Size() int64 {
+	return si.size
+}
+
+// Mode returns the permissions of the file. The copy is a regular file,
+// whatever the original was.
+func (si *spooledInfo) Mode() fs.FileMode {
+	return si.FileInfo.Mode() &^ fs.ModeType
+}
+
+func (sr *sizeReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	sr.n += int64(n)
+	if err == io.EOF {
+		sr.eof = true
+	}
+	return n, err
+}
+
+// changed reports whether more bytes than expected were read, or the end
+// came early.
+func (sr *sizeReader) changed() bool {
+	return sr.n > sr.size || (sr.eof && sr.n < sr.size)
+}
@@ -0,0 +1,63 @@
+package fs
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// Trash returns the deleted files and directories kept in the trash, most
+// recently deleted first. Servers only keep a trash when configured to.
+func (cfs *FS) Trash() ([]charm.TrashItem, error) {
+	var items []charm.TrashItem
+	if err := cfs.cc.AuthedJSONRequest("GET", "/v1/trash", nil, &items); err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		p, err := cfs.DecryptPath(item.Path)
+		if err != nil {
+			return nil, err
+		}
+		items[i].Path = p
+	}
+	return items, nil
+}
+
+// RestoreTrash moves an item in the trash back to where it was deleted from.
+// It returns fs.ErrExist if there's a file there now.
+func (cfs *FS) RestoreTrash(id int) error {
+	resp, err := cfs.cc.AuthedRequest("POST", fmt.Sprintf("/v1/trash/%d/restore", id), nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusConflict {
+		return fs.ErrExist
+	}
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return fs.ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// DeleteTrash deletes an item in the trash for good.
+func (cfs *FS) DeleteTrash(id int) error {
+	resp, err := cfs.cc.AuthedRequest("DELETE", fmt.Sprintf("/v1/trash/%d", id), nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return fs.ErrNotExist
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// EmptyTrash deletes everything in the trash for good.
+func (cfs *FS) EmptyTrash() error {
+	resp, err := cfs.cc.AuthedRequest("DELETE", "/v1/trash", nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
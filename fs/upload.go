@@ -0,0 +1,329 @@
+package fs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// chunkedUploadSize is the encrypted file size above which files are uploaded
+// in chunks rather than in a single request.
+var chunkedUploadSize int64 = 64 * 1024 * 1024 // 64MB
+
+// maxUploadAttempts is the number of times a chunk is sent before giving up on
+// the upload.
+const maxUploadAttempts = 5
+
+// pendingUploadMaxAge is how long an upload that was never committed is kept
+// in the data directory. The server deletes its upload sessions after a day
+// by default, so older uploads can't be resumed anyway.
+const pendingUploadMaxAge = 24 * time.Hour
+
+// pendingUpload is a chunked upload that hasn't been committed yet. It's
+// saved in the data directory along with the encrypted file being uploaded,
+// so an upload that's interrupted, even by a restart, carries on with the
+// chunks the server is missing the next time the same file is written.
+type pendingUpload struct {
+	ID      string      `json:"id"`
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+
+	base string
+}
+
+// uploadRejectedError is an error from the server that trying the upload
+// again won't fix, so the upload isn't kept around to be resumed.
+type uploadRejectedError struct {
+	err error
+}
+
+func (e *uploadRejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *uploadRejectedError) Unwrap() error {
+	return e.err
+}
+
+// writeChunked encrypts the data from src and uploads it in chunks to the
+// encrypted path ep. The encrypted data is written to the data directory
+// first, and one chunk of it is held in memory at a time. A chunk that fails
+// to upload is sent again. The modification time and conditional headers are
+// sent when committing the upload.
+func (cfs *FS) writeChunked(ep string, src io.Reader, info fs.FileInfo, cond http.Header) (string, error) {
+	pu, err := cfs.newPendingUpload(ep, info)
+	if err != nil {
+		return "", err
+	}
+	us, data, err := cfs.resumeUpload(pu)
+	if err != nil {
+		return "", err
+	}
+	if us == nil {
+		us, data, err = cfs.startUpload(pu, src)
+		if err != nil {
+			return "", err
+		}
+	}
+	etag, err := cfs.upload(us, data, info.ModTime(), cond)
+	data.Close() // nolint:errcheck
+	var re *uploadRejectedError
+	if errors.As(err, &re) {
+		cfs.abortUpload(us) // nolint:errcheck
+	}
+	if err == nil || re != nil {
+		pu.remove()
+	}
+	return etag, err
+}
+
+// newPendingUpload returns the pendingUpload for writing the file to the
+// encrypted path ep. It's kept in a file named after the path.
+func (cfs *FS) newPendingUpload(ep string, info fs.FileInfo) (*pendingUpload, error) {
+	dir, err := cfs.uploadsDir()
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte(ep))
+	return &pendingUpload{
+		Path:    ep,
+		Size:    info.Size(),
+		Mode:    info.Mode(),
+		ModTime: info.ModTime(),
+		base:    filepath.Join(dir, hex.EncodeToString(key[:8])),
+	}, nil
+}
+
+// resumeUpload returns the upload session and encrypted data of an earlier
+// upload of the same file, or a nil session when there's none to resume.
+func (cfs *FS) resumeUpload(pu *pendingUpload) (*charm.UploadSession, *os.File, error) {
+	b, err := os.ReadFile(pu.base + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var old pendingUpload
+	err = json.Unmarshal(b, &old)
+	if err != nil || old.Path != pu.Path || old.Size != pu.Size || old.Mode != pu.Mode || !old.ModTime.Equal(pu.ModTime) {
+		// The file changed since, start over.
+		if old.ID != "" {
+			cfs.abortUpload(&charm.UploadSession{ID: old.ID}) // nolint:errcheck
+		}
+		pu.remove()
+		return nil, nil, nil
+	}
+	us := &charm.UploadSession{}
+	resp, err := cfs.cc.AuthedRequest("GET", "/v1/uploads/"+old.ID, nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		// The session expired.
+		resp.Body.Close() // nolint:errcheck
+		pu.remove()
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	err = json.NewDecoder(resp.Body).Decode(us)
+	resp.Body.Close() // nolint:errcheck
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(pu.base + ".enc")
+	if err == nil {
+		var fi fs.FileInfo
+		fi, err = f.Stat()
+		if err == nil && fi.Size() != us.Size {
+			err = fmt.Errorf("expected %d bytes of encrypted data, got %d", us.Size, fi.Size())
+		}
+		if err != nil {
+			f.Close() // nolint:errcheck
+		}
+	}
+	if err != nil {
+		cfs.abortUpload(us) // nolint:errcheck
+		pu.remove()
+		return nil, nil, nil
+	}
+	pu.ID = us.ID
+	return us, f, nil
+}
+
+// startUpload encrypts the data from src to the data directory and creates
+// an upload session for it.
+func (cfs *FS) startUpload(pu *pendingUpload, src io.Reader) (*charm.UploadSession, *os.File, error) {
+	f, err := os.OpenFile(pu.base+".enc", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	us, err := cfs.createUpload(pu, f, src)
+	if err != nil {
+		f.Close() // nolint:errcheck
+		pu.remove()
+		return nil, nil, err
+	}
+	return us, f, nil
+}
+
+func (cfs *FS) createUpload(pu *pendingUpload, f *os.File, src io.Reader) (*charm.UploadSession, error) {
+	ew, err := cfs.crypt.NewEncryptedWriter(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(ew, src); err != nil {
+		return nil, err
+	}
+	if err := ew.Close(); err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	us := &charm.UploadSession{}
+	req := &charm.UploadSession{Path: pu.Path, Mode: pu.Mode, Size: fi.Size()}
+	if err := cfs.cc.AuthedJSONRequest("POST", "/v1/uploads", req, us); err != nil {
+		return nil, err
+	}
+	pu.ID = us.ID
+	if err := pu.save(); err != nil {
+		cfs.abortUpload(us) // nolint:errcheck
+		return nil, err
+	}
+	return us, nil
+}
+
+// upload sends the chunks of the upload session the server doesn't have yet,
+// reading them from data, and commits the upload. It returns the ETag of the
+// committed file.
+func (cfs *FS) upload(us *charm.UploadSession, data io.ReaderAt, mtime time.Time, cond http.Header) (string, error) {
+	received := make(map[int]bool, len(us.Chunks))
+	for _, n := range us.Chunks {
+		received[n] = true
+	}
+	buf := make([]byte, us.ChunkSize)
+	for n, off := 0, int64(0); n == 0 || off < us.Size; n, off = n+1, off+us.ChunkSize {
+		if received[n] {
+			continue
+		}
+		size := us.ChunkSize
+		if off+size > us.Size {
+			size = us.Size - off
+		}
+		if _, err := data.ReadAt(buf[:size], off); err != nil {
+			return "", err
+		}
+		if err := cfs.putChunk(us, n, buf[:size]); err != nil {
+			return "", err
+		}
+	}
+	path := fmt.Sprintf("/v1/uploads/%s/commit%s", us.ID, mtimeParam("?", mtime))
+	resp, err := cfs.cc.AuthedRequest("POST", path, cond, nil)
+	if resp != nil && resp.StatusCode == http.StatusPreconditionFailed {
+		resp.Body.Close() // nolint:errcheck
+		return "", &uploadRejectedError{errPreconditionFailed}
+	}
+	if resp != nil && err != nil && resp.StatusCode < http.StatusInternalServerError {
+		resp.Body.Close() // nolint:errcheck
+		return "", &uploadRejectedError{err}
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), resp.Body.Close()
+}
+
+// putChunk sends chunk n of the upload session, trying again with an
+// increasing delay when the connection or the server fails.
+func (cfs *FS) putChunk(us *charm.UploadSession, n int, data []byte) error {
+	headers := http.Header{
+		"Content-Type":   []string{"application/octet-stream"},
+		"Content-Length": []string{strconv.Itoa(len(data))},
+	}
+	path := fmt.Sprintf("/v1/uploads/%s/%d", us.ID, n)
+	delay := resumeDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := cfs.cc.AuthedRequest("PUT", path, headers, bytes.NewReader(data))
+		if resp != nil {
+			resp.Body.Close() // nolint:errcheck
+		}
+		if err == nil {
+			return nil
+		}
+		if resp != nil && resp.StatusCode < http.StatusInternalServerError {
+			return &uploadRejectedError{fmt.Errorf("cannot upload chunk %d: %w", n, err)}
+		}
+		if attempt == maxUploadAttempts {
+			return fmt.Errorf("cannot upload chunk %d: %w", n, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// abortUpload deletes the upload session and its chunks from the server.
+func (cfs *FS) abortUpload(us *charm.UploadSession) error {
+	resp, err := cfs.cc.AuthedRequest("DELETE", "/v1/uploads/"+us.ID, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// uploadsDir returns the directory pending uploads are kept in, deleting the
+// ones that are too old to be resumed.
+func (cfs *FS) uploadsDir() (string, error) {
+	dp, err := cfs.cc.DataPath()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(dp, "uploads")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, de := range des {
+		fi, err := de.Info()
+		if err == nil && time.Since(fi.ModTime()) > pendingUploadMaxAge {
+			os.Remove(filepath.Join(dir, de.Name())) // nolint:errcheck
+		}
+	}
+	return dir, nil
+}
+
+// save writes the upload to the data directory, next to its encrypted data.
+func (pu *pendingUpload) save() error {
+	b, err := json.Marshal(pu)
+	if err != nil {
+		return err
+	}
+	tmp := pu.base + ".json.tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, pu.base+".json")
+}
+
+// remove deletes the upload and its encrypted data from the data directory.
+func (pu *pendingUpload) remove() {
+	os.Remove(pu.base + ".json") // nolint:errcheck
+	os.Remove(pu.base + ".enc")  // nolint:errcheck
+}
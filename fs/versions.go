@@ -0,0 +1,87 @@
+package fs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// Versions returns the previous versions of a file kept by the server, newest
+// first. Servers only keep versions when configured to.
+func (cfs *FS) Versions(name string) ([]charm.FileVersion, error) {
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	var vs []charm.FileVersion
+	if err := cfs.cc.AuthedJSONRequest("GET", fmt.Sprintf("/v1/versions/%s", ep), nil, &vs); err != nil {
+		return nil, pathError(name, err)
+	}
+	return vs, nil
+}
+
+// OpenVersion opens a previous version of a file.
+func (cfs *FS) OpenVersion(name string, id string) (fs.File, error) {
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	p := fmt.Sprintf("/v1/versions/%s?id=%s", ep, url.QueryEscape(id))
+	resp, err := cfs.cc.AuthedRawRequest("GET", p)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, fs.ErrNotExist
+	} else if err != nil {
+		return nil, pathError(name, err)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	m, err := strconv.ParseUint(resp.Header.Get("X-File-Mode"), 10, 32)
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	modTime, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	dec, err := cfs.crypt.NewDecryptedReader(resp.Body)
+	if err != nil {
+		return nil, pathError(name, err)
+	}
+	b := bytes.NewBuffer(nil)
+	if _, err := io.Copy(b, dec); err != nil {
+		return nil, err
+	}
+	f := &File{
+		data: io.NopCloser(b),
+		info: &FileInfo{},
+	}
+	f.info.FileInfo.Name = path.Base(name)
+	f.info.FileInfo.Mode = fs.FileMode(m)
+	f.info.FileInfo.Size = int64(b.Len())
+	f.info.FileInfo.ModTime = modTime
+	return f, nil
+}
+
+// Restore replaces a file with one of its previous versions. The replaced
+// file is kept as a version.
+func (cfs *FS) Restore(name string, id string) error {
+	ep, err := cfs.EncryptPath(name)
+	if err != nil {
+		return pathError(name, err)
+	}
+	p := fmt.Sprintf("/v1/versions/%s?id=%s", ep, url.QueryEscape(id))
+	resp, err := cfs.cc.AuthedRequest("POST", p, nil, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return fs.ErrNotExist
+	} else if err != nil {
+		return pathError(name, err)
+	}
+	return resp.Body.Close()
+}
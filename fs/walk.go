@@ -0,0 +1,349 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// errNoRecursiveListing is returned by listAll when the server is too old to
+// list a directory recursively.
+var errNoRecursiveListing = errors.New("server can't list directories recursively")
+
+// subFS is the fs.FS for a directory of an FS, as returned by Sub.
+type subFS struct {
+	cfs *FS
+	dir string
+}
+
+// Stat returns the fs.FileInfo for the named file, implementing fs.StatFS.
+// It's looked up in its directory's listing, so the file isn't downloaded.
+func (cfs *FS) Stat(name string) (fs.FileInfo, error) {
+	p := cleanPath(name)
+	if p == "/" {
+		f, err := cfs.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() // nolint:errcheck
+		return f.Stat()
+	}
+	des, err := cfs.ReadDir(path.Dir(p))
+	if err != nil {
+		return nil, err
+	}
+	for _, de := range des {
+		if de.Name() == path.Base(p) {
+			return de.Info()
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// Sub returns an fs.FS for the files below dir, implementing fs.SubFS.
+func (cfs *FS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return cfs, nil
+	}
+	return &subFS{cfs: cfs, dir: dir}, nil
+}
+
+// Glob returns the names of the files matching pattern, implementing
+// fs.GlobFS. A pattern that reaches into subdirectories is matched against a
+// recursive listing, rather than listing each directory along the way.
+func (cfs *FS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	ps := strings.Split(pattern, "/")
+	i := 0
+	for i < len(ps) && !strings.ContainsAny(ps[i], `*?[\`) {
+		i++
+	}
+	if i == len(ps) {
+		if _, err := cfs.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+	dir := strings.Join(ps[:i], "/")
+	if dir == "" && i > 0 {
+		dir = "/"
+	}
+	matches := make([]string, 0)
+	if i == len(ps)-1 {
+		des, err := cfs.ReadDir(dir)
+		if err != nil {
+			return nil, nil
+		}
+		for _, de := range des {
+			if n := path.Join(dir, de.Name()); matchPath(pattern, n) {
+				matches = append(matches, n)
+			}
+		}
+		sort.Strings(matches)
+		return matches, nil
+	}
+	err := cfs.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && matchPath(pattern, p) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// WalkDir walks the file tree rooted at root, calling fn for each file and
+// directory in lexical order, the same as fs.WalkDir. Where fs.WalkDir lists
+// each directory with a request of its own, this lists the whole tree with
+// one request per page of files.
+func (cfs *FS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	info, err := cfs.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(root, fs.FileInfoToDirEntry(info), cfs.treeReader(root), fn)
+	}
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// treeReader returns a function that reads the directories below root. The
+// whole tree is listed the first time it's called, the listing answers the
+// calls after that. Servers that can't list a tree get a request for each
+// directory instead.
+func (cfs *FS) treeReader(root string) func(name string) ([]fs.DirEntry, error) {
+	var tree map[string][]fs.DirEntry
+	perDir := false
+	return func(name string) ([]fs.DirEntry, error) {
+		if perDir {
+			return cfs.ReadDir(name)
+		}
+		if tree != nil {
+			return tree[name], nil
+		}
+		fis, err := cfs.listAll(root)
+		if errors.Is(err, errNoRecursiveListing) {
+			perDir = true
+			return cfs.ReadDir(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		tree = make(map[string][]fs.DirEntry)
+		for _, fi := range fis {
+			dir := root
+			if d := path.Dir(fi.FileInfo.Name); d != "." {
+				dir = path.Join(root, d)
+			}
+			fi.FileInfo.Name = path.Base(fi.FileInfo.Name)
+			tree[dir] = append(tree[dir], fi)
+		}
+		for _, des := range tree {
+			sort.Slice(des, func(i, j int) bool { return des[i].Name() < des[j].Name() })
+		}
+		return tree[name], nil
+	}
+}
+
+// listAll lists everything below the directory name, at any depth, a page at
+// a time. The files are named by their path relative to the directory.
+func (cfs *FS) listAll(name string) ([]*FileInfo, error) {
+	ep, err := cfs.EncryptPath(cleanPath(name))
+	if err != nil {
+		return nil, err
+	}
+	fis := make([]*FileInfo, 0)
+	after := ""
+	for {
+		p := fmt.Sprintf("/v1/fs/%s?recursive=1&after=%s", ep, url.QueryEscape(after))
+		resp, err := cfs.cc.AuthedRequest("GET", p, nil, nil)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close() // nolint:errcheck
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Older servers ignore the recursive parameter and send the
+		// directory itself.
+		var l struct {
+			charm.FileListing
+			IsDir bool `json:"is_dir"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&l)
+		resp.Body.Close() // nolint:errcheck
+		if err != nil {
+			return nil, err
+		}
+		if l.IsDir {
+			return nil, errNoRecursiveListing
+		}
+		for _, fi := range l.Files {
+			dn, err := cfs.DecryptPath(fi.Name)
+			if err != nil {
+				return nil, err
+			}
+			fi.Name = dn
+			if !fi.IsDir {
+				if ds, err := cfs.crypt.DecryptedSize(fi.Size); err == nil {
+					fi.Size = ds
+				}
+			}
+			fis = append(fis, &FileInfo{FileInfo: fi})
+		}
+		if l.Next == "" {
+			return fis, nil
+		}
+		after = l.Next
+	}
+}
+
+// walkDir calls fn for name and everything below it, reading directories
+// with readDir. It's the algorithm fs.WalkDir uses.
+func walkDir(name string, d fs.DirEntry, readDir func(string) ([]fs.DirEntry, error), fn fs.WalkDirFunc) error {
+	if err := fn(name, d, nil); err != nil || !d.IsDir() {
+		if err == fs.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+	des, err := readDir(name)
+	if err != nil {
+		err = fn(name, d, err)
+		if err != nil {
+			if err == fs.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, de := range des {
+		if err := walkDir(path.Join(name, de.Name()), de, readDir, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanPath returns the path of a file from the root, starting with a slash.
+func cleanPath(name string) string {
+	return path.Clean("/" + strings.TrimPrefix(name, "charm:"))
+}
+
+// matchPath reports whether name matches the pattern, ignoring malformed
+// patterns, which Glob has checked already.
+func matchPath(pattern string, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func (sfs *subFS) fullName(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(sfs.dir, name), nil
+}
+
+func (sfs *subFS) shorten(name string) string {
+	if name == sfs.dir {
+		return "."
+	}
+	return strings.TrimPrefix(name, sfs.dir+"/")
+}
+
+// Open opens the named file.
+func (sfs *subFS) Open(name string) (fs.File, error) {
+	full, err := sfs.fullName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return sfs.cfs.Open(full)
+}
+
+// ReadFile reads the named file.
+func (sfs *subFS) ReadFile(name string) ([]byte, error) {
+	full, err := sfs.fullName("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return sfs.cfs.ReadFile(full)
+}
+
+// ReadDir reads the named directory.
+func (sfs *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := sfs.fullName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return sfs.cfs.ReadDir(full)
+}
+
+// Stat returns the fs.FileInfo for the named file.
+func (sfs *subFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := sfs.fullName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return sfs.cfs.Stat(full)
+}
+
+// Sub returns an fs.FS for the files below dir.
+func (sfs *subFS) Sub(dir string) (fs.FS, error) {
+	full, err := sfs.fullName("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "." {
+		return sfs, nil
+	}
+	return &subFS{cfs: sfs.cfs, dir: full}, nil
+}
+
+// Glob returns the names of the files matching pattern.
+func (sfs *subFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if pattern == "." {
+		return []string{"."}, nil
+	}
+	matches, err := sfs.cfs.Glob(path.Join(sfs.dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i, m := range matches {
+		matches[i] = sfs.shorten(m)
+	}
+	return matches, nil
+}
+
+// WalkDir walks the file tree rooted at root, see FS.WalkDir.
+func (sfs *subFS) WalkDir(root string, fn fs.WalkDirFunc) error {
+	full, err := sfs.fullName("walk", root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	return sfs.cfs.WalkDir(full, func(name string, d fs.DirEntry, err error) error {
+		return fn(sfs.shorten(name), d, err)
+	})
+}
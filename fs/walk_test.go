@@ -0,0 +1,128 @@
+package fs_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	charmfs "github.com/charmbracelet/charm/fs"
+	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestWalkDir(t *testing.T) {
+	pageSize := server.ListPageSize
+	t.Cleanup(func() { server.ListPageSize = pageSize })
+	server.ListPageSize = 2
+	cl := testserver.SetupTestServer(t)
+	cfs, err := charmfs.NewFSWithClient(cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/tree/a.txt", "/tree/d/b.txt", "/tree/d/e/c.md", "/tree/z.md"} {
+		lp := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(lp, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(lp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cfs.WriteFile(name, f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatalf("write file error: %s", err)
+		}
+	}
+	if err := cfs.Mkdir("/tree/empty", 0o700); err != nil {
+		t.Fatalf("mkdir error: %s", err)
+	}
+
+	walk := func(root string, skip string) []string {
+		names := make([]string, 0)
+		err := cfs.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			names = append(names, p)
+			if p == skip {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("walk error: %s", err)
+		}
+		return names
+	}
+	expected := []string{"/tree", "/tree/a.txt", "/tree/d", "/tree/d/b.txt", "/tree/d/e", "/tree/d/e/c.md", "/tree/empty", "/tree/z.md"}
+	if names := walk("/tree", ""); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+	expected = []string{"/tree", "/tree/a.txt", "/tree/d", "/tree/empty", "/tree/z.md"}
+	if names := walk("/tree", "/tree/d"); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v skipping /tree/d, got %v", expected, names)
+	}
+	err = cfs.WalkDir("/missing", func(p string, d fs.DirEntry, err error) error {
+		return err
+	})
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist walking a missing directory, got %v", err)
+	}
+
+	fi, err := cfs.Stat("/tree/d/b.txt")
+	if err != nil {
+		t.Fatalf("stat error: %s", err)
+	}
+	if fi.IsDir() || fi.Size() != int64(len("/tree/d/b.txt")) {
+		t.Fatalf("expected a file of %d bytes, got %d", len("/tree/d/b.txt"), fi.Size())
+	}
+	if _, err := cfs.Stat("/tree/d/x.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	for pattern, expected := range map[string][]string{
+		"/tree/*/*.txt":  {"/tree/d/b.txt"},
+		"/tree/*.md":     {"/tree/z.md"},
+		"/tree/d/*/*.md": {"/tree/d/e/c.md"},
+		"/tree/*/*/*":    {"/tree/d/e/c.md"},
+		"/tree/a.txt":    {"/tree/a.txt"},
+	} {
+		matches, err := cfs.Glob(pattern)
+		if err != nil {
+			t.Fatalf("glob %s error: %s", pattern, err)
+		}
+		if !reflect.DeepEqual(matches, expected) {
+			t.Fatalf("expected %v matching %s, got %v", expected, pattern, matches)
+		}
+	}
+	if _, err := cfs.Glob("/tree/["); !errors.Is(err, path.ErrBadPattern) {
+		t.Fatalf("expected a bad pattern error, got %v", err)
+	}
+
+	sub, err := fs.Sub(cfs, "tree/d")
+	if err != nil {
+		t.Fatalf("sub error: %s", err)
+	}
+	b, err := fs.ReadFile(sub, "b.txt")
+	if err != nil {
+		t.Fatalf("read file error: %s", err)
+	}
+	if string(b) != "/tree/d/b.txt" {
+		t.Fatalf("expected %q, got %q", "/tree/d/b.txt", b)
+	}
+	matches, err := fs.Glob(sub, "*/*")
+	if err != nil {
+		t.Fatalf("glob error: %s", err)
+	}
+	if expected := []string{"e/c.md"}; !reflect.DeepEqual(matches, expected) {
+		t.Fatalf("expected %v, got %v", expected, matches)
+	}
+	if fi, err := fs.Stat(sub, "e"); err != nil || !fi.IsDir() {
+		t.Fatalf("expected a directory, got %v, %v", fi, err)
+	}
+}
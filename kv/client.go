@@ -15,7 +15,7 @@ import (
 )
 
 type kvFile struct {
-	data *bytes.Buffer
+	data *bytes.Reader
 	info *kvFileInfo
 }
 
@@ -65,6 +65,10 @@ func (f *kvFile) Read(p []byte) (n int, err error) {
 	return f.data.Read(p)
 }
 
+func (f *kvFile) Seek(offset int64, whence int) (int64, error) {
+	return f.data.Seek(offset, whence)
+}
+
 func (kv *KV) seqStorageKey(seq uint64) string {
 	return strings.Join([]string{kv.name, fmt.Sprintf("%d", seq)}, "/")
 }
@@ -72,16 +76,15 @@ func (kv *KV) seqStorageKey(seq uint64) string {
 func (kv *KV) backupSeq(from uint64, at uint64) error {
 	buf := bytes.NewBuffer(nil)
 	s := kv.DB.NewStreamAt(math.MaxUint64)
-	size, err := s.Backup(buf, from)
-	if err != nil {
+	if _, err := s.Backup(buf, from); err != nil {
 		return err
 	}
 	name := kv.seqStorageKey(at)
 	src := &kvFile{
-		data: buf,
+		data: bytes.NewReader(buf.Bytes()),
 		info: &kvFileInfo{
 			name:    name,
-			size:    int64(size),
+			size:    int64(buf.Len()),
 			mode:    fs.FileMode(0o660),
 			modTime: time.Now(),
 		},
@@ -120,7 +120,12 @@ func (kv *KV) Commit(txn *badger.Txn, callback func(error)) error {
 	if err != nil {
 		return err
 	}
-	err = txn.CommitAt(seq, callback)
+	// The backup has to include the transaction, so wait for it to be
+	// written before calling back.
+	err = txn.CommitAt(seq, nil)
+	if callback != nil {
+		callback(err)
+	}
 	if err != nil {
 		return err
 	}
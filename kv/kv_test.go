@@ -0,0 +1,44 @@
+package kv_test
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/charm/kv"
+	"github.com/charmbracelet/charm/testserver"
+	badger "github.com/dgraph-io/badger/v3"
+)
+
+func TestSync(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	open := func() *kv.KV {
+		t.Helper()
+		opts := badger.DefaultOptions(t.TempDir()).WithLoggingLevel(badger.ERROR)
+		opts.Logger = nil
+		db, err := kv.Open(cl, "test", opts)
+		if err != nil {
+			t.Fatalf("open error: %s", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		return db
+	}
+
+	db := open()
+	for _, v := range []string{"first", "second"} {
+		if err := db.Set([]byte("key"), []byte(v)); err != nil {
+			t.Fatalf("set error: %s", err)
+		}
+	}
+
+	// Another copy of the database gets the values from the backups.
+	other := open()
+	if err := other.Sync(); err != nil {
+		t.Fatalf("sync error: %s", err)
+	}
+	v, err := other.Get([]byte("key"))
+	if err != nil {
+		t.Fatalf("get error: %s", err)
+	}
+	if string(v) != "second" {
+		t.Fatalf("expected %q, got %q", "second", v)
+	}
+}
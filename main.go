@@ -112,6 +112,9 @@ func init() {
 		cmd.FSCmd,
 		cmd.CryptCmd,
 		cmd.MigrateAccountCmd,
+		cmd.DeleteAccountCmd,
+		cmd.ActivityCmd,
+		cmd.JoinCmd,
 		cmd.WhereCmd,
 		manCmd,
 	)
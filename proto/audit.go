@@ -0,0 +1,36 @@
+package proto
+
+import "time"
+
+// AuditEventType is the kind of account activity recorded in the audit log.
+type AuditEventType string
+
+// Audit event types.
+const (
+	// AuditEventLink is recorded when a key is linked to an account.
+	AuditEventLink AuditEventType = "link"
+	// AuditEventUnlink is recorded when a key is unlinked from an account.
+	AuditEventUnlink AuditEventType = "unlink"
+	// AuditEventMerge is recorded when another account is merged into an
+	// account by linking one of its keys.
+	AuditEventMerge AuditEventType = "merge"
+	// AuditEventInvite is recorded when an account is created with an invite
+	// code.
+	AuditEventInvite AuditEventType = "invite"
+	// AuditEventAuth is recorded when a JWT is issued through api-auth.
+	AuditEventAuth AuditEventType = "auth"
+	// AuditEventJWT is recorded when a JWT is issued through jwt.
+	AuditEventJWT AuditEventType = "jwt"
+)
+
+// AuditEvent is a security relevant event on a Charm account, like a key
+// being linked. KeyFingerprint is the SHA256 fingerprint of the key that
+// caused the event.
+type AuditEvent struct {
+	ID             int            `json:"id"`
+	Type           AuditEventType `json:"type"`
+	KeyFingerprint string         `json:"key_fingerprint"`
+	RemoteAddr     string         `json:"remote_addr"`
+	Detail         string         `json:"detail,omitempty"`
+	CreatedAt      *time.Time     `json:"created_at"`
+}
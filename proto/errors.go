@@ -21,6 +21,20 @@ var ErrNameInvalid = errors.New("invalid name")
 // ErrCouldNotUnlinkKey is used when a key can't be deleted.
 var ErrCouldNotUnlinkKey = errors.New("could not unlink key")
 
+// ErrCouldNotDeleteAccount is used when an account can't be deleted.
+var ErrCouldNotDeleteAccount = errors.New("could not delete account")
+
+// ErrAccountSuspended is used when a suspended account is used.
+var ErrAccountSuspended = errors.New("account suspended")
+
+// ErrRegistrationClosed is used when a new key tries to create an account on
+// a server that doesn't allow it.
+var ErrRegistrationClosed = errors.New("registration is closed on this server, join with an invite code or link this key to an existing account")
+
+// ErrInvalidInvite is used when an invite code doesn't exist, was already used
+// or has expired.
+var ErrInvalidInvite = errors.New("invalid or expired invite code")
+
 // ErrMissingUser is used when no user record is found.
 var ErrMissingUser = errors.New("no user found")
 
@@ -31,9 +45,16 @@ var ErrUserExists = errors.New("user already exists for that key")
 // ErrPageOutOfBounds is an error for an invalid page number.
 var ErrPageOutOfBounds = errors.New("page must be a value of 1 or greater")
 
+// ErrTooManyLinkAttempts is used when a link request is refused because of
+// too many failed attempts.
+var ErrTooManyLinkAttempts = errors.New("too many link attempts, try again later")
+
 // ErrTokenExists is used when attempting to create a token that already exists.
 var ErrTokenExists = errors.New("token already exists")
 
+// ErrMissingToken is used when no token record is found.
+var ErrMissingToken = errors.New("no token found")
+
 // ErrAuthFailed indicates an authentication failure. The underlying error is
 // wrapped.
 type ErrAuthFailed struct {
@@ -45,3 +66,9 @@ func (e ErrAuthFailed) Error() string { return fmt.Sprintf("authentication faile
 
 // Unwrap returns the boxed error.
 func (e ErrAuthFailed) Unwrap() error { return e.Err }
+
+// ErrMissingUpload is used when no upload session is found.
+var ErrMissingUpload = errors.New("no upload session found")
+
+// ErrMissingTrashItem is used when a trash item can't be found.
+var ErrMissingTrashItem = errors.New("trash item not found")
@@ -5,16 +5,26 @@ import (
 	"time"
 )
 
-// FileInfo describes a file and is returned by Stat.
+// FileInfo describes a file and is returned by Stat. ETag identifies the
+// version of a file, it changes whenever the file is written.
 type FileInfo struct {
 	Name    string      `json:"name"`
 	IsDir   bool        `json:"is_dir"`
 	Size    int64       `json:"size"`
 	ModTime time.Time   `json:"modtime"`
 	Mode    fs.FileMode `json:"mode"`
+	ETag    string      `json:"etag,omitempty"`
 	Files   []FileInfo  `json:"files,omitempty"`
 }
 
+// FileListing is a page of a recursive directory listing. The names of the
+// files are their paths relative to the directory. Next is set when there are
+// more pages, it's passed back as the after parameter to get the next one.
+type FileListing struct {
+	Files []FileInfo `json:"files"`
+	Next  string     `json:"next,omitempty"`
+}
+
 // Add execute permissions to an fs.FileMode to mirror read permissions.
 func AddExecPermsForMkDir(mode fs.FileMode) fs.FileMode {
 	if mode.IsDir() {
@@ -32,3 +42,45 @@ func AddExecPermsForMkDir(mode fs.FileMode) fs.FileMode {
 	}
 	return mode | op | fs.ModeDir
 }
+
+// Usage describes how much storage a user has used. A zero Quota means
+// there's no limit.
+type Usage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"`
+}
+
+// UploadSession is a chunked upload of a file. Chunks are numbered from zero
+// and all but the last one are ChunkSize bytes long. Chunks lists the chunks
+// the server has received.
+type UploadSession struct {
+	ID        string      `json:"id"`
+	Path      string      `json:"path"`
+	Mode      fs.FileMode `json:"mode"`
+	Size      int64       `json:"size"`
+	ChunkSize int64       `json:"chunk_size"`
+	Chunks    []int       `json:"chunks"`
+	CreatedAt *time.Time  `json:"created_at,omitempty"`
+	// CharmID is the owner of the session, it's only used by the server.
+	CharmID string `json:"-"`
+}
+
+// FileVersion describes a previous version of a file. ModTime is when the
+// version was replaced.
+type FileVersion struct {
+	ID      string    `json:"id"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modtime"`
+}
+
+// TrashItem is a deleted file or directory kept in the trash. Path is where
+// it was deleted from.
+type TrashItem struct {
+	ID        int        `json:"id"`
+	Path      string     `json:"path"`
+	Size      int64      `json:"size"`
+	IsDir     bool       `json:"is_dir"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// CharmID is the owner of the item, it's only used by the server.
+	CharmID string `json:"-"`
+}
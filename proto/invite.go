@@ -0,0 +1,14 @@
+package proto
+
+import "time"
+
+// Invite is a code that lets a new key create an account on a server with
+// invite-only registration. UsedBy is the Charm ID of the account created with
+// the invite.
+type Invite struct {
+	Code      string     `json:"code"`
+	CreatedAt *time.Time `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	UsedBy    string     `json:"used_by,omitempty"`
+}
@@ -19,6 +19,7 @@ const (
 	LinkStatusError
 	LinkStatusValidTokenRequest
 	LinkStatusInvalidTokenRequest
+	LinkStatusTooManyAttempts
 )
 
 // LinkTimeout is the length of time a Token is valid for.
@@ -30,14 +31,42 @@ type Token string
 // Link is the struct used to communicate state during the account linking
 // process.
 type Link struct {
-	Token         Token      `json:"token"`
-	RequestPubKey string     `json:"request_pub_key"`
-	RequestAddr   string     `json:"request_addr"`
-	Host          string     `json:"host"`
-	Port          int        `json:"port"`
-	Status        LinkStatus `json:"status"`
+	Token         Token        `json:"token"`
+	RequestPubKey string       `json:"request_pub_key"`
+	RequestAddr   string       `json:"request_addr"`
+	Host          string       `json:"host"`
+	Port          int          `json:"port"`
+	Status        LinkStatus   `json:"status"`
+	Merge         *MergeReport `json:"merge,omitempty"`
 }
 
+// MergeReport describes the data moved when two accounts are merged during
+// linking.
+type MergeReport struct {
+	FromID    string          `json:"from_id"`
+	IntoID    string          `json:"into_id"`
+	Files     int             `json:"files"`
+	Bytes     int64           `json:"bytes"`
+	Seqs      int             `json:"seqs"`
+	Conflicts []MergeConflict `json:"conflicts,omitempty"`
+}
+
+// MergeConflict describes a file or named sequence that existed in both
+// merged accounts and which copy was kept.
+type MergeConflict struct {
+	Kind string `json:"kind"` // "file" or "seq"
+	Name string `json:"name"`
+	Kept string `json:"kept"` // "existing" or "merged"
+}
+
+// MergeConflict kinds and resolutions.
+const (
+	MergeConflictFile = "file"
+	MergeConflictSeq  = "seq"
+	MergeKeptExisting = "existing"
+	MergeKeptMerged   = "merged"
+)
+
 // LinkHandler handles linking operations for the key to be linked.
 type LinkHandler interface {
 	TokenCreated(*Link)
@@ -15,6 +15,10 @@ type User struct {
 	Email     string     `json:"email"`
 	Bio       string     `json:"bio"`
 	CreatedAt *time.Time `json:"created_at"`
+	Suspended bool       `json:"-"`
+	// StorageQuota is the user's storage limit in bytes. Nil means the
+	// server default applies.
+	StorageQuota *int64 `json:"-"`
 }
 
 // PublicKey represents to public SSH key for a Charm user.
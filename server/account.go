@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/ssh"
+)
+
+// DeleteAccount removes all of a user's files and then the user record along
+// with their keys and named sequences. Files are removed first so a failed
+// deletion can be retried with the same key.
+func DeleteAccount(cfg *Config, u *charm.User) error {
+	err := cfg.FileStore.Delete(u.CharmID, "")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not delete files: %w", err)
+	}
+	for _, ns := range []string{uploadsID, trashID} {
+		err = cfg.FileStore.Delete(ns, u.CharmID)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not delete %s: %w", ns, err)
+		}
+	}
+	if err := cfg.DB.DeleteUser(u); err != nil {
+		return fmt.Errorf("could not delete user: %w", err)
+	}
+	return nil
+}
+
+func (me *SSHServer) handleAPIDeleteAccount(s ssh.Session) {
+	key, err := keyText(s)
+	if err != nil {
+		log.Print(err)
+		_ = me.sendAPIMessage(s, "Missing key")
+		return
+	}
+	u, err := me.db.UserForKey(key, false)
+	if err != nil {
+		log.Error("Error fetching user", "err", err)
+		_ = me.sendAPIMessage(s, fmt.Sprintf("Error fetching user: %s", err))
+		return
+	}
+	log.Info("API delete account", "id", u.CharmID)
+	if err := DeleteAccount(me.config, u); err != nil {
+		log.Error("Error deleting account", "id", u.CharmID, "err", err)
+		_ = me.sendAPIMessage(s, fmt.Sprintf("Error deleting account: %s", err))
+		return
+	}
+	me.config.Stats.APIDeleteAccount()
+}
+
+func (s *HTTPServer) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	log.Info("API delete account", "id", u.CharmID)
+	if err := DeleteAccount(s.cfg, u); err != nil {
+		log.Error("cannot delete account", "id", u.CharmID, "err", err)
+		s.renderError(w)
+		return
+	}
+	s.cfg.Stats.APIDeleteAccount()
+}
@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	fw, err := w.CreateFormFile("data", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write([]byte("hello"))
+	_ = w.Close()
+	headers := http.Header{"Content-Type": {w.FormDataContentType()}}
+	resp, err := cl.AuthedRequest("POST", "/v1/fs/hello?mode=420", headers, buf)
+	if err != nil {
+		t.Fatalf("post file error: %s", err)
+	}
+	_ = resp.Body.Close()
+
+	if err := cl.DeleteAccount(); err != nil {
+		t.Fatalf("delete account error: %s", err)
+	}
+
+	// The same key now gets a brand new account with no files.
+	nid, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+	if nid == id {
+		t.Fatalf("expected a new account after deletion, got the same id %s", id)
+	}
+	resp, err = cl.AuthedRawRequest("GET", "/v1/fs/hello")
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected deleted file to be gone")
+	}
+	if resp != nil && resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestDeleteAccountHTTP(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+	resp, err := cl.AuthedRawRequest("DELETE", "/v1/account")
+	if err != nil {
+		t.Fatalf("delete account error: %s", err)
+	}
+	_ = resp.Body.Close()
+	nid, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+	if nid == id {
+		t.Fatalf("expected a new account after deletion, got the same id %s", id)
+	}
+}
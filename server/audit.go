@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// keyFingerprint returns the SHA256 fingerprint of an authorized key.
+func keyFingerprint(key string) string {
+	pk, _, _, _, err := gossh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return ""
+	}
+	return gossh.FingerprintSHA256(pk)
+}
+
+// audit records an event in the user's audit log. Failing to record an event
+// is logged but doesn't fail the operation being audited.
+func audit(d db.DB, u *charm.User, t charm.AuditEventType, key string, addr string, detail string) {
+	err := d.AddAuditEvent(u, &charm.AuditEvent{
+		Type:           t,
+		KeyFingerprint: keyFingerprint(key),
+		RemoteAddr:     addr,
+		Detail:         detail,
+	})
+	if err != nil {
+		log.Error("could not record audit event", "type", t, "id", u.CharmID, "err", err)
+	}
+}
+
+func (s *HTTPServer) handleGetActivity(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	p := r.FormValue("page")
+	if p == "" {
+		p = "1"
+	}
+	page, err := strconv.Atoi(p)
+	if err != nil || page < 1 {
+		s.renderCustomError(w, "page must be a positive number", http.StatusBadRequest)
+		return
+	}
+	es, err := s.db.GetAuditEvents(u, (page-1)*resultsPerPage)
+	if err != nil {
+		log.Error("cannot get activity", "id", u.CharmID, "err", err)
+		s.renderError(w)
+		return
+	}
+	if es == nil {
+		es = []*charm.AuditEvent{}
+	}
+	_ = json.NewEncoder(w).Encode(es)
+	s.cfg.Stats.GetActivity()
+}
@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestActivity(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	if _, err := cl.JWT("test"); err != nil {
+		t.Fatalf("jwt error: %s", err)
+	}
+	es, err := cl.Activity(1)
+	if err != nil {
+		t.Fatalf("activity error: %s", err)
+	}
+	types := map[charm.AuditEventType]*charm.AuditEvent{}
+	for _, e := range es {
+		types[e.Type] = e
+	}
+	for _, et := range []charm.AuditEventType{charm.AuditEventAuth, charm.AuditEventJWT} {
+		e, ok := types[et]
+		if !ok {
+			t.Fatalf("expected a %s event, got %+v", et, es)
+		}
+		if !strings.HasPrefix(e.KeyFingerprint, "SHA256:") {
+			t.Fatalf("expected a key fingerprint, got %q", e.KeyFingerprint)
+		}
+		if e.RemoteAddr == "" || e.CreatedAt == nil {
+			t.Fatalf("expected an address and a timestamp, got %+v", e)
+		}
+	}
+	if d := types[charm.AuditEventJWT].Detail; d != "audience test" {
+		t.Fatalf("unexpected jwt detail: %q", d)
+	}
+
+	es, err = cl.Activity(100)
+	if err != nil {
+		t.Fatalf("activity error: %s", err)
+	}
+	if len(es) != 0 {
+		t.Fatalf("expected an empty page, got %d events", len(es))
+	}
+}
@@ -14,6 +14,14 @@ func (me *SSHServer) sshMiddleware() wish.Middleware {
 	return func(sh ssh.Handler) ssh.Handler {
 		return func(s ssh.Session) {
 			cmd := s.Command()
+			if len(cmd) >= 1 {
+				if err := me.checkAccess(s); err != nil {
+					log.Info("Refused SSH command", "cmd", cmd[0], "err", err)
+					_ = me.sendAPIMessage(s, err.Error())
+					sh(s)
+					return
+				}
+			}
 			if len(cmd) >= 1 {
 				r := cmd[0]
 				log.Debug("ssh", "cmd", r)
@@ -26,6 +34,10 @@ func (me *SSHServer) sshMiddleware() wish.Middleware {
 					me.handleAPILink(s)
 				case "api-unlink":
 					me.handleAPIUnlink(s)
+				case "api-delete-account":
+					me.handleAPIDeleteAccount(s)
+				case "api-invite":
+					me.handleAPIInvite(s)
 				case "id":
 					me.handleID(s)
 				case "jwt":
@@ -68,6 +80,7 @@ func (me *SSHServer) handleAPIAuth(s ssh.Session) {
 		PublicKey:   u.PublicKey.Key,
 		EncryptKeys: eks,
 	})
+	audit(me.db, u, charm.AuditEventAuth, key, s.RemoteAddr().String(), "")
 	me.config.Stats.APIAuth()
 }
 
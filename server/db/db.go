@@ -4,6 +4,7 @@ import (
 	"time"
 
 	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db/migrate"
 )
 
 // DB specifies the business logic methods a datastore must implement as the
@@ -13,11 +14,18 @@ type DB interface {
 	LinkUserKey(user *charm.User, key string) error
 	UnlinkUserKey(user *charm.User, key string) error
 	KeysForUser(user *charm.User) ([]*charm.PublicKey, error)
-	MergeUsers(userID1 int, userID2 int) error
+	MergeUsers(userID1 int, userID2 int) (*charm.MergeReport, error)
+	DeleteUser(user *charm.User) error
 	EncryptKeysForPublicKey(pk *charm.PublicKey) ([]*charm.EncryptKey, error)
 	AddEncryptKeyForPublicKey(user *charm.User, publicKey string, globalID string, encryptedKey string, createdAt *time.Time) error
 	GetUserWithID(charmID string) (*charm.User, error)
 	GetUserWithName(name string) (*charm.User, error)
+	ListUsers(search string, offset int) ([]*charm.User, error)
+	SetUserSuspended(user *charm.User, suspended bool) error
+	SetUserStorageQuota(user *charm.User, quota *int64) error
+	GetStorageUsed(user *charm.User) (*int64, error)
+	SetStorageUsed(user *charm.User, used *int64) error
+	AddStorageUsed(user *charm.User, delta int64) error
 	SetUserName(charmID string, name string) (*charm.User, error)
 	UserCount() (int, error)
 	UserNameCount() (int, error)
@@ -28,5 +36,42 @@ type DB interface {
 	GetNewsList(tag string, page int) ([]*charm.News, error)
 	SetToken(token charm.Token) error
 	DeleteToken(token charm.Token) error
+	DeleteTokensBefore(t time.Time) (int64, error)
+	GetLinkRecord(token charm.Token) (*LinkRecord, error)
+	SetLinkRequest(token charm.Token, request string) error
+	SetLinkResponse(token charm.Token, response string) error
+	CreateInvite(code string, expiresAt *time.Time) error
+	GetInvites() ([]*charm.Invite, error)
+	DeleteInvite(code string) error
+	RedeemInvite(code string, key string) (*charm.User, error)
+	CreateUploadSession(user *charm.User, s *charm.UploadSession) error
+	GetUploadSession(id string) (*charm.UploadSession, error)
+	GetUploadSessionsBefore(t time.Time) ([]*charm.UploadSession, error)
+	GetUploadSessionsSize(user *charm.User) (int64, error)
+	AddUploadChunk(id string, n int, size int64) error
+	DeleteUploadSession(id string) error
+	AddTrashItem(user *charm.User, item *charm.TrashItem) error
+	GetTrashItem(user *charm.User, id int) (*charm.TrashItem, error)
+	GetTrashItems(user *charm.User) ([]*charm.TrashItem, error)
+	GetTrashItemsBefore(t time.Time) ([]*charm.TrashItem, error)
+	DeleteTrashItem(id int) error
+	AddAuditEvent(user *charm.User, event *charm.AuditEvent) error
+	GetAuditEvents(user *charm.User, offset int) ([]*charm.AuditEvent, error)
 	Close() error
 }
+
+// LinkRecord is the link state stored alongside a link token. It lets server
+// instances sharing a database hand link requests and responses to each other.
+type LinkRecord struct {
+	Token     charm.Token
+	Request   string
+	Response  string
+	CreatedAt time.Time
+}
+
+// MigratableDB is a DB with versioned schema migrations. The server refuses to
+// start when a MigratableDB has pending migrations.
+type MigratableDB interface {
+	DB
+	Migrator() *migrate.Migrator
+}
@@ -0,0 +1,785 @@
+// Package dbtest provides a behavioural test suite shared by all db.DB
+// implementations so they stay in lockstep.
+package dbtest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db"
+	"github.com/google/uuid"
+)
+
+// RunSuite runs the shared behavioural tests against the given db.DB. The
+// suite only creates uniquely named records so it can run against a database
+// that already holds data.
+func RunSuite(t *testing.T, d db.DB) {
+	t.Helper()
+	t.Run("UserForKey", func(t *testing.T) { testUserForKey(t, d) })
+	t.Run("LinkUnlink", func(t *testing.T) { testLinkUnlink(t, d) })
+	t.Run("MergeUsers", func(t *testing.T) { testMergeUsers(t, d) })
+	t.Run("DeleteUser", func(t *testing.T) { testDeleteUser(t, d) })
+	t.Run("UserName", func(t *testing.T) { testUserName(t, d) })
+	t.Run("ListUsers", func(t *testing.T) { testListUsers(t, d) })
+	t.Run("SuspendUser", func(t *testing.T) { testSuspendUser(t, d) })
+	t.Run("StorageQuota", func(t *testing.T) { testStorageQuota(t, d) })
+	t.Run("StorageUsed", func(t *testing.T) { testStorageUsed(t, d) })
+	t.Run("EncryptKeys", func(t *testing.T) { testEncryptKeys(t, d) })
+	t.Run("Seq", func(t *testing.T) { testSeq(t, d) })
+	t.Run("News", func(t *testing.T) { testNews(t, d) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, d) })
+	t.Run("LinkRecords", func(t *testing.T) { testLinkRecords(t, d) })
+	t.Run("AuditEvents", func(t *testing.T) { testAuditEvents(t, d) })
+	t.Run("Invites", func(t *testing.T) { testInvites(t, d) })
+	t.Run("UploadSessions", func(t *testing.T) { testUploadSessions(t, d) })
+	t.Run("Trash", func(t *testing.T) { testTrash(t, d) })
+}
+
+// NewKey returns a unique, fake authorized key string.
+func NewKey() string {
+	return fmt.Sprintf("ssh-ed25519 %s", uuid.New().String())
+}
+
+// NewUser creates a new user with a unique key.
+func NewUser(t *testing.T, d db.DB) *charm.User {
+	t.Helper()
+	u, err := d.UserForKey(NewKey(), true)
+	if err != nil {
+		t.Fatalf("create user: %s", err)
+	}
+	return u
+}
+
+func testUserForKey(t *testing.T, d db.DB) {
+	key := NewKey()
+	if _, err := d.UserForKey(key, false); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected ErrMissingUser, got %v", err)
+	}
+	c, err := d.UserCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := d.UserForKey(key, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.CharmID == "" || u.PublicKey == nil || u.PublicKey.Key != key {
+		t.Fatalf("unexpected user: %+v", u)
+	}
+	if c2, _ := d.UserCount(); c2 != c+1 {
+		t.Fatalf("expected user count %d, got %d", c+1, c2)
+	}
+	u2, err := d.UserForKey(key, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u2.ID != u.ID || u2.CharmID != u.CharmID {
+		t.Fatalf("expected the same user, got %+v and %+v", u, u2)
+	}
+	u3, err := d.GetUserWithID(u.CharmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u3.ID != u.ID {
+		t.Fatalf("expected user %d, got %d", u.ID, u3.ID)
+	}
+	if _, err := d.GetUserWithID(uuid.New().String()); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected ErrMissingUser, got %v", err)
+	}
+}
+
+func testLinkUnlink(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	key := NewKey()
+	if err := d.LinkUserKey(u, key); err != nil {
+		t.Fatal(err)
+	}
+	lu, err := d.UserForKey(key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lu.ID != u.ID {
+		t.Fatalf("expected linked key to belong to user %d, got %d", u.ID, lu.ID)
+	}
+	keys, err := d.KeysForUser(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(keys))
+	}
+	if err := d.UnlinkUserKey(u, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.UserForKey(key, false); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected ErrMissingUser after unlink, got %v", err)
+	}
+	// Unlinking the last key removes the account.
+	if err := d.UnlinkUserKey(u, u.PublicKey.Key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetUserWithID(u.CharmID); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected account to be deleted, got %v", err)
+	}
+}
+
+func testMergeUsers(t *testing.T, d db.DB) {
+	u1 := NewUser(t, d)
+	u2 := NewUser(t, d)
+	nextSeq := func(u *charm.User, name string, n int) {
+		for i := 0; i < n; i++ {
+			if _, err := d.NextSeq(u, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	nextSeq(u1, "shared", 2)
+	nextSeq(u2, "shared", 5)
+	nextSeq(u2, "moved", 3)
+	r, err := d.MergeUsers(u1.ID, u2.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Seqs != 1 {
+		t.Fatalf("expected 1 moved seq, got %d", r.Seqs)
+	}
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Name != "shared" || r.Conflicts[0].Kept != charm.MergeKeptMerged {
+		t.Fatalf("unexpected conflicts: %+v", r.Conflicts)
+	}
+	for name, want := range map[string]uint64{"shared": 4, "moved": 2} {
+		s, err := d.GetSeq(u1, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != want {
+			t.Fatalf("expected seq %s to be %d, got %d", name, want, s)
+		}
+	}
+	mu, err := d.UserForKey(u2.PublicKey.Key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mu.ID != u1.ID {
+		t.Fatalf("expected merged key to belong to user %d, got %d", u1.ID, mu.ID)
+	}
+	if _, err := d.GetUserWithID(u2.CharmID); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected merged account to be deleted, got %v", err)
+	}
+}
+
+func testDeleteUser(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	key2 := NewKey()
+	if err := d.LinkUserKey(u, key2); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddEncryptKeyForPublicKey(u, u.PublicKey.Key, uuid.New().String(), "encrypted", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.NextSeq(u, "seq"); err != nil {
+		t.Fatal(err)
+	}
+	upload := &charm.UploadSession{ID: uuid.New().String(), Path: "file", Size: 1, ChunkSize: 1}
+	if err := d.CreateUploadSession(u, upload); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddUploadChunk(upload.ID, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	trashed := &charm.TrashItem{Path: "/file", Size: 1}
+	if err := d.AddTrashItem(u, trashed); err != nil {
+		t.Fatal(err)
+	}
+	other := NewUser(t, d)
+	if err := d.DeleteUser(u); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetUserWithID(u.CharmID); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected ErrMissingUser, got %v", err)
+	}
+	for _, k := range []string{u.PublicKey.Key, key2} {
+		if _, err := d.UserForKey(k, false); !errors.Is(err, charm.ErrMissingUser) {
+			t.Fatalf("expected key to be deleted, got %v", err)
+		}
+	}
+	eks, err := d.EncryptKeysForPublicKey(u.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eks) != 0 {
+		t.Fatalf("expected encrypt keys to be deleted, got %d", len(eks))
+	}
+	if _, err := d.GetUserWithID(other.CharmID); err != nil {
+		t.Fatalf("expected other user to be kept: %s", err)
+	}
+	if _, err := d.GetUploadSession(upload.ID); !errors.Is(err, charm.ErrMissingUpload) {
+		t.Fatalf("expected upload session to be deleted, got %v", err)
+	}
+	if _, err := d.GetTrashItem(u, trashed.ID); !errors.Is(err, charm.ErrMissingTrashItem) {
+		t.Fatalf("expected trash item to be deleted, got %v", err)
+	}
+}
+
+func testUserName(t *testing.T, d db.DB) {
+	u1 := NewUser(t, d)
+	u2 := NewUser(t, d)
+	name := "n" + uuid.New().String()[:8]
+	nc, err := d.UserNameCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nu, err := d.SetUserName(u1.CharmID, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nu.Name != name {
+		t.Fatalf("expected name %q, got %q", name, nu.Name)
+	}
+	if c, _ := d.UserNameCount(); c != nc+1 {
+		t.Fatalf("expected user name count %d, got %d", nc+1, c)
+	}
+	if _, err := d.SetUserName(u2.CharmID, name); !errors.Is(err, charm.ErrNameTaken) {
+		t.Fatalf("expected ErrNameTaken, got %v", err)
+	}
+	gu, err := d.GetUserWithName(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gu.CharmID != u1.CharmID {
+		t.Fatalf("expected user %s, got %s", u1.CharmID, gu.CharmID)
+	}
+	if _, err := d.SetUserName(uuid.New().String(), "n"+uuid.New().String()[:8]); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected ErrMissingUser, got %v", err)
+	}
+}
+
+func testEncryptKeys(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	gid := uuid.New().String()
+	ca := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	if err := d.AddEncryptKeyForPublicKey(u, u.PublicKey.Key, gid, "encrypted", &ca); err != nil {
+		t.Fatal(err)
+	}
+	// Adding the same key twice is a no-op.
+	if err := d.AddEncryptKeyForPublicKey(u, u.PublicKey.Key, gid, "encrypted", &ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddEncryptKeyForPublicKey(u, u.PublicKey.Key, uuid.New().String(), "encrypted2", nil); err != nil {
+		t.Fatal(err)
+	}
+	eks, err := d.EncryptKeysForPublicKey(u.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eks) != 2 {
+		t.Fatalf("expected 2 encrypt keys, got %d", len(eks))
+	}
+	if eks[0].ID != gid || eks[0].Key != "encrypted" {
+		t.Fatalf("unexpected first encrypt key: %+v", eks[0])
+	}
+	other := NewUser(t, d)
+	if err := d.AddEncryptKeyForPublicKey(other, u.PublicKey.Key, uuid.New().String(), "nope", nil); err == nil {
+		t.Fatal("expected error adding encrypt key for another user's public key")
+	}
+}
+
+func testSeq(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	s, err := d.GetSeq(u, "seq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != 0 {
+		t.Fatalf("expected seq 0, got %d", s)
+	}
+	for i := uint64(1); i <= 3; i++ {
+		s, err = d.NextSeq(u, "seq")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != i {
+			t.Fatalf("expected seq %d, got %d", i, s)
+		}
+	}
+	s, err = d.GetSeq(u, "seq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != 3 {
+		t.Fatalf("expected seq 3, got %d", s)
+	}
+	s, err = d.NextSeq(u, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != 0 {
+		t.Fatalf("expected new seq to start at 0, got %d", s)
+	}
+}
+
+func testNews(t *testing.T, d db.DB) {
+	tag := "tag-" + uuid.New().String()
+	if err := d.PostNews("subject", "body", []string{tag, "other-" + tag}); err != nil {
+		t.Fatal(err)
+	}
+	ns, err := d.GetNewsList(tag, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ns) != 1 {
+		t.Fatalf("expected 1 news item, got %d", len(ns))
+	}
+	n, err := d.GetNews(ns[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Subject != "subject" || n.Body != "body" {
+		t.Fatalf("unexpected news: %+v", n)
+	}
+}
+
+func testTokens(t *testing.T, d db.DB) {
+	tok := charm.Token(uuid.New().String()[:6])
+	if err := d.SetToken(tok); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetToken(tok); !errors.Is(err, charm.ErrTokenExists) {
+		t.Fatalf("expected ErrTokenExists, got %v", err)
+	}
+	if err := d.DeleteToken(tok); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetToken(tok); err != nil {
+		t.Fatalf("expected token to be reusable after delete, got %v", err)
+	}
+	_ = d.DeleteToken(tok)
+
+	if err := d.SetToken(tok); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.DeleteTokensBefore(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetToken(tok); !errors.Is(err, charm.ErrTokenExists) {
+		t.Fatalf("expected recent token to be kept, got %v", err)
+	}
+	n, err := d.DeleteTokensBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n < 1 {
+		t.Fatalf("expected at least 1 deleted token, got %d", n)
+	}
+	if err := d.SetToken(tok); err != nil {
+		t.Fatalf("expected stale token to be deleted, got %v", err)
+	}
+	_ = d.DeleteToken(tok)
+}
+
+func testLinkRecords(t *testing.T, d db.DB) {
+	tok := charm.Token(uuid.New().String()[:6])
+	if _, err := d.GetLinkRecord(tok); !errors.Is(err, charm.ErrMissingToken) {
+		t.Fatalf("expected ErrMissingToken, got %v", err)
+	}
+	if err := d.SetLinkRequest(tok, "request"); !errors.Is(err, charm.ErrMissingToken) {
+		t.Fatalf("expected ErrMissingToken, got %v", err)
+	}
+	if err := d.SetToken(tok); err != nil {
+		t.Fatal(err)
+	}
+	defer d.DeleteToken(tok) // nolint:errcheck
+	r, err := d.GetLinkRecord(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Token != tok || r.Request != "" || r.Response != "" {
+		t.Fatalf("unexpected link record: %+v", r)
+	}
+	if time.Since(r.CreatedAt) > time.Minute {
+		t.Fatalf("unexpected created at: %s", r.CreatedAt)
+	}
+	if err := d.SetLinkRequest(tok, "request"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetLinkRequest(tok, "other"); !errors.Is(err, charm.ErrMissingToken) {
+		t.Fatalf("expected a second request to fail, got %v", err)
+	}
+	if err := d.SetLinkResponse(tok, "response"); err != nil {
+		t.Fatal(err)
+	}
+	r, err = d.GetLinkRecord(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Request != "request" || r.Response != "response" {
+		t.Fatalf("unexpected link record: %+v", r)
+	}
+}
+
+func testAuditEvents(t *testing.T, d db.DB) {
+	u1 := NewUser(t, d)
+	u2 := NewUser(t, d)
+	for _, e := range []*charm.AuditEvent{
+		{Type: charm.AuditEventAuth, KeyFingerprint: "SHA256:one", RemoteAddr: "127.0.0.1:1"},
+		{Type: charm.AuditEventLink, KeyFingerprint: "SHA256:two", RemoteAddr: "127.0.0.1:2", Detail: "linked"},
+	} {
+		if err := d.AddAuditEvent(u1, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.AddAuditEvent(u2, &charm.AuditEvent{Type: charm.AuditEventJWT, KeyFingerprint: "SHA256:three"}); err != nil {
+		t.Fatal(err)
+	}
+	es, err := d.GetAuditEvents(u1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(es))
+	}
+	if es[0].Type != charm.AuditEventLink || es[0].KeyFingerprint != "SHA256:two" || es[0].Detail != "linked" || es[0].CreatedAt == nil {
+		t.Fatalf("unexpected newest event: %+v", es[0])
+	}
+	if es, _ := d.GetAuditEvents(u1, 2); len(es) != 0 {
+		t.Fatalf("expected an empty page, got %d events", len(es))
+	}
+
+	if _, err := d.MergeUsers(u1.ID, u2.ID); err != nil {
+		t.Fatal(err)
+	}
+	es, err = d.GetAuditEvents(u1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 3 {
+		t.Fatalf("expected merged events, got %d", len(es))
+	}
+	if err := d.DeleteUser(u1); err != nil {
+		t.Fatal(err)
+	}
+	es, err = d.GetAuditEvents(u1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 0 {
+		t.Fatalf("expected events to be deleted, got %d", len(es))
+	}
+}
+
+func testListUsers(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	name := "list" + uuid.New().String()[:8]
+	if _, err := d.SetUserName(u.CharmID, name); err != nil {
+		t.Fatal(err)
+	}
+	for _, search := range []string{name, name[2:10], u.CharmID[:8]} {
+		us, err := d.ListUsers(search, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(us) != 1 || us[0].CharmID != u.CharmID {
+			t.Fatalf("expected to find user with %q, got %+v", search, us)
+		}
+	}
+	us, err := d.ListUsers("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) == 0 {
+		t.Fatal("expected to list users")
+	}
+}
+
+func testSuspendUser(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	if u.Suspended {
+		t.Fatal("expected new user not to be suspended")
+	}
+	if err := d.SetUserSuspended(u, true); err != nil {
+		t.Fatal(err)
+	}
+	su, err := d.GetUserWithID(u.CharmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !su.Suspended {
+		t.Fatal("expected user to be suspended")
+	}
+	if err := d.SetUserSuspended(u, false); err != nil {
+		t.Fatal(err)
+	}
+	su, err = d.UserForKey(u.PublicKey.Key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if su.Suspended {
+		t.Fatal("expected user to be reinstated")
+	}
+}
+
+func testStorageQuota(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	if u.StorageQuota != nil {
+		t.Fatal("expected new user to use the default quota")
+	}
+	q := int64(50 << 30)
+	if err := d.SetUserStorageQuota(u, &q); err != nil {
+		t.Fatal(err)
+	}
+	su, err := d.GetUserWithID(u.CharmID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if su.StorageQuota == nil || *su.StorageQuota != q {
+		t.Fatalf("expected quota %d, got %v", q, su.StorageQuota)
+	}
+	if err := d.SetUserStorageQuota(u, nil); err != nil {
+		t.Fatal(err)
+	}
+	su, err = d.UserForKey(u.PublicKey.Key, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if su.StorageQuota != nil {
+		t.Fatalf("expected quota to be reset, got %d", *su.StorageQuota)
+	}
+}
+
+func testStorageUsed(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	used, err := d.GetStorageUsed(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != nil {
+		t.Fatalf("expected usage of a new user to be unknown, got %d", *used)
+	}
+	// Unknown usage isn't changed by deltas.
+	if err := d.AddStorageUsed(u, 10); err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := d.GetStorageUsed(u); used != nil {
+		t.Fatalf("expected usage to stay unknown, got %d", *used)
+	}
+
+	n := int64(100)
+	if err := d.SetStorageUsed(u, &n); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddStorageUsed(u, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddStorageUsed(u, -50); err != nil {
+		t.Fatal(err)
+	}
+	used, err = d.GetStorageUsed(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used == nil || *used != 70 {
+		t.Fatalf("expected 70 bytes used, got %v", used)
+	}
+	if err := d.AddStorageUsed(u, -100); err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := d.GetStorageUsed(u); used == nil || *used != 0 {
+		t.Fatalf("expected usage not to go below zero, got %v", used)
+	}
+
+	if err := d.SetStorageUsed(u, nil); err != nil {
+		t.Fatal(err)
+	}
+	if used, _ := d.GetStorageUsed(u); used != nil {
+		t.Fatalf("expected usage to be unknown, got %d", *used)
+	}
+}
+
+func testInvites(t *testing.T, d db.DB) {
+	code := uuid.New().String()[:10]
+	expired := uuid.New().String()[:10]
+	past := time.Now().Add(-time.Hour)
+	if err := d.CreateInvite(code, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CreateInvite(expired, &past); err != nil {
+		t.Fatal(err)
+	}
+	defer d.DeleteInvite(code)    // nolint:errcheck
+	defer d.DeleteInvite(expired) // nolint:errcheck
+
+	key := NewKey()
+	if _, err := d.RedeemInvite(expired, key); !errors.Is(err, charm.ErrInvalidInvite) {
+		t.Fatalf("expected expired invite to be invalid, got %v", err)
+	}
+	if _, err := d.UserForKey(key, false); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected no user to be created, got %v", err)
+	}
+	u, err := d.RedeemInvite(code, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.RedeemInvite(code, NewKey()); !errors.Is(err, charm.ErrInvalidInvite) {
+		t.Fatalf("expected used invite to be invalid, got %v", err)
+	}
+	if _, err := d.RedeemInvite(code, key); !errors.Is(err, charm.ErrUserExists) {
+		t.Fatalf("expected ErrUserExists, got %v", err)
+	}
+
+	is, err := d.GetInvites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, i := range is {
+		switch i.Code {
+		case code:
+			found = true
+			if i.UsedBy != u.CharmID || i.UsedAt == nil || i.ExpiresAt != nil {
+				t.Fatalf("unexpected used invite: %+v", i)
+			}
+		case expired:
+			if i.UsedAt != nil || i.ExpiresAt == nil || !i.ExpiresAt.Before(time.Now()) {
+				t.Fatalf("unexpected expired invite: %+v", i)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("expected to list the invite")
+	}
+	if err := d.DeleteInvite(code); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteInvite(code); !errors.Is(err, charm.ErrInvalidInvite) {
+		t.Fatalf("expected ErrInvalidInvite, got %v", err)
+	}
+}
+
+func testUploadSessions(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	s := &charm.UploadSession{
+		ID:        uuid.New().String(),
+		Path:      "some/file",
+		Mode:      0o600,
+		Size:      25,
+		ChunkSize: 10,
+	}
+	if err := d.CreateUploadSession(u, s); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{2, 0, 2} {
+		if err := d.AddUploadChunk(s.ID, n, 5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.AddUploadChunk(uuid.New().String(), 0, 5); !errors.Is(err, charm.ErrMissingUpload) {
+		t.Fatalf("expected ErrMissingUpload, got %v", err)
+	}
+	if size, err := d.GetUploadSessionsSize(u); err != nil || size != s.Size {
+		t.Fatalf("expected upload sessions of %d bytes, got %d (%v)", s.Size, size, err)
+	}
+	gs, err := d.GetUploadSession(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gs.Path != s.Path || gs.Mode != s.Mode || gs.Size != s.Size || gs.ChunkSize != s.ChunkSize || gs.CharmID != u.CharmID || gs.CreatedAt == nil {
+		t.Fatalf("unexpected upload session: %+v", gs)
+	}
+	if len(gs.Chunks) != 2 || gs.Chunks[0] != 0 || gs.Chunks[1] != 2 {
+		t.Fatalf("expected chunks [0 2], got %v", gs.Chunks)
+	}
+
+	ss, err := d.GetUploadSessionsBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, o := range ss {
+		if o.ID == s.ID {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected upload session to be listed")
+	}
+	ss, err = d.GetUploadSessionsBefore(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range ss {
+		if o.ID == s.ID {
+			t.Fatal("expected new upload session not to be listed")
+		}
+	}
+
+	if err := d.DeleteUploadSession(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetUploadSession(s.ID); !errors.Is(err, charm.ErrMissingUpload) {
+		t.Fatalf("expected ErrMissingUpload, got %v", err)
+	}
+	if size, err := d.GetUploadSessionsSize(u); err != nil || size != 0 {
+		t.Fatalf("expected no upload sessions, got %d bytes (%v)", size, err)
+	}
+}
+
+func testTrash(t *testing.T, d db.DB) {
+	u := NewUser(t, d)
+	other := NewUser(t, d)
+	file := &charm.TrashItem{Path: "/some/file", Size: 10}
+	dir := &charm.TrashItem{Path: "/some/dir", Size: 20, IsDir: true}
+	for _, item := range []*charm.TrashItem{file, dir} {
+		if err := d.AddTrashItem(u, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if file.ID == 0 || dir.ID == 0 || file.ID == dir.ID {
+		t.Fatalf("expected unique trash item ids, got %d and %d", file.ID, dir.ID)
+	}
+
+	items, err := d.GetTrashItems(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != dir.ID || items[1].ID != file.ID {
+		t.Fatalf("expected the most recently deleted item first, got %+v", items)
+	}
+	if !items[0].IsDir || items[0].Path != dir.Path || items[0].Size != dir.Size || items[0].CharmID != u.CharmID || items[0].DeletedAt == nil {
+		t.Fatalf("unexpected trash item: %+v", items[0])
+	}
+	if _, err := d.GetTrashItem(other, file.ID); !errors.Is(err, charm.ErrMissingTrashItem) {
+		t.Fatalf("expected ErrMissingTrashItem for another user, got %v", err)
+	}
+
+	before, err := d.GetTrashItemsBefore(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, item := range before {
+		if item.ID == file.ID || item.ID == dir.ID {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Fatalf("expected both trash items to be listed, found %d", found)
+	}
+	before, err = d.GetTrashItemsBefore(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range before {
+		if item.ID == file.ID || item.ID == dir.ID {
+			t.Fatal("expected new trash items not to be listed")
+		}
+	}
+
+	if err := d.DeleteTrashItem(file.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetTrashItem(u, file.ID); !errors.Is(err, charm.ErrMissingTrashItem) {
+		t.Fatalf("expected ErrMissingTrashItem, got %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+// Package migrate provides versioned schema migrations for the Charm Cloud
+// SQL databases. Applied versions are tracked in a schema_version table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrIrreversible is returned when trying to revert a migration without a
+// down script.
+var ErrIrreversible = fmt.Errorf("migration is irreversible")
+
+// Migration is a db migration script. SQL upgrades the schema and Down, if
+// set, reverts it.
+type Migration struct {
+	ID   int
+	Name string
+	SQL  string
+	Down string
+}
+
+// Dialect is the SQL dialect of the migrated database.
+type Dialect int
+
+// Dialect values.
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+// Status describes a migration and whether it has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Migrator applies and reverts an ordered set of migrations.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator returns a Migrator for the database and migrations. Migrations
+// are sorted by ID.
+func NewMigrator(db *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	ms := make([]Migration, len(migrations))
+	copy(ms, migrations)
+	sort.Slice(ms, func(i, j int) bool { return ms[i].ID < ms[j].ID })
+	return &Migrator{db: db, dialect: dialect, migrations: ms}
+}
+
+// Migrations returns all known migrations in order.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the ID of the newest known migration.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].ID
+}
+
+// Version returns the ID of the newest applied migration, 0 if none have been
+// applied.
+func (m *Migrator) Version() (int, error) {
+	if err := m.init(); err != nil {
+		return 0, err
+	}
+	var v sql.NullInt64
+	if err := m.db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&v); err != nil {
+		return 0, err
+	}
+	return int(v.Int64), nil
+}
+
+// Status returns the status of every known migration.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	ss := make([]Status, 0, len(m.migrations))
+	for _, mi := range m.migrations {
+		s := Status{Migration: mi}
+		if at, ok := applied[mi.ID]; ok {
+			s.Applied = true
+			s.AppliedAt = at
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// Pending returns the migrations that haven't been applied, in order.
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var ms []Migration
+	for _, mi := range m.migrations {
+		if _, ok := applied[mi.ID]; !ok {
+			ms = append(ms, mi)
+		}
+	}
+	return ms, nil
+}
+
+// Reversible returns the last n applied migrations, newest first. These are
+// the migrations Down would revert.
+func (m *Migrator) Reversible(n int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var ms []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(ms) < n; i-- {
+		if _, ok := applied[m.migrations[i].ID]; ok {
+			ms = append(ms, m.migrations[i])
+		}
+	}
+	return ms, nil
+}
+
+// Up applies all pending migrations in order, each in its own transaction.
+// It returns the applied migrations.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mi := range pending {
+		err := m.tx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(mi.SQL); err != nil {
+				return err
+			}
+			_, err := tx.Exec(m.bind(`INSERT INTO schema_version (version, name) VALUES (?, ?)`), mi.ID, mi.Name)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d %s: %w", mi.ID, mi.Name, err)
+		}
+		done = append(done, mi)
+	}
+	return done, nil
+}
+
+// Down reverts the last n applied migrations, newest first. It returns the
+// reverted migrations.
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	ms, err := m.Reversible(n)
+	if err != nil {
+		return nil, err
+	}
+	for _, mi := range ms {
+		if mi.Down == "" {
+			return nil, fmt.Errorf("migration %04d %s: %w", mi.ID, mi.Name, ErrIrreversible)
+		}
+	}
+	var done []Migration
+	for _, mi := range ms {
+		err := m.tx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(mi.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(m.bind(`DELETE FROM schema_version WHERE version = ?`), mi.ID)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d %s: %w", mi.ID, mi.Name, err)
+		}
+		done = append(done, mi)
+	}
+	return done, nil
+}
+
+func (m *Migrator) init() error {
+	ts := "timestamp"
+	if m.dialect == Postgres {
+		ts = "timestamptz"
+	}
+	_, err := m.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS schema_version(
+		version integer NOT NULL PRIMARY KEY,
+		name text,
+		applied_at %s default current_timestamp
+	)`, ts))
+	return err
+}
+
+func (m *Migrator) applied() (map[int]*time.Time, error) {
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+	rs, err := m.db.Query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close() // nolint:errcheck
+	applied := make(map[int]*time.Time)
+	for rs.Next() {
+		var v int
+		var at sql.NullTime
+		if err := rs.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = nil
+		if at.Valid {
+			t := at.Time
+			applied[v] = &t
+		}
+	}
+	return applied, rs.Err()
+}
+
+func (m *Migrator) tx(f func(tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// bind rewrites ? placeholders for the dialect.
+func (m *Migrator) bind(q string) string {
+	if m.dialect != Postgres {
+		return q
+	}
+	out := make([]byte, 0, len(q)+8)
+	n := 0
+	for i := 0; i < len(q); i++ {
+		if q[i] == '?' {
+			n++
+			out = append(out, fmt.Sprintf("$%d", n)...)
+			continue
+		}
+		out = append(out, q[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,169 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite" // sqlite driver
+)
+
+var testMigrations = []Migration{
+	{
+		ID:   2,
+		Name: "add bar",
+		SQL:  `CREATE TABLE bar(id integer PRIMARY KEY);`,
+		Down: `DROP TABLE bar;`,
+	},
+	{
+		ID:   1,
+		Name: "add foo",
+		SQL:  `CREATE TABLE foo(id integer PRIMARY KEY);`,
+	},
+}
+
+func setup(t *testing.T) (*sql.DB, *Migrator) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db, NewMigrator(db, SQLite, testMigrations)
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestUp(t *testing.T) {
+	db, m := setup(t)
+	if m.Latest() != 2 {
+		t.Fatalf("expected latest 2, got %d", m.Latest())
+	}
+	v, err := m.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0 {
+		t.Fatalf("expected version 0, got %d", v)
+	}
+	pending, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != 1 || pending[1].ID != 2 {
+		t.Fatalf("unexpected pending migrations: %+v", pending)
+	}
+
+	done, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 {
+		t.Fatalf("expected 2 applied migrations, got %d", len(done))
+	}
+	if !tableExists(t, db, "foo") || !tableExists(t, db, "bar") {
+		t.Fatal("expected tables to be created")
+	}
+	v, err = m.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 2 {
+		t.Fatalf("expected version 2, got %d", v)
+	}
+	ss, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range ss {
+		if !s.Applied || s.AppliedAt == nil {
+			t.Fatalf("expected migration %d to be applied", s.ID)
+		}
+	}
+
+	done, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 0 {
+		t.Fatalf("expected no migrations to run, got %d", len(done))
+	}
+}
+
+func TestUpFailure(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close() // nolint:errcheck
+	m := NewMigrator(db, SQLite, []Migration{
+		{ID: 1, Name: "ok", SQL: `CREATE TABLE foo(id integer PRIMARY KEY);`},
+		{ID: 2, Name: "broken", SQL: `CREATE TABLE foo(id integer PRIMARY KEY);`},
+	})
+	done, err := m.Up()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(done) != 1 {
+		t.Fatalf("expected 1 applied migration, got %d", len(done))
+	}
+	v, err := m.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 1 {
+		t.Fatalf("expected version 1, got %d", v)
+	}
+}
+
+func TestDown(t *testing.T) {
+	db, m := setup(t)
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Down(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].ID != 2 {
+		t.Fatalf("unexpected reverted migrations: %+v", done)
+	}
+	if tableExists(t, db, "bar") {
+		t.Fatal("expected bar to be dropped")
+	}
+	v, err := m.Version()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 1 {
+		t.Fatalf("expected version 1, got %d", v)
+	}
+
+	if _, err := m.Down(1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("expected ErrIrreversible, got %v", err)
+	}
+	if !tableExists(t, db, "foo") {
+		t.Fatal("expected foo to be kept")
+	}
+}
+
+func TestBind(t *testing.T) {
+	m := NewMigrator(nil, Postgres, nil)
+	q := m.bind(`INSERT INTO t (a, b) VALUES (?, ?)`)
+	if q != `INSERT INTO t (a, b) VALUES ($1, $2)` {
+		t.Fatalf("unexpected query: %s", q)
+	}
+	m = NewMigrator(nil, SQLite, nil)
+	q = m.bind(`SELECT ?`)
+	if q != `SELECT ?` {
+		t.Fatalf("unexpected query: %s", q)
+	}
+}
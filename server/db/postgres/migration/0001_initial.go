@@ -0,0 +1,89 @@
+package migration
+
+// Migration0001 is the initial migration.
+var Migration0001 = Migration{
+	ID:   1,
+	Name: "initial schema",
+	SQL: `
+CREATE TABLE IF NOT EXISTS charm_user(
+	id SERIAL PRIMARY KEY,
+	charm_id uuid UNIQUE NOT NULL,
+	name varchar(50) UNIQUE,
+	email varchar(254),
+	bio varchar(1000),
+	created_at timestamptz default current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS public_key(
+	id SERIAL PRIMARY KEY,
+	user_id integer NOT NULL,
+	public_key varchar(2048) NOT NULL,
+	created_at timestamptz default current_timestamp,
+	UNIQUE (user_id, public_key),
+	CONSTRAINT user_id_fk
+		FOREIGN KEY (user_id)
+		REFERENCES charm_user (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS encrypt_key(
+	id SERIAL PRIMARY KEY,
+	public_key_id integer NOT NULL,
+	global_id uuid NOT NULL,
+	created_at timestamptz default current_timestamp,
+	encrypted_key varchar(2048) NOT NULL,
+	CONSTRAINT public_key_id_fk
+		FOREIGN KEY (public_key_id)
+		REFERENCES public_key (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS named_seq(
+	id SERIAL PRIMARY KEY,
+	user_id integer NOT NULL,
+	seq bigint NOT NULL DEFAULT 0,
+	name varchar(1024) NOT NULL,
+	UNIQUE (user_id, name),
+	CONSTRAINT user_id_fk
+		FOREIGN KEY (user_id)
+		REFERENCES charm_user (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS news(
+	id SERIAL PRIMARY KEY,
+	subject text,
+	body text,
+	created_at timestamptz default current_timestamp
+);
+
+CREATE TABLE IF NOT EXISTS news_tag(
+	id SERIAL PRIMARY KEY,
+	tag varchar(250),
+	news_id integer NOT NULL,
+	CONSTRAINT news_id_fk
+		FOREIGN KEY (news_id)
+		REFERENCES news (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS token(
+	id SERIAL PRIMARY KEY,
+	pin text UNIQUE NOT NULL,
+	created_at timestamptz default current_timestamp
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS token;
+DROP TABLE IF EXISTS news_tag;
+DROP TABLE IF EXISTS news;
+DROP TABLE IF EXISTS named_seq;
+DROP TABLE IF EXISTS encrypt_key;
+DROP TABLE IF EXISTS public_key;
+DROP TABLE IF EXISTS charm_user;
+`,
+}
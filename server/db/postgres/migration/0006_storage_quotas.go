@@ -0,0 +1,14 @@
+package migration
+
+// Migration0006 stores per-user storage quotas. A NULL quota uses the server
+// default.
+var Migration0006 = Migration{
+	ID:   6,
+	Name: "storage quotas",
+	SQL: `
+ALTER TABLE charm_user ADD COLUMN storage_quota bigint;
+`,
+	Down: `
+ALTER TABLE charm_user DROP COLUMN storage_quota;
+`,
+}
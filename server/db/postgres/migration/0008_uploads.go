@@ -0,0 +1,40 @@
+package migration
+
+// Migration0008 adds chunked upload sessions.
+var Migration0008 = Migration{
+	ID:   8,
+	Name: "uploads",
+	SQL: `
+CREATE TABLE IF NOT EXISTS upload_session(
+	id SERIAL PRIMARY KEY,
+	upload_id varchar(36) UNIQUE NOT NULL,
+	user_id integer NOT NULL,
+	path text NOT NULL,
+	mode integer NOT NULL,
+	size bigint NOT NULL,
+	chunk_size bigint NOT NULL,
+	created_at timestamptz default current_timestamp,
+	CONSTRAINT user_id_fk
+		FOREIGN KEY (user_id)
+		REFERENCES charm_user (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS upload_chunk(
+	session_id integer NOT NULL,
+	n integer NOT NULL,
+	size bigint NOT NULL,
+	PRIMARY KEY (session_id, n),
+	CONSTRAINT session_id_fk
+		FOREIGN KEY (session_id)
+		REFERENCES upload_session (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS upload_chunk;
+DROP TABLE IF EXISTS upload_session;
+`,
+}
@@ -0,0 +1,25 @@
+package migration
+
+// Migration0009 adds the trash of deleted files.
+var Migration0009 = Migration{
+	ID:   9,
+	Name: "trash",
+	SQL: `
+CREATE TABLE IF NOT EXISTS trash(
+	id SERIAL PRIMARY KEY,
+	user_id integer NOT NULL,
+	path text NOT NULL,
+	size bigint NOT NULL,
+	is_dir boolean NOT NULL,
+	deleted_at timestamptz default current_timestamp,
+	CONSTRAINT user_id_fk
+		FOREIGN KEY (user_id)
+		REFERENCES charm_user (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS trash;
+`,
+}
@@ -0,0 +1,20 @@
+// Package migration contains the PostgreSQL schema migrations.
+package migration
+
+import "github.com/charmbracelet/charm/server/db/migrate"
+
+// Migration is a db migration script.
+type Migration = migrate.Migration
+
+// Migrations is the ordered list of migrations for a PostgreSQL database.
+var Migrations = []Migration{
+	Migration0001,
+	Migration0002,
+	Migration0003,
+	Migration0004,
+	Migration0005,
+	Migration0006,
+	Migration0007,
+	Migration0008,
+	Migration0009,
+}
@@ -0,0 +1,123 @@
+package postgres
+
+const (
+	sqlSelectUserWithName         = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user WHERE name ILIKE $1`
+	sqlSelectUserWithCharmID      = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user WHERE charm_id = $1`
+	sqlSelectUserWithID           = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user WHERE id = $1`
+	sqlSelectStorageUsed          = `SELECT storage_used FROM charm_user WHERE id = $1`
+	sqlSelectUserPublicKeys       = `SELECT id, public_key, created_at FROM public_key WHERE user_id = $1 ORDER BY id`
+	sqlSelectNumberUserPublicKeys = `SELECT count(*) FROM public_key WHERE user_id = $1`
+	sqlSelectPublicKey            = `SELECT id, user_id, public_key FROM public_key WHERE public_key = $1`
+	sqlSelectEncryptKey           = `SELECT global_id, encrypted_key, created_at FROM encrypt_key WHERE public_key_id = $1 AND global_id = $2`
+	sqlSelectEncryptKeys          = `SELECT global_id, encrypted_key, created_at FROM encrypt_key WHERE public_key_id = $1 ORDER BY created_at ASC`
+	sqlSelectUserNamedSeqs        = `SELECT name, seq FROM named_seq WHERE user_id = $1 ORDER BY name`
+	sqlSelectNamedSeq             = `SELECT seq FROM named_seq WHERE user_id = $1 AND name = $2`
+
+	sqlInsertUser = `INSERT INTO charm_user (charm_id) VALUES ($1)`
+
+	sqlInsertPublicKey = `INSERT INTO public_key (user_id, public_key) VALUES ($1, $2)
+                        ON CONFLICT (user_id, public_key) DO UPDATE SET
+                        user_id = excluded.user_id,
+                        public_key = excluded.public_key`
+	sqlInsertNews    = `INSERT INTO news (subject, body) VALUES ($1, $2) RETURNING id`
+	sqlInsertNewsTag = `INSERT INTO news_tag (news_id, tag) VALUES ($1, $2)`
+
+	sqlIncNamedSeq = `INSERT INTO named_seq (user_id, name)
+                    VALUES($1, $2)
+                    ON CONFLICT (user_id, name) DO UPDATE SET
+                    seq = named_seq.seq + 1`
+
+	sqlInsertEncryptKey         = `INSERT INTO encrypt_key (encrypted_key, global_id, public_key_id) VALUES ($1, $2, $3)`
+	sqlInsertEncryptKeyWithDate = `INSERT INTO encrypt_key (encrypted_key, global_id, public_key_id, created_at) VALUES ($1, $2, $3, $4)`
+
+	sqlInsertAuditEvent = `INSERT INTO audit_event (user_id, event, key_fingerprint, remote_addr, detail) VALUES ($1, $2, $3, $4, $5)`
+
+	sqlInsertInvite = `INSERT INTO invite (code, expires_at) VALUES ($1, $2)`
+
+	sqlInsertToken = `INSERT INTO token (pin) VALUES ($1)`
+
+	sqlUpdateUserSuspended    = `UPDATE charm_user SET suspended = $1 WHERE id = $2`
+	sqlUpdateUserStorageQuota = `UPDATE charm_user SET storage_quota = $1 WHERE id = $2`
+	sqlUpdateStorageUsed      = `UPDATE charm_user SET storage_used = $1 WHERE id = $2`
+	sqlUpdateAddStorageUsed   = `UPDATE charm_user SET storage_used = GREATEST(0, storage_used + $1) WHERE id = $2 AND storage_used IS NOT NULL`
+	sqlUpdateRedeemInvite     = `UPDATE invite SET user_id = $1, used_at = current_timestamp WHERE code = $2 AND used_at IS NULL AND (expires_at IS NULL OR expires_at > $3)`
+	sqlUpdateUser             = `UPDATE charm_user SET name = $1 WHERE charm_id = $2`
+	sqlUpdateMergeNamedSeq    = `UPDATE named_seq SET user_id = $1 WHERE user_id = $2 AND name = $3`
+	sqlUpdateNamedSeq         = `UPDATE named_seq SET seq = $1 WHERE user_id = $2 AND name = $3`
+	sqlUpdateMergeAuditEvents = `UPDATE audit_event SET user_id = $1 WHERE user_id = $2`
+	sqlUpdateMergePublicKeys  = `UPDATE public_key SET user_id = $1 WHERE user_id = $2`
+
+	sqlDeleteUserPublicKey   = `DELETE FROM public_key WHERE user_id = $1 AND public_key = $2`
+	sqlDeleteUser            = `DELETE FROM charm_user WHERE id = $1`
+	sqlDeleteUserEncryptKeys = `DELETE FROM encrypt_key WHERE public_key_id IN (SELECT id FROM public_key WHERE user_id = $1)`
+	sqlDeleteUserPublicKeys  = `DELETE FROM public_key WHERE user_id = $1`
+	sqlDeleteUserAuditEvents = `DELETE FROM audit_event WHERE user_id = $1`
+	sqlDeleteUserNamedSeqs   = `DELETE FROM named_seq WHERE user_id = $1`
+
+	sqlSelectLinkRecord   = `SELECT pin, link_request, link_response, created_at FROM token WHERE pin = $1`
+	sqlUpdateLinkRequest  = `UPDATE token SET link_request = $1 WHERE pin = $2 AND link_request IS NULL`
+	sqlUpdateLinkResponse = `UPDATE token SET link_response = $1 WHERE pin = $2`
+
+	sqlDeleteInvite = `DELETE FROM invite WHERE code = $1`
+
+	sqlDeleteToken        = `DELETE FROM token WHERE pin = $1`
+	sqlDeleteTokensBefore = `DELETE FROM token WHERE created_at < $1`
+
+	sqlUserTableExists = `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'charm_user')`
+	sqlCountUsers      = `SELECT COUNT(*) FROM charm_user`
+	sqlCountUserNames  = `SELECT COUNT(*) FROM charm_user WHERE name <> ''`
+
+	sqlSelectNews     = `SELECT id, subject, body, created_at FROM news WHERE id = $1`
+	sqlSelectNewsList = `SELECT n.id, n.subject, n.created_at FROM news AS n
+	                     INNER JOIN news_tag AS t ON t.news_id = n.id
+	                     WHERE t.tag = $1
+	                     ORDER BY n.created_at desc
+	                     LIMIT 50 OFFSET $2`
+
+	sqlSelectAuditEvents = `SELECT id, event, key_fingerprint, remote_addr, detail, created_at FROM audit_event
+	                        WHERE user_id = $1
+	                        ORDER BY created_at desc, id desc
+	                        LIMIT 50 OFFSET $2`
+
+	sqlSelectUsers = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user
+	                  WHERE charm_id::text ILIKE $1 OR name ILIKE $1
+	                  ORDER BY id
+	                  LIMIT 50 OFFSET $2`
+
+	sqlSelectInvites = `SELECT i.code, i.created_at, i.expires_at, i.used_at, u.charm_id FROM invite AS i
+	                    LEFT JOIN charm_user AS u ON u.id = i.user_id
+	                    ORDER BY i.id`
+
+	sqlInsertUploadSession = `INSERT INTO upload_session (upload_id, user_id, path, mode, size, chunk_size) VALUES ($1, $2, $3, $4, $5, $6)`
+	sqlInsertUploadChunk   = `INSERT INTO upload_chunk (session_id, n, size) SELECT id, $1, $2 FROM upload_session WHERE upload_id = $3
+	                          ON CONFLICT (session_id, n) DO UPDATE SET size = excluded.size`
+	sqlSelectUploadChunks       = `SELECT n FROM upload_chunk WHERE session_id = $1 ORDER BY n`
+	sqlSelectUploadSessionsSize = `SELECT COALESCE(SUM(size), 0) FROM upload_session WHERE user_id = $1`
+
+	sqlDeleteUploadChunks       = `DELETE FROM upload_chunk WHERE session_id IN (SELECT id FROM upload_session WHERE upload_id = $1)`
+	sqlDeleteUploadSession      = `DELETE FROM upload_session WHERE upload_id = $1`
+	sqlDeleteUserUploadChunks   = `DELETE FROM upload_chunk WHERE session_id IN (SELECT id FROM upload_session WHERE user_id = $1)`
+	sqlDeleteUserUploadSessions = `DELETE FROM upload_session WHERE user_id = $1`
+
+	sqlSelectUploadSession = `SELECT s.id, s.upload_id, s.path, s.mode, s.size, s.chunk_size, s.created_at, u.charm_id FROM upload_session AS s
+	                                 INNER JOIN charm_user AS u ON u.id = s.user_id
+	                                 WHERE s.upload_id = $1`
+	sqlSelectUploadSessionsBefore = `SELECT s.id, s.upload_id, s.path, s.mode, s.size, s.chunk_size, s.created_at, u.charm_id FROM upload_session AS s
+	                                 INNER JOIN charm_user AS u ON u.id = s.user_id
+	                                 WHERE s.created_at < $1`
+
+	sqlInsertTrashItem       = `INSERT INTO trash (user_id, path, size, is_dir) VALUES ($1, $2, $3, $4) RETURNING id`
+	sqlDeleteTrashItem       = `DELETE FROM trash WHERE id = $1`
+	sqlDeleteUserTrashItems  = `DELETE FROM trash WHERE user_id = $1`
+	sqlUpdateMergeTrashItems = `UPDATE trash SET user_id = $1 WHERE user_id = $2`
+	sqlSelectTrashItem       = `SELECT t.id, t.path, t.size, t.is_dir, t.deleted_at, u.charm_id FROM trash AS t
+	                             INNER JOIN charm_user AS u ON u.id = t.user_id
+	                             WHERE t.user_id = $1 AND t.id = $2`
+	sqlSelectUserTrashItems = `SELECT t.id, t.path, t.size, t.is_dir, t.deleted_at, u.charm_id FROM trash AS t
+	                            INNER JOIN charm_user AS u ON u.id = t.user_id
+	                            WHERE t.user_id = $1
+	                            ORDER BY t.id DESC`
+	sqlSelectTrashItemsBefore = `SELECT t.id, t.path, t.size, t.is_dir, t.deleted_at, u.charm_id FROM trash AS t
+	                             INNER JOIN charm_user AS u ON u.id = t.user_id
+	                             WHERE t.deleted_at < $1`
+)
@@ -0,0 +1,20 @@
+package postgres
+
+import (
+	"os"
+	"testing"
+
+	"github.com/charmbracelet/charm/server/db/dbtest"
+)
+
+// The PostgreSQL tests need a running server. Point CHARM_TEST_POSTGRES_DSN at
+// an empty database to run them.
+func TestSuite(t *testing.T) {
+	dsn := os.Getenv("CHARM_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CHARM_TEST_POSTGRES_DSN not set")
+	}
+	d := NewDB(dsn)
+	t.Cleanup(func() { _ = d.Close() })
+	dbtest.RunSuite(t, d)
+}
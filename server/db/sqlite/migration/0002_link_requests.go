@@ -0,0 +1,16 @@
+package migration
+
+// Migration0002 stores link requests with their tokens so that server
+// instances sharing the database can link accounts.
+var Migration0002 = Migration{
+	ID:   2,
+	Name: "link requests",
+	SQL: `
+ALTER TABLE token ADD COLUMN link_request text;
+ALTER TABLE token ADD COLUMN link_response text;
+`,
+	Down: `
+ALTER TABLE token DROP COLUMN link_response;
+ALTER TABLE token DROP COLUMN link_request;
+`,
+}
@@ -0,0 +1,29 @@
+package migration
+
+// Migration0003 adds the account audit log.
+var Migration0003 = Migration{
+	ID:   3,
+	Name: "audit events",
+	SQL: `
+CREATE TABLE IF NOT EXISTS audit_event(
+	id INTEGER NOT NULL PRIMARY KEY,
+	user_id integer NOT NULL,
+	event varchar(50) NOT NULL,
+	key_fingerprint varchar(100) NOT NULL,
+	remote_addr varchar(255) NOT NULL,
+	detail varchar(1024),
+	created_at timestamp default current_timestamp,
+	CONSTRAINT user_id_fk
+		FOREIGN KEY (user_id)
+		REFERENCES charm_user (id)
+		ON DELETE CASCADE
+		ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS audit_event_user_id_idx ON audit_event (user_id, created_at);
+`,
+	Down: `
+DROP INDEX IF EXISTS audit_event_user_id_idx;
+DROP TABLE IF EXISTS audit_event;
+`,
+}
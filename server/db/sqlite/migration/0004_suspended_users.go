@@ -0,0 +1,13 @@
+package migration
+
+// Migration0004 lets admins suspend accounts.
+var Migration0004 = Migration{
+	ID:   4,
+	Name: "suspended users",
+	SQL: `
+ALTER TABLE charm_user ADD COLUMN suspended boolean NOT NULL DEFAULT false;
+`,
+	Down: `
+ALTER TABLE charm_user DROP COLUMN suspended;
+`,
+}
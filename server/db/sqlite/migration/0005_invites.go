@@ -0,0 +1,25 @@
+package migration
+
+// Migration0005 adds invite codes for invite-only registration.
+var Migration0005 = Migration{
+	ID:   5,
+	Name: "invites",
+	SQL: `
+CREATE TABLE IF NOT EXISTS invite(
+	id INTEGER NOT NULL PRIMARY KEY,
+	code varchar(50) UNIQUE NOT NULL,
+	user_id integer,
+	created_at timestamp default current_timestamp,
+	expires_at timestamp,
+	used_at timestamp,
+	CONSTRAINT user_id_fk
+		FOREIGN KEY (user_id)
+		REFERENCES charm_user (id)
+		ON DELETE SET NULL
+		ON UPDATE CASCADE
+);
+`,
+	Down: `
+DROP TABLE IF EXISTS invite;
+`,
+}
@@ -0,0 +1,14 @@
+package migration
+
+// Migration0007 caches the number of bytes each user stores. A NULL value
+// means the usage is unknown and has to be computed from the file store.
+var Migration0007 = Migration{
+	ID:   7,
+	Name: "storage used",
+	SQL: `
+ALTER TABLE charm_user ADD COLUMN storage_used INTEGER;
+`,
+	Down: `
+ALTER TABLE charm_user DROP COLUMN storage_used;
+`,
+}
@@ -1,8 +1,19 @@
 package migration
 
+import "github.com/charmbracelet/charm/server/db/migrate"
+
 // Migration is a db migration script.
-type Migration struct {
-	ID   int
-	Name string
-	SQL  string
+type Migration = migrate.Migration
+
+// Migrations is the ordered list of migrations for a SQLite database.
+var Migrations = []Migration{
+	Migration0001,
+	Migration0002,
+	Migration0003,
+	Migration0004,
+	Migration0005,
+	Migration0006,
+	Migration0007,
+	Migration0008,
+	Migration0009,
 }
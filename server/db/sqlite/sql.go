@@ -73,14 +73,16 @@ const (
                            created_at timestamp default current_timestamp
                            )`
 
-	sqlSelectUserWithName         = `SELECT id, charm_id, name, email, bio, created_at FROM charm_user WHERE name like ?`
-	sqlSelectUserWithCharmID      = `SELECT id, charm_id, name, email, bio, created_at FROM charm_user WHERE charm_id = ?`
-	sqlSelectUserWithID           = `SELECT id, charm_id, name, email, bio, created_at FROM charm_user WHERE id = ?`
+	sqlSelectUserWithName         = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user WHERE name like ?`
+	sqlSelectUserWithCharmID      = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user WHERE charm_id = ?`
+	sqlSelectUserWithID           = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user WHERE id = ?`
+	sqlSelectStorageUsed          = `SELECT storage_used FROM charm_user WHERE id = ?`
 	sqlSelectUserPublicKeys       = `SELECT id, public_key, created_at FROM public_key WHERE user_id = ?`
 	sqlSelectNumberUserPublicKeys = `SELECT count(*) FROM public_key WHERE user_id = ?`
 	sqlSelectPublicKey            = `SELECT id, user_id, public_key FROM public_key WHERE public_key = ?`
 	sqlSelectEncryptKey           = `SELECT global_id, encrypted_key, created_at FROM encrypt_key WHERE public_key_id = ? AND global_id = ?`
 	sqlSelectEncryptKeys          = `SELECT global_id, encrypted_key, created_at FROM encrypt_key WHERE public_key_id = ? ORDER BY created_at ASC`
+	sqlSelectUserNamedSeqs        = `SELECT name, seq FROM named_seq WHERE user_id = ? ORDER BY name`
 	sqlSelectNamedSeq             = `SELECT seq FROM named_seq WHERE user_id = ? AND name = ?`
 
 	sqlInsertUser = `INSERT INTO charm_user (charm_id) VALUES (?)`
@@ -102,16 +104,40 @@ const (
 	sqlInsertEncryptKey         = `INSERT INTO encrypt_key (encrypted_key, global_id, public_key_id) VALUES (?, ?, ?)`
 	sqlInsertEncryptKeyWithDate = `INSERT INTO encrypt_key (encrypted_key, global_id, public_key_id, created_at) VALUES (?, ?, ?, ?)`
 
-	sqlInsertToken = `INSERT INTO token (pin) VALUES (?)`
-
-	sqlUpdateUser            = `UPDATE charm_user SET name = ? WHERE charm_id = ?`
-	sqlUpdateMergePublicKeys = `UPDATE public_key SET user_id = ? WHERE user_id = ?`
+	sqlInsertAuditEvent = `INSERT INTO audit_event (user_id, event, key_fingerprint, remote_addr, detail) VALUES (?, ?, ?, ?, ?)`
 
-	sqlDeleteUserPublicKey = `DELETE FROM public_key WHERE user_id = ? AND public_key = ?`
-	sqlDeleteUser          = `DELETE FROM charm_user WHERE id = ?`
+	sqlInsertInvite = `INSERT INTO invite (code, expires_at) VALUES (?, ?)`
 
-	sqlDeleteToken = `DELETE FROM token WHERE pin = ?`
+	sqlInsertToken = `INSERT INTO token (pin) VALUES (?)`
 
+	sqlUpdateUserSuspended    = `UPDATE charm_user SET suspended = ? WHERE id = ?`
+	sqlUpdateUserStorageQuota = `UPDATE charm_user SET storage_quota = ? WHERE id = ?`
+	sqlUpdateStorageUsed      = `UPDATE charm_user SET storage_used = ? WHERE id = ?`
+	sqlUpdateAddStorageUsed   = `UPDATE charm_user SET storage_used = MAX(0, storage_used + ?) WHERE id = ? AND storage_used IS NOT NULL`
+	sqlUpdateRedeemInvite     = `UPDATE invite SET user_id = ?, used_at = current_timestamp WHERE code = ? AND used_at IS NULL AND (expires_at IS NULL OR expires_at > ?)`
+	sqlUpdateUser             = `UPDATE charm_user SET name = ? WHERE charm_id = ?`
+	sqlUpdateMergeNamedSeq    = `UPDATE named_seq SET user_id = ? WHERE user_id = ? AND name = ?`
+	sqlUpdateNamedSeq         = `UPDATE named_seq SET seq = ? WHERE user_id = ? AND name = ?`
+	sqlUpdateMergeAuditEvents = `UPDATE audit_event SET user_id = ? WHERE user_id = ?`
+	sqlUpdateMergePublicKeys  = `UPDATE public_key SET user_id = ? WHERE user_id = ?`
+
+	sqlDeleteUserPublicKey   = `DELETE FROM public_key WHERE user_id = ? AND public_key = ?`
+	sqlDeleteUser            = `DELETE FROM charm_user WHERE id = ?`
+	sqlDeleteUserEncryptKeys = `DELETE FROM encrypt_key WHERE public_key_id IN (SELECT id FROM public_key WHERE user_id = ?)`
+	sqlDeleteUserPublicKeys  = `DELETE FROM public_key WHERE user_id = ?`
+	sqlDeleteUserAuditEvents = `DELETE FROM audit_event WHERE user_id = ?`
+	sqlDeleteUserNamedSeqs   = `DELETE FROM named_seq WHERE user_id = ?`
+
+	sqlSelectLinkRecord   = `SELECT pin, link_request, link_response, created_at FROM token WHERE pin = ?`
+	sqlUpdateLinkRequest  = `UPDATE token SET link_request = ? WHERE pin = ? AND link_request IS NULL`
+	sqlUpdateLinkResponse = `UPDATE token SET link_response = ? WHERE pin = ?`
+
+	sqlDeleteInvite = `DELETE FROM invite WHERE code = ?`
+
+	sqlDeleteToken        = `DELETE FROM token WHERE pin = ?`
+	sqlDeleteTokensBefore = `DELETE FROM token WHERE created_at < ?`
+
+	sqlCountUserTable = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'charm_user'`
 	sqlCountUsers     = `SELECT COUNT(*) FROM charm_user`
 	sqlCountUserNames = `SELECT COUNT(*) FROM charm_user WHERE name <> ''`
 
@@ -121,4 +147,51 @@ const (
 	                     WHERE t.tag = ?
 	                     ORDER BY n.created_at desc
 	                     LIMIT 50 OFFSET ?`
+
+	sqlSelectAuditEvents = `SELECT id, event, key_fingerprint, remote_addr, detail, created_at FROM audit_event
+	                        WHERE user_id = ?
+	                        ORDER BY created_at desc, id desc
+	                        LIMIT 50 OFFSET ?`
+
+	sqlSelectUsers = `SELECT id, charm_id, name, email, bio, created_at, suspended, storage_quota FROM charm_user
+	                  WHERE charm_id LIKE ? OR name LIKE ?
+	                  ORDER BY id
+	                  LIMIT 50 OFFSET ?`
+
+	sqlSelectInvites = `SELECT i.code, i.created_at, i.expires_at, i.used_at, u.charm_id FROM invite AS i
+	                    LEFT JOIN charm_user AS u ON u.id = i.user_id
+	                    ORDER BY i.id`
+
+	sqlInsertUploadSession = `INSERT INTO upload_session (upload_id, user_id, path, mode, size, chunk_size) VALUES (?, ?, ?, ?, ?, ?)`
+	sqlInsertUploadChunk   = `INSERT INTO upload_chunk (session_id, n, size) SELECT id, ?, ? FROM upload_session WHERE upload_id = ?
+	                          ON CONFLICT (session_id, n) DO UPDATE SET size = excluded.size`
+	sqlSelectUploadChunks       = `SELECT n FROM upload_chunk WHERE session_id = ? ORDER BY n`
+	sqlSelectUploadSessionsSize = `SELECT COALESCE(SUM(size), 0) FROM upload_session WHERE user_id = ?`
+
+	sqlDeleteUploadChunks       = `DELETE FROM upload_chunk WHERE session_id IN (SELECT id FROM upload_session WHERE upload_id = ?)`
+	sqlDeleteUploadSession      = `DELETE FROM upload_session WHERE upload_id = ?`
+	sqlDeleteUserUploadChunks   = `DELETE FROM upload_chunk WHERE session_id IN (SELECT id FROM upload_session WHERE user_id = ?)`
+	sqlDeleteUserUploadSessions = `DELETE FROM upload_session WHERE user_id = ?`
+
+	sqlSelectUploadSession = `SELECT s.id, s.upload_id, s.path, s.mode, s.size, s.chunk_size, s.created_at, u.charm_id FROM upload_session AS s
+	                                 INNER JOIN charm_user AS u ON u.id = s.user_id
+	                                 WHERE s.upload_id = ?`
+	sqlSelectUploadSessionsBefore = `SELECT s.id, s.upload_id, s.path, s.mode, s.size, s.chunk_size, s.created_at, u.charm_id FROM upload_session AS s
+	                                 INNER JOIN charm_user AS u ON u.id = s.user_id
+	                                 WHERE s.created_at < ?`
+
+	sqlInsertTrashItem       = `INSERT INTO trash (user_id, path, size, is_dir) VALUES (?, ?, ?, ?)`
+	sqlDeleteTrashItem       = `DELETE FROM trash WHERE id = ?`
+	sqlDeleteUserTrashItems  = `DELETE FROM trash WHERE user_id = ?`
+	sqlUpdateMergeTrashItems = `UPDATE trash SET user_id = ? WHERE user_id = ?`
+	sqlSelectTrashItem       = `SELECT t.id, t.path, t.size, t.is_dir, t.deleted_at, u.charm_id FROM trash AS t
+	                             INNER JOIN charm_user AS u ON u.id = t.user_id
+	                             WHERE t.user_id = ? AND t.id = ?`
+	sqlSelectUserTrashItems = `SELECT t.id, t.path, t.size, t.is_dir, t.deleted_at, u.charm_id FROM trash AS t
+	                            INNER JOIN charm_user AS u ON u.id = t.user_id
+	                            WHERE t.user_id = ?
+	                            ORDER BY t.id DESC`
+	sqlSelectTrashItemsBefore = `SELECT t.id, t.path, t.size, t.is_dir, t.deleted_at, u.charm_id FROM trash AS t
+	                             INNER JOIN charm_user AS u ON u.id = t.user_id
+	                             WHERE t.deleted_at < ?`
 )
@@ -10,6 +10,9 @@ import (
 	"github.com/charmbracelet/log"
 
 	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db"
+	"github.com/charmbracelet/charm/server/db/migrate"
+	"github.com/charmbracelet/charm/server/db/sqlite/migration"
 	"github.com/google/uuid"
 	"modernc.org/sqlite"
 	sqlitelib "modernc.org/sqlite/lib"
@@ -20,6 +23,10 @@ const (
 	DbName = "charm_sqlite.db"
 	// The DB default connection options.
 	DbOptions = "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+
+	// timeFormat is SQLite's UTC text format for timestamps, used when
+	// comparing against the current_timestamp defaults.
+	timeFormat = "2006-01-02 15:04:05"
 )
 
 // DB is the database struct.
@@ -36,13 +43,29 @@ func NewDB(path string) *DB {
 		panic(err)
 	}
 	d := &DB{db: db}
+	fresh, err := d.isFresh()
+	if err != nil {
+		panic(err)
+	}
 	err = d.CreateDB()
 	if err != nil {
 		panic(err)
 	}
+	// New databases are brought up to date right away, existing ones need to
+	// be migrated with `charm serve migrate`.
+	if fresh {
+		if _, err := d.Migrator().Up(); err != nil {
+			panic(err)
+		}
+	}
 	return d
 }
 
+// Migrator returns the schema migrator for the database.
+func (me *DB) Migrator() *migrate.Migrator {
+	return migrate.NewMigrator(me.db, migrate.SQLite, migration.Migrations)
+}
+
 // UserCount returns the number of users.
 func (me *DB) UserCount() (int, error) {
 	var c int
@@ -83,6 +106,79 @@ func (me *DB) GetUserWithName(name string) (*charm.User, error) {
 	return u, nil
 }
 
+// ListUsers returns a page of users whose Charm ID or name contains search.
+func (me *DB) ListUsers(search string, offset int) ([]*charm.User, error) {
+	var us []*charm.User
+	pattern := "%" + search + "%"
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		rs, err := tx.Query(sqlSelectUsers, pattern, pattern, offset)
+		if err != nil {
+			return err
+		}
+		defer rs.Close() // nolint:errcheck
+		for rs.Next() {
+			u, err := me.scanUser(rs)
+			if err != nil {
+				return err
+			}
+			us = append(us, u)
+		}
+		return rs.Err()
+	})
+	return us, err
+}
+
+// SetUserSuspended suspends or reinstates the given user.
+func (me *DB) SetUserSuspended(user *charm.User, suspended bool) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateUserSuspended, suspended, user.ID)
+		return err
+	})
+}
+
+// SetUserStorageQuota sets the storage quota in bytes for the given user. A
+// nil quota resets the user to the server default.
+func (me *DB) SetUserStorageQuota(user *charm.User, quota *int64) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateUserStorageQuota, quota, user.ID)
+		return err
+	})
+}
+
+// GetStorageUsed returns the cached number of bytes the user stores, or nil if
+// it's unknown.
+func (me *DB) GetStorageUsed(user *charm.User) (*int64, error) {
+	var used sql.NullInt64
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		return tx.QueryRow(sqlSelectStorageUsed, user.ID).Scan(&used)
+	})
+	if err == sql.ErrNoRows {
+		return nil, charm.ErrMissingUser
+	}
+	if err != nil || !used.Valid {
+		return nil, err
+	}
+	return &used.Int64, nil
+}
+
+// SetStorageUsed sets the cached number of bytes the user stores. A nil value
+// marks it as unknown.
+func (me *DB) SetStorageUsed(user *charm.User, used *int64) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateStorageUsed, used, user.ID)
+		return err
+	})
+}
+
+// AddStorageUsed adds delta bytes to the cached storage usage of the user. It
+// does nothing if the usage is unknown.
+func (me *DB) AddStorageUsed(user *charm.User, delta int64) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateAddStorageUsed, delta, user.ID)
+		return err
+	})
+}
+
 // SetUserName sets a user name for the given user id.
 func (me *DB) SetUserName(charmID string, name string) (*charm.User, error) {
 	var u *charm.User
@@ -130,41 +226,11 @@ func (me *DB) SetUserName(charmID string, name string) (*charm.User, error) {
 
 // UserForKey returns the user for the given key, or optionally creates a new user with it.
 func (me *DB) UserForKey(key string, create bool) (*charm.User, error) {
-	pk := &charm.PublicKey{}
-	u := &charm.User{}
+	var u *charm.User
 	err := me.WrapTransaction(func(tx *sql.Tx) error {
 		var err error
-		r := me.selectPublicKey(tx, key)
-		err = r.Scan(&pk.ID, &pk.UserID, &pk.Key)
-		if err != nil && err != sql.ErrNoRows {
-			return err
-		}
-		if err == sql.ErrNoRows && !create {
-			return charm.ErrMissingUser
-		}
-		if err == sql.ErrNoRows {
-			log.Debug("Creating user for key", "key", charm.PublicKeySha(key))
-			err = me.createUser(tx, key)
-			if err != nil {
-				return err
-			}
-		}
-		r = me.selectPublicKey(tx, key)
-		err = r.Scan(&pk.ID, &pk.UserID, &pk.Key)
-		if err != nil {
-			return err
-		}
-
-		r = me.selectUserWithID(tx, pk.UserID)
-		u, err = me.scanUser(r)
-		if err != nil && err != sql.ErrNoRows {
-			return err
-		}
-		if err == sql.ErrNoRows {
-			return charm.ErrMissingUser
-		}
-		u.PublicKey = pk
-		return nil
+		u, err = me.userForKey(tx, key, create)
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -379,16 +445,447 @@ func (me *DB) PostNews(subject string, body string, tags []string) error {
 	})
 }
 
-// MergeUsers merge two users into a single one.
-func (me *DB) MergeUsers(userID1 int, userID2 int) error {
-	return me.WrapTransaction(func(tx *sql.Tx) error {
+// MergeUsers merge two users into a single one. The public keys and named
+// sequences and trash items of userID2 are moved to userID1 and userID2 is
+// deleted.
+func (me *DB) MergeUsers(userID1 int, userID2 int) (*charm.MergeReport, error) {
+	r := &charm.MergeReport{}
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
 		err := me.updateMergePublicKeys(tx, userID1, userID2)
 		if err != nil {
 			return err
 		}
-
+		if err := me.mergeNamedSeqs(tx, userID1, userID2, r); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqlUpdateMergeAuditEvents, userID1, userID2); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqlUpdateMergeTrashItems, userID1, userID2); err != nil {
+			return err
+		}
+		// Unfinished uploads of the merged account are dropped.
+		for _, q := range []string{sqlDeleteUserUploadChunks, sqlDeleteUserUploadSessions} {
+			if _, err := tx.Exec(q, userID2); err != nil {
+				return err
+			}
+		}
 		return me.deleteUser(tx, userID2)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// mergeNamedSeqs moves the named sequences of userID2 to userID1. When both
+// users have a sequence with the same name the higher value is kept so the
+// sequence never goes backwards.
+func (me *DB) mergeNamedSeqs(tx *sql.Tx, userID1 int, userID2 int, r *charm.MergeReport) error {
+	rs, err := tx.Query(sqlSelectUserNamedSeqs, userID2)
+	if err != nil {
+		return err
+	}
+	seqs := make(map[string]uint64)
+	var names []string
+	for rs.Next() {
+		var name string
+		var seq uint64
+		if err := rs.Scan(&name, &seq); err != nil {
+			_ = rs.Close()
+			return err
+		}
+		seqs[name] = seq
+		names = append(names, name)
+	}
+	if err := rs.Err(); err != nil {
+		_ = rs.Close()
+		return err
+	}
+	_ = rs.Close()
+	for _, name := range names {
+		seq := seqs[name]
+		existing, err := me.selectNamedSeq(tx, userID1, name)
+		if err == sql.ErrNoRows {
+			if _, err := tx.Exec(sqlUpdateMergeNamedSeq, userID1, userID2, name); err != nil {
+				return err
+			}
+			r.Seqs++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c := charm.MergeConflict{Kind: charm.MergeConflictSeq, Name: name, Kept: charm.MergeKeptExisting}
+		if seq > existing {
+			if _, err := tx.Exec(sqlUpdateNamedSeq, seq, userID1, name); err != nil {
+				return err
+			}
+			c.Kept = charm.MergeKeptMerged
+		}
+		r.Conflicts = append(r.Conflicts, c)
+	}
+	return nil
+}
+
+// DeleteUser deletes the user along with their public keys, encrypt keys and
+// named sequences.
+func (me *DB) DeleteUser(user *charm.User) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		for _, q := range []string{
+			sqlDeleteUserEncryptKeys,
+			sqlDeleteUserNamedSeqs,
+			sqlDeleteUserAuditEvents,
+			sqlDeleteUserUploadChunks,
+			sqlDeleteUserUploadSessions,
+			sqlDeleteUserTrashItems,
+			sqlDeleteUserPublicKeys,
+		} {
+			if _, err := tx.Exec(q, user.ID); err != nil {
+				return err
+			}
+		}
+		return me.deleteUser(tx, user.ID)
+	})
+}
+
+// CreateInvite creates an invite with the given code. Invites without an
+// expiry time never expire.
+func (me *DB) CreateInvite(code string, expiresAt *time.Time) error {
+	var ea interface{}
+	if expiresAt != nil {
+		ea = expiresAt.UTC().Format(timeFormat)
+	}
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlInsertInvite, code, ea)
+		return err
+	})
+}
+
+// GetInvites returns all invites, oldest first.
+func (me *DB) GetInvites() ([]*charm.Invite, error) {
+	var is []*charm.Invite
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		rs, err := tx.Query(sqlSelectInvites)
+		if err != nil {
+			return err
+		}
+		defer rs.Close() // nolint:errcheck
+		for rs.Next() {
+			i := &charm.Invite{}
+			var ca, ea, ua sql.NullTime
+			var ub sql.NullString
+			if err := rs.Scan(&i.Code, &ca, &ea, &ua, &ub); err != nil {
+				return err
+			}
+			if ca.Valid {
+				i.CreatedAt = &ca.Time
+			}
+			if ea.Valid {
+				i.ExpiresAt = &ea.Time
+			}
+			if ua.Valid {
+				i.UsedAt = &ua.Time
+			}
+			i.UsedBy = ub.String
+			is = append(is, i)
+		}
+		return rs.Err()
+	})
+	return is, err
+}
+
+// DeleteInvite deletes the given invite.
+func (me *DB) DeleteInvite(code string) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		r, err := tx.Exec(sqlDeleteInvite, code)
+		if err != nil {
+			return err
+		}
+		n, err := r.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return charm.ErrInvalidInvite
+		}
+		return nil
+	})
+}
+
+// RedeemInvite creates an account for the given key with an unused, unexpired
+// invite.
+func (me *DB) RedeemInvite(code string, key string) (*charm.User, error) {
+	var u *charm.User
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := me.userForKey(tx, key, false)
+		if err == nil {
+			return charm.ErrUserExists
+		}
+		if err != charm.ErrMissingUser {
+			return err
+		}
+		u, err = me.userForKey(tx, key, true)
+		if err != nil {
+			return err
+		}
+		r, err := tx.Exec(sqlUpdateRedeemInvite, u.ID, code, time.Now().UTC().Format(timeFormat))
+		if err != nil {
+			return err
+		}
+		n, err := r.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return charm.ErrInvalidInvite
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CreateUploadSession stores a new upload session for the given user.
+func (me *DB) CreateUploadSession(user *charm.User, s *charm.UploadSession) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlInsertUploadSession, s.ID, user.ID, s.Path, s.Mode, s.Size, s.ChunkSize)
+		return err
+	})
+}
+
+// GetUploadSession returns the upload session with the given ID along with
+// the chunks received so far.
+func (me *DB) GetUploadSession(id string) (*charm.UploadSession, error) {
+	var s *charm.UploadSession
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		var sid int
+		var err error
+		s, sid, err = me.scanUploadSession(tx.QueryRow(sqlSelectUploadSession, id))
+		if err == sql.ErrNoRows {
+			return charm.ErrMissingUpload
+		}
+		if err != nil {
+			return err
+		}
+		rs, err := tx.Query(sqlSelectUploadChunks, sid)
+		if err != nil {
+			return err
+		}
+		defer rs.Close() // nolint:errcheck
+		s.Chunks = []int{}
+		for rs.Next() {
+			var n int
+			if err := rs.Scan(&n); err != nil {
+				return err
+			}
+			s.Chunks = append(s.Chunks, n)
+		}
+		return rs.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetUploadSessionsBefore returns the upload sessions created before t,
+// without their chunks.
+func (me *DB) GetUploadSessionsBefore(t time.Time) ([]*charm.UploadSession, error) {
+	var ss []*charm.UploadSession
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		rs, err := tx.Query(sqlSelectUploadSessionsBefore, t.UTC().Format(timeFormat))
+		if err != nil {
+			return err
+		}
+		defer rs.Close() // nolint:errcheck
+		for rs.Next() {
+			s, _, err := me.scanUploadSession(rs)
+			if err != nil {
+				return err
+			}
+			ss = append(ss, s)
+		}
+		return rs.Err()
+	})
+	return ss, err
+}
+
+// GetUploadSessionsSize returns the total size of the user's upload sessions.
+func (me *DB) GetUploadSessionsSize(user *charm.User) (int64, error) {
+	var size int64
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		return tx.QueryRow(sqlSelectUploadSessionsSize, user.ID).Scan(&size)
+	})
+	return size, err
+}
+
+// AddUploadChunk records that chunk n of the upload session was received.
+func (me *DB) AddUploadChunk(id string, n int, size int64) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		r, err := tx.Exec(sqlInsertUploadChunk, n, size, id)
+		if err != nil {
+			return err
+		}
+		c, err := r.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if c == 0 {
+			return charm.ErrMissingUpload
+		}
+		return nil
+	})
+}
+
+// DeleteUploadSession deletes the upload session and its chunk records.
+func (me *DB) DeleteUploadSession(id string) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(sqlDeleteUploadChunks, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(sqlDeleteUploadSession, id)
+		return err
+	})
+}
+
+func (me *DB) scanUploadSession(r rowScanner) (*charm.UploadSession, int, error) {
+	s := &charm.UploadSession{}
+	var sid int
+	var ca sql.NullTime
+	err := r.Scan(&sid, &s.ID, &s.Path, &s.Mode, &s.Size, &s.ChunkSize, &ca, &s.CharmID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if ca.Valid {
+		s.CreatedAt = &ca.Time
+	}
+	return s, sid, nil
+}
+
+// AddTrashItem records a deleted file or directory in the user's trash and
+// sets the item's ID.
+func (me *DB) AddTrashItem(user *charm.User, item *charm.TrashItem) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		r, err := tx.Exec(sqlInsertTrashItem, user.ID, item.Path, item.Size, item.IsDir)
+		if err != nil {
+			return err
+		}
+		id, err := r.LastInsertId()
+		if err != nil {
+			return err
+		}
+		item.ID = int(id)
+		return nil
+	})
+}
+
+// GetTrashItem returns the item in the user's trash with the given ID.
+func (me *DB) GetTrashItem(user *charm.User, id int) (*charm.TrashItem, error) {
+	var item *charm.TrashItem
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		var err error
+		item, err = me.scanTrashItem(tx.QueryRow(sqlSelectTrashItem, user.ID, id))
+		if err == sql.ErrNoRows {
+			return charm.ErrMissingTrashItem
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// GetTrashItems returns the items in the user's trash, most recently deleted
+// first.
+func (me *DB) GetTrashItems(user *charm.User) ([]*charm.TrashItem, error) {
+	return me.selectTrashItems(sqlSelectUserTrashItems, user.ID)
+}
+
+// GetTrashItemsBefore returns the trash items of all users deleted before t.
+func (me *DB) GetTrashItemsBefore(t time.Time) ([]*charm.TrashItem, error) {
+	return me.selectTrashItems(sqlSelectTrashItemsBefore, t.UTC().Format(timeFormat))
+}
+
+// DeleteTrashItem deletes the record of a trash item.
+func (me *DB) DeleteTrashItem(id int) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteTrashItem, id)
+		return err
+	})
+}
+
+func (me *DB) selectTrashItems(query string, args ...interface{}) ([]*charm.TrashItem, error) {
+	items := make([]*charm.TrashItem, 0)
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		rs, err := tx.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rs.Close() // nolint:errcheck
+		for rs.Next() {
+			item, err := me.scanTrashItem(rs)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return rs.Err()
+	})
+	return items, err
+}
+
+func (me *DB) scanTrashItem(r rowScanner) (*charm.TrashItem, error) {
+	item := &charm.TrashItem{}
+	var da sql.NullTime
+	err := r.Scan(&item.ID, &item.Path, &item.Size, &item.IsDir, &da, &item.CharmID)
+	if err != nil {
+		return nil, err
+	}
+	if da.Valid {
+		item.DeletedAt = &da.Time
+	}
+	return item, nil
+}
+
+// AddAuditEvent records an audit event for the given user.
+func (me *DB) AddAuditEvent(user *charm.User, event *charm.AuditEvent) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlInsertAuditEvent, user.ID, string(event.Type), event.KeyFingerprint, event.RemoteAddr, event.Detail)
+		return err
+	})
+}
+
+// GetAuditEvents returns a page of the user's audit events, newest first.
+func (me *DB) GetAuditEvents(user *charm.User, offset int) ([]*charm.AuditEvent, error) {
+	var es []*charm.AuditEvent
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		rs, err := tx.Query(sqlSelectAuditEvents, user.ID, offset)
+		if err != nil {
+			return err
+		}
+		defer rs.Close() // nolint:errcheck
+		for rs.Next() {
+			e := &charm.AuditEvent{}
+			var t string
+			var d sql.NullString
+			var ca sql.NullTime
+			if err := rs.Scan(&e.ID, &t, &e.KeyFingerprint, &e.RemoteAddr, &d, &ca); err != nil {
+				return err
+			}
+			e.Type = charm.AuditEventType(t)
+			e.Detail = d.String
+			if ca.Valid {
+				e.CreatedAt = &ca.Time
+			}
+			es = append(es, e)
+		}
+		return rs.Err()
+	})
+	return es, err
 }
 
 // SetToken creates the given token.
@@ -412,6 +909,66 @@ func (me *DB) DeleteToken(token charm.Token) error {
 	})
 }
 
+// DeleteTokensBefore deletes all tokens created before t and returns the
+// number of deleted tokens.
+func (me *DB) DeleteTokensBefore(t time.Time) (int64, error) {
+	var n int64
+	err := me.WrapTransaction(func(tx *sql.Tx) error {
+		r, err := tx.Exec(sqlDeleteTokensBefore, t.UTC().Format(timeFormat))
+		if err != nil {
+			return err
+		}
+		n, err = r.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// GetLinkRecord returns the link state stored with the given token.
+func (me *DB) GetLinkRecord(token charm.Token) (*db.LinkRecord, error) {
+	r := &db.LinkRecord{}
+	var req, resp sql.NullString
+	err := me.db.QueryRow(sqlSelectLinkRecord, string(token)).Scan(&r.Token, &req, &resp, &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, charm.ErrMissingToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Request = req.String
+	r.Response = resp.String
+	return r, nil
+}
+
+// SetLinkRequest stores a link request with the given token. A token only
+// accepts a single request.
+func (me *DB) SetLinkRequest(token charm.Token, request string) error {
+	return me.updateToken(sqlUpdateLinkRequest, token, request)
+}
+
+// SetLinkResponse stores the response to the link request with the given
+// token.
+func (me *DB) SetLinkResponse(token charm.Token, response string) error {
+	return me.updateToken(sqlUpdateLinkResponse, token, response)
+}
+
+func (me *DB) updateToken(query string, token charm.Token, value string) error {
+	return me.WrapTransaction(func(tx *sql.Tx) error {
+		r, err := tx.Exec(query, value, string(token))
+		if err != nil {
+			return err
+		}
+		n, err := r.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return charm.ErrMissingToken
+		}
+		return nil
+	})
+}
+
 // CreateDB creates the database.
 func (me *DB) CreateDB() error {
 	return me.WrapTransaction(func(tx *sql.Tx) error {
@@ -453,6 +1010,15 @@ func (me *DB) Close() error {
 	return me.db.Close()
 }
 
+func (me *DB) isFresh() (bool, error) {
+	var c int
+	err := me.db.QueryRow(sqlCountUserTable).Scan(&c)
+	if err != nil {
+		return false, err
+	}
+	return c == 0, nil
+}
+
 func (me *DB) createUser(tx *sql.Tx, key string) error {
 	charmID := uuid.New().String()
 	err := me.insertUser(tx, charmID)
@@ -592,6 +1158,41 @@ func (me *DB) updateMergePublicKeys(tx *sql.Tx, userID1 int, userID2 int) error
 	return err
 }
 
+func (me *DB) userForKey(tx *sql.Tx, key string, create bool) (*charm.User, error) {
+	pk := &charm.PublicKey{}
+	r := me.selectPublicKey(tx, key)
+	err := r.Scan(&pk.ID, &pk.UserID, &pk.Key)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == sql.ErrNoRows && !create {
+		return nil, charm.ErrMissingUser
+	}
+	if err == sql.ErrNoRows {
+		log.Debug("Creating user for key", "key", charm.PublicKeySha(key))
+		err = me.createUser(tx, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	r = me.selectPublicKey(tx, key)
+	err = r.Scan(&pk.ID, &pk.UserID, &pk.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	r = me.selectUserWithID(tx, pk.UserID)
+	u, err := me.scanUser(r)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == sql.ErrNoRows {
+		return nil, charm.ErrMissingUser
+	}
+	u.PublicKey = pk
+	return u, nil
+}
+
 func (me *DB) createUserTable(tx *sql.Tx) error {
 	_, err := tx.Exec(sqlCreateUserTable)
 	return err
@@ -627,11 +1228,17 @@ func (me *DB) createTokenTable(tx *sql.Tx) error {
 	return err
 }
 
-func (me *DB) scanUser(r *sql.Row) (*charm.User, error) {
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (me *DB) scanUser(r rowScanner) (*charm.User, error) {
 	u := &charm.User{}
 	var un, ue, ub sql.NullString
 	var ca sql.NullTime
-	err := r.Scan(&u.ID, &u.CharmID, &un, &ue, &ub, &ca)
+	var sq sql.NullInt64
+	err := r.Scan(&u.ID, &u.CharmID, &un, &ue, &ub, &ca, &u.Suspended, &sq)
 	if err != nil {
 		return nil, err
 	}
@@ -647,6 +1254,9 @@ func (me *DB) scanUser(r *sql.Row) (*charm.User, error) {
 	if ca.Valid {
 		u.CreatedAt = &ca.Time
 	}
+	if sq.Valid {
+		u.StorageQuota = &sq.Int64
+	}
 	return u, nil
 }
 
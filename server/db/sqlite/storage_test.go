@@ -0,0 +1,14 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/charm/server/db/dbtest"
+)
+
+func TestSuite(t *testing.T) {
+	d := NewDB(filepath.Join(t.TempDir(), DbName))
+	t.Cleanup(func() { _ = d.Close() })
+	dbtest.RunSuite(t, d)
+}
@@ -97,9 +97,25 @@ func NewHTTPServer(cfg *Config) (*HTTPServer, error) {
 	mux.HandleFunc(pat.Get("/v1/bio/:name"), s.handleGetUser)
 	mux.HandleFunc(pat.Post("/v1/bio"), s.handlePostUser)
 	mux.HandleFunc(pat.Post("/v1/encrypt-key"), s.handlePostEncryptKey)
+	mux.HandleFunc(pat.Delete("/v1/account"), s.handleDeleteAccount)
+	mux.HandleFunc(pat.Get("/v1/activity"), s.handleGetActivity)
+	mux.HandleFunc(pat.Get("/v1/usage"), s.handleGetUsage)
 	mux.HandleFunc(pat.Get("/v1/fs/*"), s.handleGetFile)
 	mux.HandleFunc(pat.Post("/v1/fs/*"), s.handlePostFile)
 	mux.HandleFunc(pat.Delete("/v1/fs/*"), s.handleDeleteFile)
+	mux.HandleFunc(pat.NewWithMethods("/v1/fs/*", "MOVE"), s.handleMoveFile)
+	mux.HandleFunc(pat.NewWithMethods("/v1/fs/*", "MKCOL"), s.handleMkdir)
+	mux.HandleFunc(pat.Get("/v1/trash"), s.handleGetTrash)
+	mux.HandleFunc(pat.Delete("/v1/trash"), s.handleEmptyTrash)
+	mux.HandleFunc(pat.Post("/v1/trash/:id/restore"), s.handleRestoreTrashItem)
+	mux.HandleFunc(pat.Delete("/v1/trash/:id"), s.handleDeleteTrashItem)
+	mux.HandleFunc(pat.Get("/v1/versions/*"), s.handleGetVersions)
+	mux.HandleFunc(pat.Post("/v1/versions/*"), s.handleRestoreVersion)
+	mux.HandleFunc(pat.Post("/v1/uploads"), s.handleCreateUpload)
+	mux.HandleFunc(pat.Get("/v1/uploads/:id"), s.handleGetUpload)
+	mux.HandleFunc(pat.Put("/v1/uploads/:id/:n"), s.handlePutUploadChunk)
+	mux.HandleFunc(pat.Post("/v1/uploads/:id/commit"), s.handleCommitUpload)
+	mux.HandleFunc(pat.Delete("/v1/uploads/:id"), s.handleDeleteUpload)
 	mux.HandleFunc(pat.Get("/v1/seq/:name"), s.handleGetSeq)
 	mux.HandleFunc(pat.Post("/v1/seq/:name"), s.handlePostSeq)
 	mux.HandleFunc(pat.Get("/v1/news"), s.handleGetNewsList)
@@ -300,29 +316,112 @@ func (s *HTTPServer) handlePostFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer f.Close() // nolint:errcheck
-	if s.cfg.UserMaxStorage > 0 {
-		stat, err := s.cfg.FileStore.Stat(u.CharmID, "")
+	if s.putFile(w, r, u, path, f, fh.Size, fs.FileMode(m)) {
+		s.cfg.Stats.FSFileWritten(u.CharmID, fh.Size)
+	}
+}
+
+// checkQuota renders an error and returns false if writing size bytes to path
+// would take the user over their storage quota. It returns the size of the
+// file being replaced along with its versions, if any.
+func (s *HTTPServer) checkQuota(w http.ResponseWriter, u *charm.User, path string, size int64, mode fs.FileMode) (int64, bool) {
+	var old int64
+	if !mode.IsDir() {
+		var err error
+		old, err = s.cfg.fileSize(u.CharmID, path)
+		if err != nil {
+			log.Error("cannot stat file", "err", err)
+			s.renderError(w)
+			return 0, false
+		}
+	}
+	if quota := s.cfg.storageQuota(u); quota > 0 {
+		used, err := s.cfg.storageUsed(u)
 		if err != nil {
 			log.Error("cannot stat user storage", "err", err)
 			s.renderError(w)
-			return
+			return 0, false
 		}
-		if stat.Size()+fh.Size > s.cfg.UserMaxStorage {
+		// A replaced file only frees up space when versions aren't kept.
+		if _, ok := s.cfg.FileStore.(*storage.VersionedFileStore); !ok {
+			used -= old
+		}
+		if used+size > quota {
 			s.renderCustomError(w, "user storage limit exceeded", http.StatusForbidden)
-			return
+			return 0, false
 		}
 	}
-	if err := s.cfg.FileStore.Put(u.CharmID, path, f, fs.FileMode(m)); err != nil {
+	return old, true
+}
+
+// modTime returns the modification time a client wants a file to have, from
+// the mtime query parameter, or the zero time if it's not set. It renders an
+// error and returns false if the time is invalid.
+func (s *HTTPServer) modTime(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	mt := r.URL.Query().Get("mtime")
+	if mt == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339Nano, mt)
+	if err != nil {
+		s.renderCustomError(w, "invalid modification time", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// putFile stores size bytes read from data at path, enforcing the request's
+// If-Match and If-None-Match preconditions and the user's storage quota, and
+// keeping their usage counter up to date. It renders an error and returns
+// false if the file couldn't be stored.
+func (s *HTTPServer) putFile(w http.ResponseWriter, r *http.Request, u *charm.User, path string, data io.Reader, size int64, mode fs.FileMode) bool {
+	mtime, ok := s.modTime(w, r)
+	if !ok {
+		return false
+	}
+	unlock := s.cfg.locks.lock(u.CharmID + path)
+	defer unlock()
+	if !s.checkPreconditions(w, r, u, path) {
+		return false
+	}
+	old, ok := s.checkQuota(w, u, path, size, mode)
+	if !ok {
+		return false
+	}
+	if err := s.cfg.FileStore.Put(u.CharmID, path, data, mode, mtime); err != nil {
 		log.Error("cannot post file", "err", err)
+		s.cfg.resetStorageUsed(u)
 		s.renderError(w)
-		return
+		return false
 	}
-	s.cfg.Stats.FSFileWritten(u.CharmID, fh.Size)
+	if mode.IsDir() {
+		return true
+	}
+	if fi, err := s.cfg.FileStore.Stat(u.CharmID, path); err == nil {
+		w.Header().Set("ETag", storage.ETag(fi))
+	}
+	if _, ok := s.cfg.FileStore.(*storage.VersionedFileStore); ok {
+		// Keeping and pruning versions changes the size by more than the
+		// size of the new file.
+		n, err := s.cfg.fileSize(u.CharmID, path)
+		if err != nil {
+			log.Error("cannot stat file", "err", err)
+			s.cfg.resetStorageUsed(u)
+			return true
+		}
+		size = n
+	}
+	s.cfg.addStorageUsed(u, size-old)
+	return true
 }
 
 func (s *HTTPServer) handleGetFile(w http.ResponseWriter, r *http.Request) {
 	u := s.charmUserFromRequest(w, r)
 	path := filepath.Clean(pattern.Path(r.Context()))
+	if r.URL.Query().Get("recursive") == "1" {
+		s.handleListFiles(w, r, u, path)
+		return
+	}
 	f, err := s.cfg.FileStore.Get(u.CharmID, path)
 	if errors.Is(err, fs.ErrNotExist) {
 		s.renderCustomError(w, "file not found", http.StatusNotFound)
@@ -341,15 +440,24 @@ func (s *HTTPServer) handleGetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	switch f.(type) {
+	w.Header().Set("X-File-Mode", fmt.Sprintf("%d", fi.Mode()))
+	w.Header().Set("X-File-Mtime", fi.ModTime().UTC().Format(time.RFC3339Nano))
+	switch f := f.(type) {
 	case *charmfs.DirFile:
 		w.Header().Set("Content-Type", "application/json")
+	case io.ReadSeeker:
+		// ServeContent handles Range, If-Range, If-None-Match and
+		// If-Modified-Since requests.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("ETag", storage.ETag(fi))
+		http.ServeContent(w, r, "", fi.ModTime(), f)
+		s.cfg.Stats.FSFileRead(u.CharmID, fi.Size())
+		return
 	default:
 		w.Header().Set("Content-Type", "application/octet-stream")
-		w.Header().Set("Last-Modified", fi.ModTime().Format(http.TimeFormat))
+		w.Header().Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
 		s.cfg.Stats.FSFileRead(u.CharmID, fi.Size())
 	}
-	w.Header().Set("X-File-Mode", fmt.Sprintf("%d", fi.Mode()))
 	_, err = io.Copy(w, f)
 	if err != nil {
 		log.Error("cannot copy file", "err", err)
@@ -361,14 +469,190 @@ func (s *HTTPServer) handleGetFile(w http.ResponseWriter, r *http.Request) {
 func (s *HTTPServer) handleDeleteFile(w http.ResponseWriter, r *http.Request) {
 	u := s.charmUserFromRequest(w, r)
 	path := filepath.Clean(pattern.Path(r.Context()))
-	err := s.cfg.FileStore.Delete(u.CharmID, path)
-	if err != nil {
+	unlock := s.cfg.locks.lock(u.CharmID + path)
+	defer unlock()
+	if err := s.deleteFile(u, path); err != nil {
 		log.Error("cannot delete file", "err", err)
 		s.renderError(w)
 		return
 	}
 }
 
+// deleteFile deletes the file or directory at path, moving it to the trash if
+// it's enabled, and updates the user's usage counter. The caller must hold
+// the lock on path.
+func (s *HTTPServer) deleteFile(u *charm.User, path string) error {
+	size, err := s.cfg.fileSize(u.CharmID, path)
+	if err != nil {
+		return err
+	}
+	var trashed int64
+	if s.cfg.TrashRetention > 0 {
+		item, err := s.cfg.trashFile(u, path)
+		if err != nil {
+			return fmt.Errorf("cannot move file to trash: %w", err)
+		}
+		if item != nil {
+			trashed = item.Size
+		}
+	}
+	if err := s.cfg.FileStore.Delete(u.CharmID, path); err != nil {
+		s.cfg.resetStorageUsed(u)
+		return err
+	}
+	s.cfg.addStorageUsed(u, trashed-size)
+	return nil
+}
+
+// replaceFile moves the file at path to dest, replacing the file there. The
+// replaced file is moved to the trash first and put back if the move fails,
+// then it's purged right away if the trash is disabled. The caller must hold
+// the locks on both paths.
+func (s *HTTPServer) replaceFile(u *charm.User, path string, dest string) error {
+	size, err := s.cfg.fileSize(u.CharmID, dest)
+	if err != nil {
+		return err
+	}
+	item, err := s.cfg.trashFile(u, dest)
+	if err != nil {
+		return fmt.Errorf("cannot move file to trash: %w", err)
+	}
+	if err := s.cfg.FileStore.Move(u.CharmID, path, u.CharmID, dest); err != nil {
+		if item != nil {
+			if rerr := untrashFile(s.cfg, item); rerr != nil {
+				log.Error("cannot restore replaced file", "id", item.ID, "err", rerr)
+				s.cfg.resetStorageUsed(u)
+			}
+		}
+		return err
+	}
+	if item == nil {
+		return nil
+	}
+	if s.cfg.TrashRetention > 0 {
+		s.cfg.addStorageUsed(u, item.Size-size)
+		return nil
+	}
+	if err := deleteTrashItem(s.cfg, item); err != nil {
+		log.Error("cannot delete replaced file", "id", item.ID, "err", err)
+		s.cfg.resetStorageUsed(u)
+		return nil
+	}
+	s.cfg.addStorageUsed(u, -size)
+	return nil
+}
+
+// handleMoveFile moves a file or directory to the path in the Destination
+// header. Paths are encrypted deterministically, so this doesn't need to
+// touch the file contents. A file at the destination is replaced when the
+// Overwrite header is "T", as it would be by deleting it first, otherwise
+// it's a conflict. A directory at the destination is always a conflict.
+func (s *HTTPServer) handleMoveFile(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	path := filepath.Clean(pattern.Path(r.Context()))
+	dest := r.Header.Get("Destination")
+	if dest == "" {
+		s.renderCustomError(w, "missing destination", http.StatusBadRequest)
+		return
+	}
+	dest = filepath.Clean("/" + dest)
+	if path == "/" || dest == "/" {
+		s.renderCustomError(w, "cannot move the root directory", http.StatusBadRequest)
+		return
+	}
+	if dest == path {
+		return
+	}
+	if strings.HasPrefix(dest, path+"/") {
+		s.renderCustomError(w, "cannot move a directory into itself", http.StatusBadRequest)
+		return
+	}
+	unlock := s.cfg.locks.lock(u.CharmID+path, u.CharmID+dest)
+	defer unlock()
+	replace := false
+	if r.Header.Get("Overwrite") == "T" {
+		_, err := s.cfg.FileStore.Stat(u.CharmID, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			s.renderCustomError(w, "file not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("cannot stat file", "err", err)
+			s.renderError(w)
+			return
+		}
+		fi, err := s.cfg.FileStore.Stat(u.CharmID, dest)
+		if err == nil && fi.IsDir() {
+			s.renderCustomError(w, "destination is a directory", http.StatusConflict)
+			return
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error("cannot stat file", "err", err)
+			s.renderError(w)
+			return
+		}
+		replace = err == nil
+	}
+	var err error
+	if replace {
+		err = s.replaceFile(u, path, dest)
+	} else {
+		err = s.cfg.FileStore.Move(u.CharmID, path, u.CharmID, dest)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		s.renderCustomError(w, "file not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, fs.ErrExist) {
+		s.renderCustomError(w, "destination already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error("cannot move file", "err", err)
+		s.renderError(w)
+		return
+	}
+}
+
+// handleMkdir creates a directory, along with any missing parents. The mode
+// and modification time are taken from the mode and mtime query parameters.
+func (s *HTTPServer) handleMkdir(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	path := filepath.Clean(pattern.Path(r.Context()))
+	mode := fs.FileMode(0o700)
+	if ms := r.URL.Query().Get("mode"); ms != "" {
+		m, err := strconv.ParseUint(ms, 10, 32)
+		if err != nil {
+			s.renderCustomError(w, "invalid file mode", http.StatusBadRequest)
+			return
+		}
+		mode = fs.FileMode(m)
+	}
+	mtime, ok := s.modTime(w, r)
+	if !ok {
+		return
+	}
+	unlock := s.cfg.locks.lock(u.CharmID + path)
+	defer unlock()
+	err := s.cfg.FileStore.Mkdir(u.CharmID, path, mode)
+	if errors.Is(err, fs.ErrExist) {
+		s.renderCustomError(w, "file already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error("cannot create directory", "err", err)
+		s.renderError(w)
+		return
+	}
+	if !mtime.IsZero() {
+		if err := s.cfg.FileStore.Chtimes(u.CharmID, path, mtime); err != nil {
+			log.Error("cannot set directory modification time", "err", err)
+			s.renderError(w)
+			return
+		}
+	}
+}
+
 func (s *HTTPServer) handleGetNewsList(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	p := r.FormValue("page")
@@ -1,8 +1,15 @@
 package server_test
 
 import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server"
 	"github.com/charmbracelet/charm/testserver"
 )
 
@@ -18,3 +25,210 @@ func TestHTTPAccess(t *testing.T) {
 		t.Fatalf("expected access error, got nil")
 	}
 }
+
+func TestGetFileRange(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	resp, err := postFile(t, cl, "hello", []byte("hello world"))
+	if err != nil {
+		t.Fatalf("post file error: %s", err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = cl.AuthedRawRequest("GET", "/v1/fs/hello")
+	if err != nil {
+		t.Fatalf("get file error: %s", err)
+	}
+	_ = resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	lm := resp.Header.Get("Last-Modified")
+	if etag == "" || lm == "" {
+		t.Fatalf("expected ETag and Last-Modified, got %q and %q", etag, lm)
+	}
+
+	resp, err = cl.AuthedRequest("GET", "/v1/fs/hello", http.Header{
+		"Range":    {"bytes=6-"},
+		"If-Range": {etag},
+	}, nil)
+	if err != nil {
+		t.Fatalf("range request error: %s", err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent || string(b) != "world" {
+		t.Fatalf("expected 206 with %q, got %d with %q", "world", resp.StatusCode, b)
+	}
+
+	// A stale If-Range returns the whole file.
+	resp, err = cl.AuthedRequest("GET", "/v1/fs/hello", http.Header{
+		"Range":    {"bytes=6-"},
+		"If-Range": {`"stale"`},
+	}, nil)
+	if err != nil {
+		t.Fatalf("range request error: %s", err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(b) != "hello world" {
+		t.Fatalf("expected 200 with the whole file, got %d with %q", resp.StatusCode, b)
+	}
+
+	for _, h := range []http.Header{
+		{"If-None-Match": {etag}},
+		{"If-Modified-Since": {lm}},
+	} {
+		resp, err = cl.AuthedRequest("GET", "/v1/fs/hello", h, nil)
+		if resp == nil {
+			t.Fatalf("conditional request error: %s", err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusNotModified {
+			t.Fatalf("expected 304 for %v, got %d", h, resp.StatusCode)
+		}
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	for _, name := range []string{"dir/a", "dir/b", "other"} {
+		resp, err := postFile(t, cl, name, []byte(name))
+		if err != nil {
+			t.Fatalf("post file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	move := func(from string, to string) (*http.Response, error) {
+		return cl.AuthedRequest("MOVE", "/v1/fs/"+from, http.Header{"Destination": {to}}, nil)
+	}
+	resp, err := move("dir", "moved/dir")
+	if err != nil {
+		t.Fatalf("move error: %s", err)
+	}
+	_ = resp.Body.Close()
+	if resp, err := cl.AuthedRawRequest("GET", "/v1/fs/dir/a"); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the old path to be gone, got %v", err)
+	}
+	resp, err = cl.AuthedRawRequest("GET", "/v1/fs/moved/dir/b")
+	if err != nil {
+		t.Fatalf("get moved file error: %s", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "dir/b" {
+		t.Fatalf("expected the moved file to be %q, got %q", "dir/b", b)
+	}
+
+	for _, tc := range []struct {
+		from, to string
+		status   int
+	}{
+		{"other", "moved/dir/a", http.StatusConflict},
+		{"missing", "somewhere", http.StatusNotFound},
+		{"moved", "moved/dir/inside", http.StatusBadRequest},
+		{"other", "", http.StatusBadRequest},
+	} {
+		resp, err := move(tc.from, tc.to)
+		if err == nil || resp.StatusCode != tc.status {
+			t.Fatalf("expected moving %q to %q to fail with %d, got %v", tc.from, tc.to, tc.status, err)
+		}
+		_ = resp.Body.Close()
+	}
+}
+
+func TestConditionalWrite(t *testing.T) {
+	cl := testserver.SetupTestServer(t)
+	resp, err := postFile(t, cl, "file", []byte("one"), http.Header{"If-None-Match": {"*"}})
+	if err != nil {
+		t.Fatalf("post file error: %s", err)
+	}
+	_ = resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected the write to return the new ETag")
+	}
+
+	resp, err = postFile(t, cl, "file", []byte("two"), http.Header{"If-None-Match": {"*"}})
+	if err == nil || resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected creating an existing file to fail with 412, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = postFile(t, cl, "file", []byte("two"), http.Header{"If-Match": {etag}})
+	if err != nil {
+		t.Fatalf("post file error: %s", err)
+	}
+	_ = resp.Body.Close()
+	if resp.Header.Get("ETag") == etag {
+		t.Fatal("expected the ETag to change")
+	}
+
+	// The old version is gone, so writing over it again fails.
+	resp, err = postFile(t, cl, "file", []byte("three"), http.Header{"If-Match": {etag}})
+	if err == nil || resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected a stale If-Match to fail with 412, got %v", err)
+	}
+	_ = resp.Body.Close()
+	resp, err = postFile(t, cl, "missing", []byte("three"), http.Header{"If-Match": {"*"}})
+	if err == nil || resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected If-Match on a missing file to fail with 412, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = cl.AuthedRawRequest("GET", "/v1/fs/file")
+	if err != nil {
+		t.Fatalf("get file error: %s", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "two" {
+		t.Fatalf("expected %q, got %q", "two", b)
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	pageSize := server.ListPageSize
+	t.Cleanup(func() { server.ListPageSize = pageSize })
+	server.ListPageSize = 2
+	cl := testserver.SetupTestServer(t)
+	for _, name := range []string{"dir/a", "dir/sub/b", "dir/sub/c", "dir/z"} {
+		resp, err := postFile(t, cl, name, []byte(name))
+		if err != nil {
+			t.Fatalf("post file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	var names []string
+	pages := 0
+	after := ""
+	for {
+		resp, err := cl.AuthedRawRequest("GET", "/v1/fs/dir?recursive=1&after="+url.QueryEscape(after))
+		if err != nil {
+			t.Fatalf("list error: %s", err)
+		}
+		var l charm.FileListing
+		err = json.NewDecoder(resp.Body).Decode(&l)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, fi := range l.Files {
+			names = append(names, fi.Name)
+		}
+		if l.Next == "" {
+			break
+		}
+		after = l.Next
+	}
+	if got := strings.Join(names, " "); got != "a sub sub/b sub/c z" || pages != 3 {
+		t.Fatalf("expected 5 files in 3 pages, got %q in %d", got, pages)
+	}
+
+	if resp, err := cl.AuthedRawRequest("GET", "/v1/fs/missing?recursive=1"); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected listing a missing directory to fail with 404, got %v", err)
+	}
+}
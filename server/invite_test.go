@@ -0,0 +1,64 @@
+package server_test
+
+import (
+	"errors"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestInviteRegistration(t *testing.T) {
+	t.Setenv("CHARM_SERVER_REGISTRATION", server.RegistrationInvite)
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+
+	if _, err := cl.Auth(); !errors.Is(err, charm.ErrRegistrationClosed) {
+		t.Fatalf("expected ErrRegistrationClosed, got %v", err)
+	}
+	if err := cl.RedeemInvite("NOPE000000"); !errors.Is(err, charm.ErrInvalidInvite) {
+		t.Fatalf("expected ErrInvalidInvite, got %v", err)
+	}
+
+	inv, err := server.NewInvite(cfg.DB, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.RedeemInvite(inv.Code); err != nil {
+		t.Fatalf("redeem error: %s", err)
+	}
+	if _, err := cl.Auth(); err != nil {
+		t.Fatalf("auth error after redeeming: %s", err)
+	}
+	if err := cl.RedeemInvite(inv.Code); !errors.Is(err, charm.ErrUserExists) {
+		t.Fatalf("expected ErrUserExists, got %v", err)
+	}
+
+	invs, err := cfg.DB.GetInvites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invs) != 1 || invs[0].UsedAt == nil || invs[0].UsedBy != id {
+		t.Fatalf("expected invite to be used by %s, got %+v", id, invs)
+	}
+}
+
+func TestAllowlistRegistration(t *testing.T) {
+	t.Setenv("CHARM_SERVER_REGISTRATION", server.RegistrationAllowlist)
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+
+	if _, err := cl.Auth(); !errors.Is(err, charm.ErrRegistrationClosed) {
+		t.Fatalf("expected ErrRegistrationClosed, got %v", err)
+	}
+	inv, err := server.NewInvite(cfg.DB, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.RedeemInvite(inv.Code); !errors.Is(err, charm.ErrRegistrationClosed) {
+		t.Fatalf("expected invites to be refused, got %v", err)
+	}
+}
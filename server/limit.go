@@ -0,0 +1,75 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// attemptLimiter counts failed attempts per key, e.g. an IP address or a
+// public key, within a fixed window that starts at the first failed attempt.
+// The counts are kept in memory, so each server process has limits of its
+// own.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	attempts map[string]*attempts
+}
+
+type attempts struct {
+	count int
+	first time.Time
+}
+
+// newAttemptLimiter returns a limiter that allows up to max failed attempts
+// per key within window. A max of zero or less disables the limiter.
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: make(map[string]*attempts),
+	}
+}
+
+// Allow reports whether none of the keys have exceeded the attempt limit.
+func (al *attemptLimiter) Allow(keys ...string) bool {
+	if al.max <= 0 {
+		return true
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.prune()
+	for _, k := range keys {
+		if a, ok := al.attempts[k]; ok && a.count >= al.max {
+			return false
+		}
+	}
+	return true
+}
+
+// Fail records a failed attempt for each of the keys.
+func (al *attemptLimiter) Fail(keys ...string) {
+	if al.max <= 0 {
+		return
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.prune()
+	for _, k := range keys {
+		a, ok := al.attempts[k]
+		if !ok {
+			a = &attempts{first: time.Now()}
+			al.attempts[k] = a
+		}
+		a.count++
+	}
+}
+
+// prune removes expired windows. It must be called with the lock held.
+func (al *attemptLimiter) prune() {
+	for k, a := range al.attempts {
+		if time.Since(a.first) > al.window {
+			delete(al.attempts, k)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	al := newAttemptLimiter(2, time.Hour)
+	if !al.Allow("ip:a", "key:a") {
+		t.Fatal("expected first attempt to be allowed")
+	}
+	al.Fail("ip:a", "key:a")
+	al.Fail("ip:a", "key:b")
+	if al.Allow("ip:a", "key:c") {
+		t.Fatal("expected ip to be limited")
+	}
+	if !al.Allow("ip:b", "key:a") {
+		t.Fatal("expected key with one failure to be allowed")
+	}
+	al.Fail("ip:b", "key:a")
+	if al.Allow("ip:c", "key:a") {
+		t.Fatal("expected key to be limited")
+	}
+
+	al = newAttemptLimiter(1, time.Millisecond)
+	al.Fail("ip:a")
+	time.Sleep(5 * time.Millisecond)
+	if !al.Allow("ip:a") {
+		t.Fatal("expected attempts to expire")
+	}
+
+	al = newAttemptLimiter(0, time.Hour)
+	al.Fail("ip:a")
+	if !al.Allow("ip:a") {
+		t.Fatal("expected disabled limiter to allow everything")
+	}
+}
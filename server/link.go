@@ -3,7 +3,9 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -176,6 +178,7 @@ func (me *SSHServer) LinkGen(lt charm.LinkTransport) error {
 					me.linkQueue.SendLinkRequest(lt, linkRequest, l)
 					return err
 				}
+				audit(me.db, u, charm.AuditEventLink, l.RequestPubKey, l.RequestAddr, "approved by "+keyFingerprint(u.PublicKey.Key))
 				l.Status = charm.LinkStatusSuccess
 			} else if lu.ID == u.ID {
 				// Maybe they're already linked
@@ -185,12 +188,16 @@ func (me *SSHServer) LinkGen(lt charm.LinkTransport) error {
 			} else {
 				// Link requester's key is linked to another acccount, merge
 				log.Debug("Key is already linked to different account", "id", lu.CharmID)
-				err = me.db.MergeUsers(u.ID, lu.ID)
+				mr, err := me.mergeAccounts(u, lu)
 				if err != nil {
 					l.Status = charm.LinkStatusError
 					me.linkQueue.SendLinkRequest(lt, linkRequest, l)
 					return err
 				}
+				log.Info("Merged accounts", "from", mr.FromID, "into", mr.IntoID, "files", mr.Files, "seqs", mr.Seqs, "conflicts", len(mr.Conflicts))
+				audit(me.db, u, charm.AuditEventMerge, l.RequestPubKey, l.RequestAddr,
+					fmt.Sprintf("merged account %s (%d files, %d sequences), approved by %s", mr.FromID, mr.Files, mr.Seqs, keyFingerprint(u.PublicKey.Key)))
+				l.Merge = mr
 				l.Status = charm.LinkStatusSuccess
 			}
 			if l.Status == charm.LinkStatusSuccess {
@@ -219,8 +226,20 @@ func (me *SSHServer) LinkRequest(lt charm.LinkTransport, key string, token strin
 		Token:         charm.Token(token),
 	}
 	lt.RequestStart(l)
+	host, _, err := net.SplitHostPort(ip)
+	if err != nil {
+		host = ip
+	}
+	limits := []string{"ip:" + host, "key:" + key}
+	if !me.linkAttempts.Allow(limits...) {
+		log.Info("Too many link attempts", "addr", host)
+		l.Status = charm.LinkStatusTooManyAttempts
+		lt.RequestInvalidToken(l)
+		return charm.ErrTooManyLinkAttempts
+	}
 	linkRequest, err := me.linkQueue.WaitLinkRequest(l.Token)
 	if err != nil || !me.linkQueue.ValidateLinkRequest(l.Token) {
+		me.linkAttempts.Fail(limits...)
 		l.Status = charm.LinkStatusInvalidTokenRequest
 		lt.RequestInvalidToken(l)
 		return fmt.Errorf("invalid token '%s'", token)
@@ -232,6 +251,7 @@ func (me *SSHServer) LinkRequest(lt charm.LinkTransport, key string, token strin
 		select {
 		case lr := <-linkRequest:
 			l.Status = lr.Status
+			l.Merge = lr.Merge
 			switch lr.Status {
 			case charm.LinkStatusSuccess:
 				lt.Success(l)
@@ -358,31 +378,45 @@ func (me *SSHServer) handleAPIUnlink(s ssh.Session) {
 		_ = me.sendAPIMessage(s, fmt.Sprintf("Error unlinking account: %s", err))
 		return
 	}
+	audit(me.db, u, charm.AuditEventUnlink, key, s.RemoteAddr().String(), "unlinked "+keyFingerprint(ur.Key))
 	me.config.Stats.APIUnlink()
 }
 
 type channelLinkQueue struct {
 	s            *SSHServer
+	mu           sync.Mutex
 	linkRequests map[charm.Token]chan *charm.Link
+	createdAt    map[charm.Token]time.Time
 }
 
 // InitLinkRequest implements the proto.LinkQueue interface for the channelLinkQueue.
 func (s *channelLinkQueue) InitLinkRequest(t charm.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if _, ok := s.linkRequests[t]; !ok {
 		log.Error("Making new link for token", "token", t)
 		lr := make(chan *charm.Link)
 		s.linkRequests[t] = lr
+		s.createdAt[t] = time.Now()
 	}
 }
 
-// ValidateLinkRequest implements the proto.LinkQueue interface for the channelLinkQueue.
+// ValidateLinkRequest implements the proto.LinkQueue interface for the
+// channelLinkQueue. Tokens expire after charm.LinkTimeout.
 func (s *channelLinkQueue) ValidateLinkRequest(t charm.Token) bool {
-	_, err := s.WaitLinkRequest(t)
-	return err == nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ca, ok := s.createdAt[t]
+	if !ok {
+		return false
+	}
+	return time.Since(ca) < charm.LinkTimeout
 }
 
 // WaitLinkRequest implements the proto.LinkQueue interface for the channelLinkQueue.
 func (s *channelLinkQueue) WaitLinkRequest(t charm.Token) (chan *charm.Link, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	lr, ok := s.linkRequests[t]
 	if !ok {
 		return nil, fmt.Errorf("no link request for token: %s", t)
@@ -404,5 +438,8 @@ func (s *channelLinkQueue) SendLinkRequest(lt charm.LinkTransport, lc chan *char
 
 // DeleteLinkRequest implements the proto.LinkTransport interface for the channelLinkQueue.
 func (s *channelLinkQueue) DeleteLinkRequest(tok charm.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	delete(s.linkRequests, tok)
+	delete(s.createdAt, tok)
 }
@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+// invalidTokenHandler records the status of the last invalid token response.
+type invalidTokenHandler struct {
+	status charm.LinkStatus
+}
+
+func (lh *invalidTokenHandler) TokenCreated(*charm.Link) {}
+func (lh *invalidTokenHandler) TokenSent(*charm.Link)    {}
+func (lh *invalidTokenHandler) ValidToken(*charm.Link)   {}
+func (lh *invalidTokenHandler) InvalidToken(l *charm.Link) {
+	lh.status = l.Status
+}
+func (lh *invalidTokenHandler) Request(*charm.Link) bool  { return false }
+func (lh *invalidTokenHandler) RequestDenied(*charm.Link) {}
+func (lh *invalidTokenHandler) SameUser(*charm.Link)      {}
+func (lh *invalidTokenHandler) Success(*charm.Link)       {}
+func (lh *invalidTokenHandler) Timeout(*charm.Link)       {}
+func (lh *invalidTokenHandler) Error(*charm.Link)         {}
+
+func TestLinkAttemptLimit(t *testing.T) {
+	t.Setenv("CHARM_SERVER_LINK_MAX_ATTEMPTS", "3")
+	cl := testserver.SetupTestServer(t)
+	for i := 0; i < 3; i++ {
+		lh := &invalidTokenHandler{}
+		if err := cl.Link(lh, "AAAAAA"); err != nil {
+			t.Fatalf("link error: %s", err)
+		}
+		if lh.status != charm.LinkStatusInvalidTokenRequest {
+			t.Fatalf("expected invalid token, got status %d", lh.status)
+		}
+	}
+	lh := &invalidTokenHandler{}
+	if err := cl.Link(lh, "AAAAAA"); err != nil {
+		t.Fatalf("link error: %s", err)
+	}
+	if lh.status != charm.LinkStatusTooManyAttempts {
+		t.Fatalf("expected too many attempts, got status %d", lh.status)
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db"
+)
+
+// DefaultLinkQueuePollInterval is how often a DBLinkQueue checks the database
+// for link requests and responses.
+const DefaultLinkQueuePollInterval = 250 * time.Millisecond
+
+// DBLinkQueue is a proto.LinkQueue that keeps link requests in the database,
+// next to their tokens. It lets the link generator and the link requester be
+// connected to different server instances as long as they share a database.
+//
+// Each instance polls the database and hands requests and responses to the
+// local channels returned by WaitLinkRequest.
+type DBLinkQueue struct {
+	db       db.DB
+	interval time.Duration
+	mu       sync.Mutex
+	links    map[charm.Token]*dbLink
+}
+
+// dbLink tracks a link request generated on this instance.
+type dbLink struct {
+	waiting bool
+	done    chan struct{}
+}
+
+// NewDBLinkQueue returns a DBLinkQueue that polls the given database at the
+// given interval. A zero interval uses DefaultLinkQueuePollInterval.
+func NewDBLinkQueue(d db.DB, interval time.Duration) *DBLinkQueue {
+	if interval <= 0 {
+		interval = DefaultLinkQueuePollInterval
+	}
+	return &DBLinkQueue{
+		db:       d,
+		interval: interval,
+		links:    make(map[charm.Token]*dbLink),
+	}
+}
+
+// InitLinkRequest implements the proto.LinkQueue interface for the
+// DBLinkQueue. The token itself is already stored by SSHServer.NewToken.
+func (q *DBLinkQueue) InitLinkRequest(t charm.Token) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.links[t]; !ok {
+		q.links[t] = &dbLink{done: make(chan struct{})}
+	}
+}
+
+// ValidateLinkRequest implements the proto.LinkQueue interface for the
+// DBLinkQueue. Tokens expire after charm.LinkTimeout and only accept a single
+// request.
+func (q *DBLinkQueue) ValidateLinkRequest(t charm.Token) bool {
+	r, err := q.db.GetLinkRecord(t)
+	if err != nil {
+		if !errors.Is(err, charm.ErrMissingToken) {
+			log.Error("Could not get link request", "err", err)
+		}
+		return false
+	}
+	return r.Request == "" && time.Since(r.CreatedAt) < charm.LinkTimeout
+}
+
+// WaitLinkRequest implements the proto.LinkQueue interface for the
+// DBLinkQueue. The first call for a token initialized on this instance
+// returns the generator's side of the link, every other call the requester's.
+func (q *DBLinkQueue) WaitLinkRequest(t charm.Token) (chan *charm.Link, error) {
+	q.mu.Lock()
+	l, ok := q.links[t]
+	generator := ok && !l.waiting
+	if generator {
+		l.waiting = true
+	}
+	q.mu.Unlock()
+
+	lc := make(chan *charm.Link)
+	if generator {
+		go q.receiveRequest(t, lc, l.done)
+		return lc, nil
+	}
+	if !q.ValidateLinkRequest(t) {
+		return nil, fmt.Errorf("no link request for token: %s", t)
+	}
+	go q.forwardRequest(t, lc)
+	return lc, nil
+}
+
+// SendLinkRequest implements the proto.LinkQueue interface for the
+// DBLinkQueue. It stores the response and blocks until the requester picked it
+// up, so the token isn't deleted before then.
+func (q *DBLinkQueue) SendLinkRequest(lt charm.LinkTransport, _ chan *charm.Link, l *charm.Link) {
+	b, err := json.Marshal(l)
+	if err == nil {
+		err = q.db.SetLinkResponse(l.Token, string(b))
+	}
+	if err != nil {
+		log.Error("Could not send link response", "token", l.Token, "err", err)
+		return
+	}
+	// The requester deletes the token once it has read the response.
+	_, err = q.poll(l.Token, nil, func(*db.LinkRecord) string { return "" })
+	if errors.Is(err, charm.ErrMissingToken) {
+		return
+	}
+	l.Status = charm.LinkStatusTimedOut
+	lt.TimedOut(l)
+}
+
+// DeleteLinkRequest implements the proto.LinkQueue interface for the
+// DBLinkQueue.
+func (q *DBLinkQueue) DeleteLinkRequest(t charm.Token) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if l, ok := q.links[t]; ok {
+		close(l.done)
+		delete(q.links, t)
+	}
+}
+
+// receiveRequest waits for a link request to show up in the database and
+// hands it to the link generator.
+func (q *DBLinkQueue) receiveRequest(t charm.Token, lc chan *charm.Link, done chan struct{}) {
+	l, err := q.poll(t, done, func(r *db.LinkRecord) string { return r.Request })
+	if err != nil {
+		log.Debug("No link request received", "token", t, "err", err)
+		return
+	}
+	select {
+	case lc <- l:
+	case <-done:
+	}
+}
+
+// forwardRequest stores the requester's link request in the database, waits
+// for the generator's response and hands it back to the requester.
+func (q *DBLinkQueue) forwardRequest(t charm.Token, lc chan *charm.Link) {
+	var l *charm.Link
+	select {
+	case l = <-lc:
+	case <-time.After(charm.LinkTimeout):
+		return
+	}
+	b, err := json.Marshal(l)
+	if err == nil {
+		err = q.db.SetLinkRequest(t, string(b))
+	}
+	var lr *charm.Link
+	if err == nil {
+		lr, err = q.poll(t, nil, func(r *db.LinkRecord) string { return r.Response })
+	}
+	if err != nil {
+		log.Error("Could not get link response", "token", t, "err", err)
+		lr = &charm.Link{Token: t, Status: charm.LinkStatusError}
+	}
+	// Let the generator know the response arrived.
+	if err := q.db.DeleteToken(t); err != nil {
+		log.Error("Could not delete token", "token", t, "err", err)
+	}
+	select {
+	case lc <- lr:
+	case <-time.After(charm.LinkTimeout):
+	}
+}
+
+// poll checks the link record for the given token until field returns a
+// value, done is closed or charm.LinkTimeout passes.
+func (q *DBLinkQueue) poll(t charm.Token, done chan struct{}, field func(*db.LinkRecord) string) (*charm.Link, error) {
+	tick := time.NewTicker(q.interval)
+	defer tick.Stop()
+	timeout := time.After(charm.LinkTimeout)
+	for {
+		r, err := q.db.GetLinkRecord(t)
+		if err != nil {
+			return nil, err
+		}
+		if v := field(r); v != "" {
+			l := &charm.Link{}
+			if err := json.Unmarshal([]byte(v), l); err != nil {
+				return nil, err
+			}
+			return l, nil
+		}
+		select {
+		case <-tick.C:
+		case <-done:
+			return nil, errors.New("link request deleted")
+		case <-timeout:
+			return nil, errors.New("link request timed out")
+		}
+	}
+}
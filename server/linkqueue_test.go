@@ -0,0 +1,79 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db/sqlite"
+)
+
+func TestDBLinkQueue(t *testing.T) {
+	d := sqlite.NewDB(filepath.Join(t.TempDir(), sqlite.DbName))
+	defer d.Close() // nolint:errcheck
+
+	// Two queues sharing a database act like two server instances.
+	gen := NewDBLinkQueue(d, 10*time.Millisecond)
+	req := NewDBLinkQueue(d, 10*time.Millisecond)
+
+	if _, err := req.WaitLinkRequest("NOPE00"); err == nil {
+		t.Fatal("expected an error for an unknown token")
+	}
+
+	tok := charm.Token("ABC123")
+	if err := d.SetToken(tok); err != nil {
+		t.Fatal(err)
+	}
+	gen.InitLinkRequest(tok)
+	defer gen.DeleteLinkRequest(tok)
+	gc, err := gen.WaitLinkRequest(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := req.WaitLinkRequest(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.ValidateLinkRequest(tok) {
+		t.Fatal("expected token to be valid")
+	}
+	go func() { rc <- &charm.Link{Token: tok, RequestPubKey: "key"} }()
+
+	var l *charm.Link
+	select {
+	case l = <-gc:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for link request")
+	}
+	if l.RequestPubKey != "key" {
+		t.Fatalf("unexpected link request: %+v", l)
+	}
+	if req.ValidateLinkRequest(tok) {
+		t.Fatal("expected token to only accept a single request")
+	}
+
+	l.Status = charm.LinkStatusSuccess
+	sent := make(chan struct{})
+	go func() {
+		gen.SendLinkRequest(nil, gc, l)
+		close(sent)
+	}()
+	select {
+	case lr := <-rc:
+		if lr.Status != charm.LinkStatusSuccess {
+			t.Fatalf("expected success, got status %d", lr.Status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for link response")
+	}
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the response to be picked up")
+	}
+	if _, err := d.GetLinkRecord(tok); err != charm.ErrMissingToken {
+		t.Fatalf("expected token to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+// ListPageSize is the most files returned by a page of a recursive listing.
+var ListPageSize = 1000
+
+// handleListFiles lists everything below a directory, at any depth, a page at
+// a time. The after query parameter is the Next of the previous page.
+func (s *HTTPServer) handleListFiles(w http.ResponseWriter, r *http.Request, u *charm.User, path string) {
+	// Ask for one more than a page to know if there's another one.
+	fis, err := s.cfg.FileStore.List(u.CharmID, path, r.URL.Query().Get("after"), ListPageSize+1)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.renderCustomError(w, "directory not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("cannot list files", "err", err)
+		s.renderError(w)
+		return
+	}
+	l := charm.FileListing{Files: fis}
+	if len(fis) > ListPageSize {
+		l.Files = fis[:ListPageSize]
+		l.Next = storage.ListKey(l.Files[len(l.Files)-1])
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l)
+}
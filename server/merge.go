@@ -0,0 +1,99 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+// mergeAccounts merges the account from into the account into. Files are
+// copied first, then the keys, named sequences and trash items are merged in
+// the database and finally the files of the merged account are deleted. If
+// anything fails before the database merge both accounts are left intact.
+// Unfinished uploads of the merged account are dropped, its clients start
+// them over.
+func (me *SSHServer) mergeAccounts(into *charm.User, from *charm.User) (*charm.MergeReport, error) {
+	files := &charm.MergeReport{}
+	if err := mergeFiles(me.config.FileStore, into.CharmID, from.CharmID, files); err != nil {
+		return nil, fmt.Errorf("could not merge files: %w", err)
+	}
+	trash, err := me.db.GetTrashItems(from)
+	if err != nil {
+		return nil, err
+	}
+	r, err := me.db.MergeUsers(into.ID, from.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range trash {
+		mergeTrashItem(me.config.FileStore, into.CharmID, item)
+	}
+	me.config.resetStorageUsed(into)
+	r.FromID = from.CharmID
+	r.IntoID = into.CharmID
+	r.Files = files.Files
+	r.Bytes = files.Bytes
+	r.Conflicts = append(files.Conflicts, r.Conflicts...)
+	if err := me.config.FileStore.Delete(from.CharmID, ""); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Error("cannot delete merged account files", "id", from.CharmID, "err", err)
+	}
+	for _, ns := range []string{uploadsID, trashID} {
+		if err := me.config.FileStore.Delete(ns, from.CharmID); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error("cannot delete merged account files", "id", from.CharmID, "namespace", ns, "err", err)
+		}
+	}
+	return r, nil
+}
+
+// mergeFiles copies every file of fromID to intoID. When a file exists in
+// both accounts the most recently modified copy is kept.
+func mergeFiles(fstore storage.FileStore, intoID string, fromID string, r *charm.MergeReport) error {
+	return storage.Walk(fstore, fromID, "/", func(path string, info fs.FileInfo) error {
+		existing, err := fstore.Stat(intoID, path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err == nil {
+			c := charm.MergeConflict{Kind: charm.MergeConflictFile, Name: path, Kept: charm.MergeKeptExisting}
+			if !info.ModTime().After(existing.ModTime()) {
+				r.Conflicts = append(r.Conflicts, c)
+				return nil
+			}
+			c.Kept = charm.MergeKeptMerged
+			r.Conflicts = append(r.Conflicts, c)
+		}
+		f, err := fstore.Get(fromID, path)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // nolint:errcheck
+		// Conflicts are settled by modification time, keep it.
+		if err := fstore.Put(intoID, path, f, info.Mode(), info.ModTime()); err != nil {
+			return err
+		}
+		r.Files++
+		r.Bytes += info.Size()
+		return nil
+	})
+}
+
+// mergeTrashItem moves the files of a merged account's trash item to the
+// trash of the account it was merged into, which the item now belongs to.
+func mergeTrashItem(fstore storage.FileStore, intoID string, item *charm.TrashItem) {
+	merged := *item
+	merged.CharmID = intoID
+	moves := [][2]string{
+		{trashDir(item), trashDir(&merged)},
+		{trashVersionsDir(item), trashVersionsDir(&merged)},
+	}
+	for _, m := range moves {
+		if err := fstore.Move(trashID, m[0], trashID, m[1]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error("cannot move merged trash item", "id", item.ID, "err", err)
+		}
+	}
+}
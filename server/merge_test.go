@@ -0,0 +1,111 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db/dbtest"
+	"github.com/charmbracelet/charm/server/db/sqlite"
+	localstorage "github.com/charmbracelet/charm/server/storage/local"
+)
+
+func TestMergeAccounts(t *testing.T) {
+	td := t.TempDir()
+	d := sqlite.NewDB(filepath.Join(td, sqlite.DbName))
+	defer d.Close() // nolint:errcheck
+	fstore, err := localstorage.NewLocalFileStore(filepath.Join(td, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	me := &SSHServer{config: &Config{DB: d, FileStore: fstore}, db: d}
+
+	into := dbtest.NewUser(t, d)
+	from := dbtest.NewUser(t, d)
+	put := func(u *charm.User, path string, content string) {
+		if err := fstore.Put(u.CharmID, path, bytes.NewBufferString(content), 0o644, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(into, "/kept", "into")
+	put(from, "/kept", "from")
+	put(from, "/dir/moved", "moved")
+	put(from, "/dir/sub/moved", "moved too")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := fstore.Chtimes(from.CharmID, "/dir/moved", mtime); err != nil {
+		t.Fatal(err)
+	}
+	put(from, "/trashed", "trashed")
+	if _, err := me.config.trashFile(from, "/trashed"); err != nil {
+		t.Fatal(err)
+	}
+	// Make the conflicting file in the surviving account the newest.
+	time.Sleep(10 * time.Millisecond)
+	put(into, "/kept", "into")
+	if _, err := d.NextSeq(from, "seq"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := me.mergeAccounts(into, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.FromID != from.CharmID || r.IntoID != into.CharmID {
+		t.Fatalf("unexpected report ids: %+v", r)
+	}
+	if r.Files != 2 || r.Bytes != int64(len("moved")+len("moved too")) {
+		t.Fatalf("expected 2 moved files, got %d (%d bytes)", r.Files, r.Bytes)
+	}
+	if r.Seqs != 1 {
+		t.Fatalf("expected 1 moved seq, got %d", r.Seqs)
+	}
+	if len(r.Conflicts) != 1 || r.Conflicts[0].Name != "/kept" || r.Conflicts[0].Kept != charm.MergeKeptExisting {
+		t.Fatalf("unexpected conflicts: %+v", r.Conflicts)
+	}
+
+	for path, want := range map[string]string{
+		"/kept":          "into",
+		"/dir/moved":     "moved",
+		"/dir/sub/moved": "moved too",
+	} {
+		f, err := fstore.Get(into.CharmID, path)
+		if err != nil {
+			t.Fatalf("get %s: %s", path, err)
+		}
+		b, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Fatalf("expected %s to contain %q, got %q", path, want, string(b))
+		}
+	}
+	if fi, err := fstore.Stat(into.CharmID, "/dir/moved"); err != nil || !fi.ModTime().Equal(mtime) {
+		t.Fatalf("expected merged file to keep its modification time %s, got %v (%v)", mtime, fi, err)
+	}
+	items, err := d.GetTrashItems(into)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Path != "/trashed" {
+		t.Fatalf("expected the merged trash item, got %+v", items)
+	}
+	if err := restoreTrashItem(me.config, items[0]); err != nil {
+		t.Fatalf("restore merged trash item: %s", err)
+	}
+	if _, err := fstore.Stat(into.CharmID, "/trashed"); err != nil {
+		t.Fatalf("expected restored trash item: %s", err)
+	}
+	if _, err := fstore.Stat(from.CharmID, ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected merged account files to be deleted, got %v", err)
+	}
+	if _, err := d.GetUserWithID(from.CharmID); !errors.Is(err, charm.ErrMissingUser) {
+		t.Fatalf("expected merged account to be deleted, got %v", err)
+	}
+}
@@ -29,7 +29,7 @@ func RequestLimitMiddleware() func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			var maxRequestSize int64
-			if strings.HasPrefix(r.URL.Path, "/v1/fs") {
+			if strings.HasPrefix(r.URL.Path, "/v1/fs") || strings.HasPrefix(r.URL.Path, "/v1/uploads/") {
 				maxRequestSize = MaxFSRequestSize
 			} else {
 				maxRequestSize = 1024 * 1024 // limit request size to 1MB for other endpoints
@@ -104,6 +104,10 @@ func CharmUserMiddleware(s *HTTPServer) func(http.Handler) http.Handler {
 					s.renderError(w)
 					return
 				}
+				if u.Suspended {
+					s.renderCustomError(w, charm.ErrAccountSuspended.Error(), http.StatusForbidden)
+					return
+				}
 				ctx := context.WithValue(r.Context(), ctxUserKey, u)
 				h.ServeHTTP(w, r.WithContext(ctx))
 			}
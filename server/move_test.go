@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/charm/server/db/dbtest"
+	"github.com/charmbracelet/charm/server/db/sqlite"
+	"github.com/charmbracelet/charm/server/storage"
+	localstorage "github.com/charmbracelet/charm/server/storage/local"
+)
+
+// failingMoveStore fails moves within a user's files.
+type failingMoveStore struct {
+	storage.FileStore
+}
+
+func (s failingMoveStore) Move(fromID string, from string, toID string, to string) error {
+	if fromID == toID && fromID[0] != '.' {
+		return errors.New("move failed")
+	}
+	return s.FileStore.Move(fromID, from, toID, to)
+}
+
+func TestReplaceFile(t *testing.T) {
+	td := t.TempDir()
+	d := sqlite.NewDB(filepath.Join(td, sqlite.DbName))
+	defer d.Close() // nolint:errcheck
+	fstore, err := localstorage.NewLocalFileStore(filepath.Join(td, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := dbtest.NewUser(t, d)
+	s := &HTTPServer{cfg: &Config{DB: d, FileStore: fstore}}
+	put := func(path string, content string) {
+		if err := fstore.Put(u.CharmID, path, bytes.NewBufferString(content), 0o644, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(path string) string {
+		f, err := fstore.Get(u.CharmID, path)
+		if err != nil {
+			t.Fatalf("get %s: %s", path, err)
+		}
+		defer f.Close() // nolint:errcheck
+		b, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	put("/src", "new")
+	put("/dest", "old")
+
+	// A failed move leaves the destination as it was.
+	s.cfg.FileStore = failingMoveStore{fstore}
+	if err := s.replaceFile(u, "/src", "/dest"); err == nil {
+		t.Fatal("expected the move to fail")
+	}
+	if got := read("/dest"); got != "old" {
+		t.Fatalf("expected the destination to be kept, got %q", got)
+	}
+
+	s.cfg.FileStore = fstore
+	if err := s.replaceFile(u, "/src", "/dest"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("/dest"); got != "new" {
+		t.Fatalf("expected the destination to be replaced, got %q", got)
+	}
+	items, err := d.GetTrashItems(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("expected the replaced file to be purged without a trash, got %+v", items)
+	}
+}
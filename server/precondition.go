@@ -0,0 +1,124 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+// pathLocks serializes writes, moves and deletes of the same path, so a
+// conditional write or an overwriting move can't race with another change
+// between checking the file and replacing it. They're shared by everything
+// serving a Config; a nil *pathLocks locks nothing.
+type pathLocks struct {
+	mu    sync.Mutex
+	locks map[string]*pathLock
+}
+
+type pathLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the keys and returns the function unlocking them. Keys are
+// locked in order, so locking several can't deadlock with another request
+// locking some of the same ones.
+func (pl *pathLocks) lock(keys ...string) func() {
+	if pl == nil {
+		return func() {}
+	}
+	keys = append([]string(nil), keys...)
+	sort.Strings(keys)
+	unlocks := make([]func(), 0, len(keys))
+	for i, key := range keys {
+		if i > 0 && key == keys[i-1] {
+			continue
+		}
+		unlocks = append(unlocks, pl.lockKey(key))
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// lockKey locks the key and returns the function unlocking it.
+func (pl *pathLocks) lockKey(key string) func() {
+	pl.mu.Lock()
+	if pl.locks == nil {
+		pl.locks = make(map[string]*pathLock)
+	}
+	l, ok := pl.locks[key]
+	if !ok {
+		l = &pathLock{}
+		pl.locks[key] = l
+	}
+	l.refs++
+	pl.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		pl.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(pl.locks, key)
+		}
+		pl.mu.Unlock()
+	}
+}
+
+// checkPreconditions renders a 412 Precondition Failed error and returns
+// false if the file at path doesn't satisfy the request's If-Match or
+// If-None-Match header. Either can be "*", which matches any existing file.
+func (s *HTTPServer) checkPreconditions(w http.ResponseWriter, r *http.Request, u *charm.User, path string) bool {
+	im := r.Header.Get("If-Match")
+	inm := r.Header.Get("If-None-Match")
+	if im == "" && inm == "" {
+		return true
+	}
+	etag := ""
+	fi, err := s.cfg.FileStore.Stat(u.CharmID, path)
+	switch {
+	case err == nil:
+		etag = storage.ETag(fi)
+	case !errors.Is(err, fs.ErrNotExist):
+		log.Error("cannot stat file", "err", err)
+		s.renderError(w)
+		return false
+	}
+	if im != "" && !etagMatch(im, etag) {
+		s.renderCustomError(w, "file has changed", http.StatusPreconditionFailed)
+		return false
+	}
+	if inm != "" && etagMatch(inm, etag) {
+		s.renderCustomError(w, "file already exists", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// etagMatch reports whether a list of entity tags from an If-Match or
+// If-None-Match header matches etag. An empty etag means there's no file and
+// matches nothing.
+func etagMatch(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/db"
+	"github.com/charmbracelet/ssh"
+	"github.com/muesli/toktok"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Registration modes decide which new keys get an account.
+const (
+	// RegistrationOpen creates an account for any key that connects.
+	RegistrationOpen = "open"
+	// RegistrationInvite creates accounts for allowlisted keys and for keys
+	// that redeem an invite code.
+	RegistrationInvite = "invite"
+	// RegistrationAllowlist only creates accounts for allowlisted keys.
+	RegistrationAllowlist = "allowlist"
+)
+
+// inviteCodeLength is the length of generated invite codes.
+const inviteCodeLength = 10
+
+func validateRegistration(cfg *Config) error {
+	switch cfg.Registration {
+	case "", RegistrationOpen, RegistrationInvite, RegistrationAllowlist:
+	default:
+		return fmt.Errorf("unknown registration mode %q, expected %s, %s or %s", cfg.Registration, RegistrationOpen, RegistrationInvite, RegistrationAllowlist)
+	}
+	for _, a := range cfg.RegistrationAllowlist {
+		a = strings.TrimSpace(a)
+		if strings.HasPrefix(a, "SHA256:") {
+			continue
+		}
+		if _, _, _, _, err := gossh.ParseAuthorizedKey([]byte(a)); err != nil {
+			return fmt.Errorf("invalid registration allowlist entry %q: %w", a, err)
+		}
+	}
+	return nil
+}
+
+// canRegister reports whether a new account can be created for the key
+// without an invite.
+func (cfg *Config) canRegister(key string) bool {
+	if cfg.Registration == RegistrationOpen || cfg.Registration == "" {
+		return true
+	}
+	fp := keyFingerprint(key)
+	if fp == "" {
+		return false
+	}
+	for _, a := range cfg.RegistrationAllowlist {
+		a = strings.TrimSpace(a)
+		if a == fp || keyFingerprint(a) == fp {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccess returns an error if the session's key may not run the command,
+// either because its account is suspended or because it doesn't have an
+// account and the server doesn't let it register. Keys without an account can
+// always redeem invites and request to be linked to an existing account.
+func (me *SSHServer) checkAccess(s ssh.Session) error {
+	key, err := keyText(s)
+	if err != nil {
+		return nil
+	}
+	u, err := me.db.UserForKey(key, false)
+	if err == nil {
+		if u.Suspended {
+			return charm.ErrAccountSuspended
+		}
+		return nil
+	}
+	if err != charm.ErrMissingUser || me.config.canRegister(key) {
+		return nil
+	}
+	switch cmd := s.Command(); cmd[0] {
+	case "api-invite":
+		return nil
+	case "api-link":
+		if len(cmd) > 1 {
+			return nil
+		}
+	}
+	return charm.ErrRegistrationClosed
+}
+
+// NewInvite creates an invite code. Invites with a zero ttl never expire.
+func NewInvite(d db.DB, ttl time.Duration) (*charm.Invite, error) {
+	var ea *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		ea = &t
+	}
+	code := toktok.GenerateToken(inviteCodeLength, []rune("ABCDEFHJKLMNPRSTUWXY369"))
+	if err := d.CreateInvite(code, ea); err != nil {
+		return nil, err
+	}
+	return &charm.Invite{Code: code, ExpiresAt: ea}, nil
+}
+
+func (me *SSHServer) handleAPIInvite(s ssh.Session) {
+	key, err := keyText(s)
+	if err != nil {
+		log.Print(err)
+		_ = me.sendAPIMessage(s, "Missing key")
+		return
+	}
+	if len(s.Command()) < 2 {
+		_ = me.sendAPIMessage(s, "Missing invite code")
+		return
+	}
+	if me.config.Registration == RegistrationAllowlist {
+		_ = me.sendAPIMessage(s, charm.ErrRegistrationClosed.Error())
+		return
+	}
+	code := strings.ToUpper(s.Command()[1])
+	u, err := me.db.RedeemInvite(code, key)
+	if err == charm.ErrInvalidInvite || err == charm.ErrUserExists {
+		_ = me.sendAPIMessage(s, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("Error redeeming invite", "err", err)
+		_ = me.sendAPIMessage(s, fmt.Sprintf("Error redeeming invite: %s", err))
+		return
+	}
+	log.Info("Redeemed invite", "id", u.CharmID)
+	audit(me.db, u, charm.AuditEventInvite, key, s.RemoteAddr().String(), "")
+	me.config.Stats.APIInvite()
+}
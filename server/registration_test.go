@@ -0,0 +1,54 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/keygen"
+)
+
+func TestCanRegister(t *testing.T) {
+	kp, err := keygen.New("", keygen.WithKeyType(keygen.Ed25519))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := keygen.New("", keygen.WithKeyType(keygen.Ed25519))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := string(kp.AuthorizedKey())
+	fp := keyFingerprint(key)
+
+	cases := []struct {
+		name      string
+		mode      string
+		allowlist []string
+		want      bool
+	}{
+		{"default", "", nil, true},
+		{"open", RegistrationOpen, nil, true},
+		{"invite", RegistrationInvite, nil, false},
+		{"allowlist empty", RegistrationAllowlist, nil, false},
+		{"allowlist key", RegistrationAllowlist, []string{key + "\n"}, true},
+		{"allowlist fingerprint", RegistrationInvite, []string{" " + fp}, true},
+		{"allowlist other key", RegistrationAllowlist, []string{string(other.AuthorizedKey())}, false},
+	}
+	for _, c := range cases {
+		cfg := &Config{Registration: c.mode, RegistrationAllowlist: c.allowlist}
+		if err := validateRegistration(cfg); err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if got := cfg.canRegister(key); got != c.want {
+			t.Errorf("%s: expected %t, got %t", c.name, c.want, got)
+		}
+	}
+	if cfg := (&Config{Registration: RegistrationAllowlist}); cfg.canRegister("not a key") {
+		t.Error("expected an invalid key to be refused")
+	}
+
+	if err := validateRegistration(&Config{Registration: "closed"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if err := validateRegistration(&Config{Registration: RegistrationAllowlist, RegistrationAllowlist: []string{"nope"}}); err == nil {
+		t.Error("expected an error for an invalid allowlist entry")
+	}
+}
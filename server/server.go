@@ -9,16 +9,21 @@ import (
 	glog "log"
 	"net/url"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	env "github.com/caarlos0/env/v6"
 	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/charm/server/db"
+	"github.com/charmbracelet/charm/server/db/postgres"
 	"github.com/charmbracelet/charm/server/db/sqlite"
 	"github.com/charmbracelet/charm/server/stats"
 	"github.com/charmbracelet/charm/server/stats/noop"
 	"github.com/charmbracelet/charm/server/stats/prometheus"
 	"github.com/charmbracelet/charm/server/storage"
 	lfs "github.com/charmbracelet/charm/server/storage/local"
+	s3fs "github.com/charmbracelet/charm/server/storage/s3"
 	"github.com/charmbracelet/log"
 	gossh "golang.org/x/crypto/ssh"
 	"golang.org/x/sync/errgroup"
@@ -33,35 +38,76 @@ type Config struct {
 	StatsPort      int    `env:"CHARM_SERVER_STATS_PORT" envDefault:"35355"`
 	HealthPort     int    `env:"CHARM_SERVER_HEALTH_PORT" envDefault:"35356"`
 	DataDir        string `env:"CHARM_SERVER_DATA_DIR" envDefault:"data"`
+	DBDSN          string `env:"CHARM_SERVER_DB_DSN"`
 	UseTLS         bool   `env:"CHARM_SERVER_USE_TLS" envDefault:"false"`
 	TLSKeyFile     string `env:"CHARM_SERVER_TLS_KEY_FILE"`
 	TLSCertFile    string `env:"CHARM_SERVER_TLS_CERT_FILE"`
 	PublicURL      string `env:"CHARM_SERVER_PUBLIC_URL"`
 	EnableMetrics  bool   `env:"CHARM_SERVER_ENABLE_METRICS" envDefault:"false"`
 	UserMaxStorage int64  `env:"CHARM_SERVER_USER_MAX_STORAGE" envDefault:"0"`
-	errorLog       *glog.Logger
-	PublicKey      []byte
-	PrivateKey     []byte
-	DB             db.DB
-	FileStore      storage.FileStore
-	Stats          stats.Stats
-	linkQueue      charm.LinkQueue
-	tlsConfig      *tls.Config
-	jwtKeyPair     JSONWebKeyPair
-	httpScheme     string
+	S3Endpoint     string `env:"CHARM_SERVER_S3_ENDPOINT"`
+	S3Bucket       string `env:"CHARM_SERVER_S3_BUCKET"`
+	S3Region       string `env:"CHARM_SERVER_S3_REGION" envDefault:"us-east-1"`
+	S3AccessKey    string `env:"CHARM_SERVER_S3_ACCESS_KEY_ID"`
+	S3SecretKey    string `env:"CHARM_SERVER_S3_SECRET_ACCESS_KEY"`
+	S3PathStyle    bool   `env:"CHARM_SERVER_S3_PATH_STYLE" envDefault:"true"`
+	// SharedLinkQueue keeps pending link requests in the database instead of
+	// memory, which is needed when several instances share a database.
+	SharedLinkQueue bool `env:"CHARM_SERVER_SHARED_LINK_QUEUE" envDefault:"false"`
+	// Registration decides which new keys get an account: open, invite or
+	// allowlist. RegistrationAllowlist holds authorized keys or SHA256
+	// fingerprints that can always register.
+	Registration          string   `env:"CHARM_SERVER_REGISTRATION" envDefault:"open"`
+	RegistrationAllowlist []string `env:"CHARM_SERVER_REGISTRATION_ALLOWLIST" envSeparator:","`
+	// StorageReconcileInterval is how often the cached storage usage of every
+	// user is recomputed from the file store. Zero disables reconciliation.
+	StorageReconcileInterval time.Duration `env:"CHARM_SERVER_STORAGE_RECONCILE_INTERVAL" envDefault:"24h"`
+	// FileVersions is the number of previous versions kept per file when it's
+	// overwritten. Versions replaced longer ago than FileVersionMaxAge are
+	// deleted as well, unless it's zero. Versions count toward the user's
+	// storage quota. Zero disables versions.
+	FileVersions      int           `env:"CHARM_SERVER_FILE_VERSIONS" envDefault:"0"`
+	FileVersionMaxAge time.Duration `env:"CHARM_SERVER_FILE_VERSION_MAX_AGE" envDefault:"0"`
+	// TrashRetention is how long deleted files are kept in the user's trash
+	// before they're purged. Trash counts toward the user's storage quota,
+	// so it's off by default: zero disables the trash and deletes files right
+	// away.
+	TrashRetention time.Duration `env:"CHARM_SERVER_TRASH_RETENTION" envDefault:"0"`
+	// UploadExpiry is how long an uncommitted chunked upload is kept before
+	// its chunks are deleted.
+	UploadExpiry time.Duration `env:"CHARM_SERVER_UPLOAD_EXPIRY" envDefault:"24h"`
+	// LinkMaxAttempts is the number of failed link attempts allowed per IP
+	// address and per public key within LinkAttemptWindow, counted from the
+	// first failed attempt. Each server process counts attempts on its own.
+	// Zero disables the limit.
+	LinkMaxAttempts   int           `env:"CHARM_SERVER_LINK_MAX_ATTEMPTS" envDefault:"5"`
+	LinkAttemptWindow time.Duration `env:"CHARM_SERVER_LINK_ATTEMPT_WINDOW" envDefault:"15m"`
+	errorLog          *glog.Logger
+	PublicKey         []byte
+	PrivateKey        []byte
+	DB                db.DB
+	FileStore         storage.FileStore
+	Stats             stats.Stats
+	linkQueue         charm.LinkQueue
+	tlsConfig         *tls.Config
+	jwtKeyPair        JSONWebKeyPair
+	httpScheme        string
+	locks             *pathLocks
 }
 
 // Server contains the SSH and HTTP servers required to host the Charm Cloud.
 type Server struct {
-	Config *Config
-	ssh    *SSHServer
-	http   *HTTPServer
+	Config    *Config
+	ssh       *SSHServer
+	http      *HTTPServer
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // DefaultConfig returns a Config with the values populated with the defaults
 // or specified environment variables.
 func DefaultConfig() *Config {
-	cfg := &Config{httpScheme: "http"}
+	cfg := &Config{httpScheme: "http", locks: &pathLocks{}}
 	if err := env.Parse(cfg); err != nil {
 		log.Fatal("could not read environment", "err", err)
 	}
@@ -127,8 +173,14 @@ func (cfg *Config) httpURL() *url.URL {
 
 // NewServer returns a *Server with the specified Config.
 func NewServer(cfg *Config) (*Server, error) {
-	s := &Server{Config: cfg}
+	s := &Server{Config: cfg, done: make(chan struct{})}
 	s.init(cfg)
+	if err := CheckSchemaVersion(cfg.DB); err != nil {
+		return nil, err
+	}
+	if err := validateRegistration(cfg); err != nil {
+		return nil, err
+	}
 
 	pk, err := gossh.ParseRawPrivateKey(cfg.PrivateKey)
 	if err != nil {
@@ -162,11 +214,15 @@ func (srv *Server) Start() error {
 	errg.Go(func() error {
 		return srv.ssh.Start()
 	})
+	go srv.every(srv.Config.StorageReconcileInterval, srv.reconcileStorage)
+	go srv.every(time.Hour, srv.expireUploads)
+	go srv.every(time.Hour, srv.purgeTrash)
 	return errg.Wait()
 }
 
 // Shutdown shuts down the HTTP, and SSH and health HTTP servers for the Charm Cloud.
 func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.stop()
 	if srv.Config.Stats != nil {
 		if err := srv.Config.Stats.Shutdown(ctx); err != nil {
 			return err
@@ -180,6 +236,7 @@ func (srv *Server) Shutdown(ctx context.Context) error {
 
 // Close immediately closes all active net.Listeners for the HTTP, HTTP health and SSH servers.
 func (srv *Server) Close() error {
+	srv.stop()
 	herr := srv.http.server.Close()
 	hherr := srv.http.health.Close()
 	serr := srv.ssh.server.Close()
@@ -199,19 +256,20 @@ func (srv *Server) Close() error {
 }
 
 func (srv *Server) init(cfg *Config) {
+	if cfg.locks == nil {
+		cfg.locks = &pathLocks{}
+	}
 	if cfg.DB == nil {
-		dp := filepath.Join(cfg.DataDir, "db")
-		err := storage.EnsureDir(dp, 0o700)
+		db, err := OpenDB(cfg)
 		if err != nil {
-			log.Fatal("could not init sqlite path", "err", err)
+			log.Fatal("could not open db", "err", err)
 		}
-		db := sqlite.NewDB(filepath.Join(dp, sqlite.DbName))
 		srv.Config = cfg.WithDB(db)
 	}
 	if cfg.FileStore == nil {
-		fs, err := lfs.NewLocalFileStore(filepath.Join(cfg.DataDir, "files"))
+		fs, err := OpenFileStore(cfg)
 		if err != nil {
-			log.Fatal("could not init file path", "err", err)
+			log.Fatal("could not init file store", "err", err)
 		}
 		srv.Config = cfg.WithFileStore(fs)
 	}
@@ -220,6 +278,73 @@ func (srv *Server) init(cfg *Config) {
 	}
 }
 
+// OpenDB opens the database configured in the Config. A PostgreSQL database is
+// used when CHARM_SERVER_DB_DSN is a postgres:// URL, otherwise a SQLite
+// database is created in the data directory.
+func OpenDB(cfg *Config) (db.DB, error) {
+	switch {
+	case cfg.DBDSN == "":
+		dp := filepath.Join(cfg.DataDir, "db")
+		if err := storage.EnsureDir(dp, 0o700); err != nil {
+			return nil, fmt.Errorf("could not init sqlite path: %w", err)
+		}
+		return sqlite.NewDB(filepath.Join(dp, sqlite.DbName)), nil
+	case strings.HasPrefix(cfg.DBDSN, "postgres://"), strings.HasPrefix(cfg.DBDSN, "postgresql://"):
+		return postgres.NewDB(cfg.DBDSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported database dsn, expected a postgres:// url")
+	}
+}
+
+// OpenFileStore opens the FileStore configured in the Config. Files are
+// stored in an S3-compatible bucket when CHARM_SERVER_S3_BUCKET is set,
+// otherwise they're stored in the data directory. Previous versions of files
+// are kept when CHARM_SERVER_FILE_VERSIONS is set.
+func OpenFileStore(cfg *Config) (storage.FileStore, error) {
+	var fstore storage.FileStore
+	var err error
+	if cfg.S3Bucket != "" {
+		fstore, err = s3fs.NewS3FileStore(s3fs.Config{
+			Endpoint:        cfg.S3Endpoint,
+			Bucket:          cfg.S3Bucket,
+			Region:          cfg.S3Region,
+			AccessKeyID:     cfg.S3AccessKey,
+			SecretAccessKey: cfg.S3SecretKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+	} else {
+		fstore, err = lfs.NewLocalFileStore(filepath.Join(cfg.DataDir, "files"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.FileVersions > 0 {
+		fstore = storage.NewVersionedFileStore(fstore, cfg.FileVersions, cfg.FileVersionMaxAge)
+	}
+	return fstore, nil
+}
+
+// CheckSchemaVersion returns an error if the database has pending migrations.
+func CheckSchemaVersion(d db.DB) error {
+	md, ok := d.(db.MigratableDB)
+	if !ok {
+		return nil
+	}
+	m := md.Migrator()
+	pending, err := m.Pending()
+	if err != nil {
+		return fmt.Errorf("could not read db schema version: %w", err)
+	}
+	if len(pending) > 0 {
+		v, err := m.Version()
+		if err != nil {
+			return fmt.Errorf("could not read db schema version: %w", err)
+		}
+		return fmt.Errorf("database schema is at version %04d but %04d is required, run `charm serve migrate` to apply %d pending migration(s)", v, m.Latest(), len(pending))
+	}
+	return nil
+}
+
 func getStatsImpl(cfg *Config) stats.Stats {
 	if cfg.EnableMetrics {
 		return prometheus.NewStats(cfg.DB, cfg.StatsPort)
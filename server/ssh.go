@@ -8,6 +8,7 @@ import (
 	glog "log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -32,19 +33,21 @@ type SessionHandler func(s Session)
 // SSHServer serves the SSH protocol and handles requests to authenticate and
 // link Charm user accounts.
 type SSHServer struct {
-	config    *Config
-	db        db.DB
-	server    *ssh.Server
-	errorLog  *glog.Logger
-	linkQueue charm.LinkQueue
+	config       *Config
+	db           db.DB
+	server       *ssh.Server
+	errorLog     *glog.Logger
+	linkQueue    charm.LinkQueue
+	linkAttempts *attemptLimiter
 }
 
 // NewSSHServer creates a new SSHServer from the provided Config.
 func NewSSHServer(cfg *Config) (*SSHServer, error) {
 	s := &SSHServer{
-		config:    cfg,
-		errorLog:  cfg.errorLog,
-		linkQueue: cfg.linkQueue,
+		config:       cfg,
+		errorLog:     cfg.errorLog,
+		linkQueue:    cfg.linkQueue,
+		linkAttempts: newAttemptLimiter(cfg.LinkMaxAttempts, cfg.LinkAttemptWindow),
 	}
 
 	if s.errorLog == nil {
@@ -54,10 +57,19 @@ func NewSSHServer(cfg *Config) (*SSHServer, error) {
 	}
 	addr := fmt.Sprintf("%s:%d", cfg.BindAddr, cfg.SSHPort)
 	s.db = cfg.DB
+	if n, err := s.db.DeleteTokensBefore(time.Now().Add(-charm.LinkTimeout)); err != nil {
+		log.Error("could not delete stale link tokens", "err", err)
+	} else if n > 0 {
+		log.Debug("Deleted stale link tokens", "count", n)
+	}
+	if s.linkQueue == nil && cfg.SharedLinkQueue {
+		s.linkQueue = NewDBLinkQueue(cfg.DB, 0)
+	}
 	if s.linkQueue == nil {
 		s.linkQueue = &channelLinkQueue{
 			s:            s,
 			linkRequests: make(map[charm.Token]chan *charm.Link),
+			createdAt:    make(map[charm.Token]time.Time),
 		}
 	}
 	opts := []ssh.Option{
@@ -135,6 +147,7 @@ func (me *SSHServer) handleJWT(s ssh.Session) {
 		return
 	}
 	_, _ = s.Write([]byte(j))
+	audit(me.db, u, charm.AuditEventJWT, key, s.RemoteAddr().String(), "audience "+strings.Join(aud, ", "))
 	me.config.Stats.JWT()
 }
 
@@ -16,6 +16,8 @@ var _ stats.Stats = Stats{}
 func (Stats) APILinkGen()                      {}
 func (Stats) APILinkRequest()                  {}
 func (Stats) APIUnlink()                       {}
+func (Stats) APIDeleteAccount()                {}
+func (Stats) APIInvite()                       {}
 func (Stats) APIAuth()                         {}
 func (Stats) APIKeys()                         {}
 func (Stats) LinkGen()                         {}
@@ -29,6 +31,8 @@ func (Stats) SetUserName()                     {}
 func (Stats) GetNewsList()                     {}
 func (Stats) GetNews()                         {}
 func (Stats) PostNews()                        {}
+func (Stats) GetActivity()                     {}
+func (Stats) GetUsage()                        {}
 func (Stats) FSFileRead(_ string, _ int64)     {}
 func (Stats) FSFileWritten(_ string, _ int64)  {}
 func (Stats) Start() error                     { return nil }
@@ -15,31 +15,35 @@ import (
 
 // Stats contains all of the calls to track metrics.
 type Stats struct {
-	apiLinkGenCalls     prometheus.Counter
-	apiLinkRequestCalls prometheus.Counter
-	apiUnlinkCalls      prometheus.Counter
-	apiAuthCalls        prometheus.Counter
-	apiKeysCalls        prometheus.Counter
-	linkGenCalls        prometheus.Counter
-	linkRequestCalls    prometheus.Counter
-	keysCalls           prometheus.Counter
-	idCalls             prometheus.Counter
-	jwtCalls            prometheus.Counter
-	getUserByIDCalls    prometheus.Counter
-	getUserCalls        prometheus.Counter
-	setUserNameCalls    prometheus.Counter
-	getNews             prometheus.Counter
-	postNews            prometheus.Counter
-	getNewsList         prometheus.Counter
-	fsBytesRead         *prometheus.CounterVec
-	fsBytesWritten      *prometheus.CounterVec
-	fsReads             *prometheus.CounterVec
-	fsWritten           *prometheus.CounterVec
-	users               prometheus.Gauge
-	userNames           prometheus.Gauge
-	db                  db.DB
-	port                int
-	server              *http.Server
+	apiLinkGenCalls       prometheus.Counter
+	apiLinkRequestCalls   prometheus.Counter
+	apiUnlinkCalls        prometheus.Counter
+	apiDeleteAccountCalls prometheus.Counter
+	apiInviteCalls        prometheus.Counter
+	apiAuthCalls          prometheus.Counter
+	apiKeysCalls          prometheus.Counter
+	linkGenCalls          prometheus.Counter
+	linkRequestCalls      prometheus.Counter
+	keysCalls             prometheus.Counter
+	idCalls               prometheus.Counter
+	jwtCalls              prometheus.Counter
+	getUserByIDCalls      prometheus.Counter
+	getUserCalls          prometheus.Counter
+	setUserNameCalls      prometheus.Counter
+	getNews               prometheus.Counter
+	postNews              prometheus.Counter
+	getNewsList           prometheus.Counter
+	getActivity           prometheus.Counter
+	getUsage              prometheus.Counter
+	fsBytesRead           *prometheus.CounterVec
+	fsBytesWritten        *prometheus.CounterVec
+	fsReads               *prometheus.CounterVec
+	fsWritten             *prometheus.CounterVec
+	users                 prometheus.Gauge
+	userNames             prometheus.Gauge
+	db                    db.DB
+	port                  int
+	server                *http.Server
 }
 
 // Start starts the PrometheusStats HTTP server.
@@ -92,31 +96,35 @@ func NewStats(db db.DB, port int) *Stats {
 
 	fsLabels := []string{"charm_id"}
 	return &Stats{
-		apiLinkGenCalls:     newCounter("charm_id_api_link_gen_total", "Total API link gen calls"),
-		apiLinkRequestCalls: newCounter("charm_id_api_link_request_total", "Total api link request calls"),
-		apiUnlinkCalls:      newCounter("charm_id_api_unlink_total", "Total api unlink calls"),
-		apiAuthCalls:        newCounter("charm_id_api_auth_total", "Total api auth calls"),
-		apiKeysCalls:        newCounter("charm_id_api_keys_total", "Total api keys calls"),
-		linkGenCalls:        newCounter("charm_id_link_gen_total", "Total link gen calls"),
-		linkRequestCalls:    newCounter("charm_id_link_request_total", "Total link request calls"),
-		keysCalls:           newCounter("charm_id_keys_total", "Total keys calls"),
-		idCalls:             newCounter("charm_id_id_total", "Total id calls"),
-		jwtCalls:            newCounter("charm_id_jwt_total", "Total jwt calls"),
-		getUserByIDCalls:    newCounter("charm_bio_get_user_by_id_total", "Total bio user by id calls"),
-		getUserCalls:        newCounter("charm_bio_get_user_total", "Total bio get user calls"),
-		setUserNameCalls:    newCounter("charm_bio_set_username_total", "Total total bio set username calls"),
-		getNews:             newCounter("charm_news_get_news_total", "Total get news calls"),
-		postNews:            newCounter("charm_news_post_news_total", "Total post news calls"),
-		getNewsList:         newCounter("charm_news_get_news_list_total", "Total get news list calls"),
-		fsBytesRead:         newCounterWithLabels("charm_fs_bytes_read_total", "Total bytes read", fsLabels),
-		fsBytesWritten:      newCounterWithLabels("charm_fs_bytes_written_total", "Total bytes written", fsLabels),
-		fsReads:             newCounterWithLabels("charm_fs_files_read_total", "Total files read", fsLabels),
-		fsWritten:           newCounterWithLabels("charm_fs_files_written_total", "Total files read", fsLabels),
-		users:               newGauge("charm_bio_users", "Total users"),
-		userNames:           newGauge("charm_bio_users_names", "Total usernames"),
-		db:                  db,
-		port:                port,
-		server:              s,
+		apiLinkGenCalls:       newCounter("charm_id_api_link_gen_total", "Total API link gen calls"),
+		apiLinkRequestCalls:   newCounter("charm_id_api_link_request_total", "Total api link request calls"),
+		apiUnlinkCalls:        newCounter("charm_id_api_unlink_total", "Total api unlink calls"),
+		apiDeleteAccountCalls: newCounter("charm_id_api_delete_account_total", "Total api delete account calls"),
+		apiInviteCalls:        newCounter("charm_id_api_invite_total", "Total api invite calls"),
+		apiAuthCalls:          newCounter("charm_id_api_auth_total", "Total api auth calls"),
+		apiKeysCalls:          newCounter("charm_id_api_keys_total", "Total api keys calls"),
+		linkGenCalls:          newCounter("charm_id_link_gen_total", "Total link gen calls"),
+		linkRequestCalls:      newCounter("charm_id_link_request_total", "Total link request calls"),
+		keysCalls:             newCounter("charm_id_keys_total", "Total keys calls"),
+		idCalls:               newCounter("charm_id_id_total", "Total id calls"),
+		jwtCalls:              newCounter("charm_id_jwt_total", "Total jwt calls"),
+		getUserByIDCalls:      newCounter("charm_bio_get_user_by_id_total", "Total bio user by id calls"),
+		getUserCalls:          newCounter("charm_bio_get_user_total", "Total bio get user calls"),
+		setUserNameCalls:      newCounter("charm_bio_set_username_total", "Total total bio set username calls"),
+		getNews:               newCounter("charm_news_get_news_total", "Total get news calls"),
+		postNews:              newCounter("charm_news_post_news_total", "Total post news calls"),
+		getNewsList:           newCounter("charm_news_get_news_list_total", "Total get news list calls"),
+		getActivity:           newCounter("charm_id_get_activity_total", "Total get activity calls"),
+		getUsage:              newCounter("charm_fs_get_usage_total", "Total get usage calls"),
+		fsBytesRead:           newCounterWithLabels("charm_fs_bytes_read_total", "Total bytes read", fsLabels),
+		fsBytesWritten:        newCounterWithLabels("charm_fs_bytes_written_total", "Total bytes written", fsLabels),
+		fsReads:               newCounterWithLabels("charm_fs_files_read_total", "Total files read", fsLabels),
+		fsWritten:             newCounterWithLabels("charm_fs_files_written_total", "Total files read", fsLabels),
+		users:                 newGauge("charm_bio_users", "Total users"),
+		userNames:             newGauge("charm_bio_users_names", "Total usernames"),
+		db:                    db,
+		port:                  port,
+		server:                s,
 	}
 }
 
@@ -135,6 +143,16 @@ func (ps *Stats) APIUnlink() {
 	ps.apiUnlinkCalls.Inc()
 }
 
+// APIDeleteAccount increments the number of api-delete-account calls.
+func (ps *Stats) APIDeleteAccount() {
+	ps.apiDeleteAccountCalls.Inc()
+}
+
+// APIInvite increments the number of api-invite calls.
+func (ps *Stats) APIInvite() {
+	ps.apiInviteCalls.Inc()
+}
+
 // APIAuth increments the number of api-auth calls.
 func (ps *Stats) APIAuth() {
 	ps.apiAuthCalls.Inc()
@@ -200,6 +218,16 @@ func (ps *Stats) GetNewsList() {
 	ps.getNewsList.Inc()
 }
 
+// GetActivity increments the number of get-activity calls.
+func (ps *Stats) GetActivity() {
+	ps.getActivity.Inc()
+}
+
+// GetUsage increments the number of get-usage calls.
+func (ps *Stats) GetUsage() {
+	ps.getUsage.Inc()
+}
+
 // FSFileRead reports metrics on a read file by a given charm_id.
 func (ps *Stats) FSFileRead(id string, size int64) {
 	ps.fsReads.WithLabelValues(id).Inc()
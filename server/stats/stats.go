@@ -10,6 +10,8 @@ type Stats interface {
 	APILinkGen()
 	APILinkRequest()
 	APIUnlink()
+	APIDeleteAccount()
+	APIInvite()
 	APIAuth()
 	APIKeys()
 	LinkGen()
@@ -23,6 +25,8 @@ type Stats interface {
 	GetNewsList()
 	GetNews()
 	PostNews()
+	GetActivity()
+	GetUsage()
 	FSFileRead(id string, size int64)
 	FSFileWritten(id string, size int64)
 	Close() error
@@ -8,6 +8,9 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	charmfs "github.com/charmbracelet/charm/fs"
 	charm "github.com/charmbracelet/charm/proto"
@@ -99,6 +102,9 @@ func (lfs *LocalFileStore) Get(charmID string, path string) (fs.File, error) {
 				ModTime: fi.ModTime(),
 				Mode:    fi.Mode(),
 			}
+			if !fi.IsDir() {
+				fin.ETag = storage.ETag(fi)
+			}
 			fis = append(fis, fin)
 		}
 		dir := charm.FileInfo{
@@ -125,14 +131,17 @@ func (lfs *LocalFileStore) Get(charmID string, path string) (fs.File, error) {
 
 // Put reads from the provided io.Reader and stores the data with the Charm ID
 // and path.
-func (lfs *LocalFileStore) Put(charmID string, path string, r io.Reader, mode fs.FileMode) error {
+func (lfs *LocalFileStore) Put(charmID string, path string, r io.Reader, mode fs.FileMode, mtime time.Time) error {
 	if cpath := filepath.Clean(path); cpath == string(os.PathSeparator) {
 		return fmt.Errorf("invalid path specified: %s", cpath)
 	}
 
 	fp := filepath.Join(lfs.Path, charmID, path)
 	if mode.IsDir() {
-		return storage.EnsureDir(fp, mode)
+		if err := storage.EnsureDir(fp, mode); err != nil {
+			return err
+		}
+		return chtimes(fp, mtime)
 	}
 	err := storage.EnsureDir(filepath.Dir(fp), mode)
 	if err != nil {
@@ -148,9 +157,22 @@ func (lfs *LocalFileStore) Put(charmID string, path string, r io.Reader, mode fs
 		return err
 	}
 	if mode != 0 {
-		return f.Chmod(mode)
+		if err := f.Chmod(mode); err != nil {
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
 	}
-	return nil
+	return chtimes(fp, mtime)
+}
+
+// chtimes sets the modification time of the file at fp, unless it's zero.
+func chtimes(fp string, mtime time.Time) error {
+	if mtime.IsZero() {
+		return nil
+	}
+	return os.Chtimes(fp, mtime, mtime)
 }
 
 // Delete deletes the file at the given path for the provided Charm ID.
@@ -158,3 +180,148 @@ func (lfs *LocalFileStore) Delete(charmID string, path string) error {
 	fp := filepath.Join(lfs.Path, charmID, path)
 	return os.RemoveAll(fp)
 }
+
+// Move moves the file or directory at the given path to a new path, which can
+// belong to another Charm ID. It returns fs.ErrNotExist if there's nothing to
+// move and fs.ErrExist if there's already something at the new path.
+func (lfs *LocalFileStore) Move(fromID string, from string, toID string, to string) error {
+	if cpath := filepath.Clean(to); cpath == string(os.PathSeparator) {
+		return fmt.Errorf("invalid path specified: %s", cpath)
+	}
+	fp := filepath.Join(lfs.Path, fromID, from)
+	tp := filepath.Join(lfs.Path, toID, to)
+	if _, err := os.Stat(fp); os.IsNotExist(err) {
+		return fs.ErrNotExist
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(tp); err == nil {
+		return fs.ErrExist
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := storage.EnsureDir(filepath.Dir(tp), 0o700); err != nil {
+		return err
+	}
+	return os.Rename(fp, tp)
+}
+
+// Mkdir creates the directory at the given path along with any missing
+// parents. It returns fs.ErrExist if there's a file at the path.
+func (lfs *LocalFileStore) Mkdir(charmID string, path string, mode fs.FileMode) error {
+	fp := filepath.Join(lfs.Path, charmID, path)
+	i, err := os.Stat(fp)
+	if err == nil {
+		if !i.IsDir() {
+			return fs.ErrExist
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return storage.EnsureDir(fp, mode.Perm())
+}
+
+// Chtimes sets the modification time of the file or directory at the given
+// path.
+func (lfs *LocalFileStore) Chtimes(charmID string, path string, mtime time.Time) error {
+	fp := filepath.Join(lfs.Path, charmID, path)
+	err := os.Chtimes(fp, mtime, mtime)
+	if os.IsNotExist(err) {
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+// List returns the files and directories below the directory at path,
+// recursively. See storage.FileStore for the ordering.
+func (lfs *LocalFileStore) List(charmID string, path string, after string, limit int) ([]charm.FileInfo, error) {
+	fp := filepath.Join(lfs.Path, charmID, path)
+	info, err := os.Stat(fp)
+	if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+		return nil, fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	fis := make([]charm.FileInfo, 0)
+	if _, err := listDir(fp, "", after, limit, &fis); err != nil {
+		return nil, err
+	}
+	return fis, nil
+}
+
+// listDir appends the files and directories below the directory rel in root
+// to fis, in storage.ListKey order, starting after the given key. Each
+// directory is read in order and right after its entry, which gives the order
+// of the whole tree, and the ones that come before after entirely are
+// skipped. It returns true once fis has limit entries, and stops there.
+func listDir(root string, rel string, after string, limit int, fis *[]charm.FileInfo) (bool, error) {
+	des, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return false, err
+	}
+	keys := make([]string, len(des))
+	for i, de := range des {
+		keys[i] = de.Name()
+		if rel != "" {
+			keys[i] = rel + "/" + keys[i]
+		}
+		if de.IsDir() {
+			keys[i] += "/"
+		}
+	}
+	sort.Sort(byKey{des, keys})
+	for i, de := range des {
+		if limit > 0 && len(*fis) >= limit {
+			return true, nil
+		}
+		key := keys[i]
+		if key > after {
+			fi, err := de.Info()
+			if err != nil {
+				return false, err
+			}
+			fin := charm.FileInfo{
+				Name:    strings.TrimSuffix(key, "/"),
+				IsDir:   fi.IsDir(),
+				Size:    fi.Size(),
+				ModTime: fi.ModTime(),
+				Mode:    fi.Mode(),
+			}
+			if fi.IsDir() {
+				fin.Size = 0
+			} else {
+				fin.ETag = storage.ETag(fi)
+			}
+			*fis = append(*fis, fin)
+		}
+		if de.IsDir() && (key > after || strings.HasPrefix(after, key)) {
+			full, err := listDir(root, strings.TrimSuffix(key, "/"), after, limit, fis)
+			if full || err != nil {
+				return full, err
+			}
+		}
+	}
+	return limit > 0 && len(*fis) >= limit, nil
+}
+
+// byKey sorts directory entries by their list keys.
+type byKey struct {
+	des  []fs.DirEntry
+	keys []string
+}
+
+func (bk byKey) Len() int {
+	return len(bk.des)
+}
+
+func (bk byKey) Less(i, j int) bool {
+	return bk.keys[i] < bk.keys[j]
+}
+
+func (bk byKey) Swap(i, j int) {
+	bk.des[i], bk.des[j] = bk.des[j], bk.des[i]
+	bk.keys[i], bk.keys[j] = bk.keys[j], bk.keys[i]
+}
@@ -6,8 +6,12 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
 	"github.com/google/uuid"
 )
 
@@ -22,7 +26,7 @@ func TestPut(t *testing.T) {
 
 	paths := []string{filepath.Join(string(os.PathSeparator), ""), filepath.Join(string(os.PathSeparator), "//")}
 	for _, path := range paths {
-		err = lfs.Put(charmID, path, buf, fs.FileMode(0o644))
+		err = lfs.Put(charmID, path, buf, fs.FileMode(0o644), time.Time{})
 		if err == nil {
 			t.Fatalf("expected error when file path is %s", path)
 		}
@@ -33,7 +37,7 @@ func TestPut(t *testing.T) {
 	path := filepath.Join(string(os.PathSeparator), "hello.txt")
 	t.Run(path, func(t *testing.T) {
 		buf = bytes.NewBufferString(content)
-		err = lfs.Put(charmID, path, buf, fs.FileMode(0o644))
+		err = lfs.Put(charmID, path, buf, fs.FileMode(0o644), time.Time{})
 		if err != nil {
 			t.Fatalf("expected no error when file path is %s, %v", path, err)
 		}
@@ -66,7 +70,7 @@ func TestPut(t *testing.T) {
 	path = filepath.Join(string(os.PathSeparator), "foo", "hello.txt")
 	t.Run(path, func(t *testing.T) {
 		buf = bytes.NewBufferString(content)
-		err = lfs.Put(charmID, path, buf, fs.FileMode(0o644))
+		err = lfs.Put(charmID, path, buf, fs.FileMode(0o644), time.Time{})
 		if err != nil {
 			t.Fatalf("expected no error when file path is %s, %v", path, err)
 		}
@@ -95,3 +99,155 @@ func TestPut(t *testing.T) {
 		}
 	})
 }
+
+func TestMove(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lfs.Put(charmID, "/foo/hello.txt", bytes.NewBufferString("hello"), 0o644, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lfs.Put(charmID, "/bar.txt", bytes.NewBufferString("bar"), 0o644, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lfs.Move(charmID, "/foo", charmID, "/baz/foo"); err != nil {
+		t.Fatalf("expected no error moving a directory, %v", err)
+	}
+	if _, err := lfs.Stat(charmID, "/foo"); err != fs.ErrNotExist {
+		t.Fatalf("expected the old path to be gone, got %v", err)
+	}
+	b, err := os.ReadFile(filepath.Join(tdir, charmID, "baz", "foo", "hello.txt"))
+	if err != nil {
+		t.Fatalf("expected no error reading the moved file, %v", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("expected content to be hello, got %s", string(b))
+	}
+
+	if err := lfs.Move(charmID, "/bar.txt", charmID, "/baz/foo/hello.txt"); err != fs.ErrExist {
+		t.Fatalf("expected fs.ErrExist moving onto an existing file, got %v", err)
+	}
+	if err := lfs.Move(charmID, "/missing.txt", charmID, "/other.txt"); err != fs.ErrNotExist {
+		t.Fatalf("expected fs.ErrNotExist moving a missing file, got %v", err)
+	}
+	if err := lfs.Move(charmID, "/bar.txt", ".other", "/"+charmID+"/bar.txt"); err != nil {
+		t.Fatalf("expected no error moving to another namespace, %v", err)
+	}
+	if _, err := lfs.Stat(".other", "/"+charmID+"/bar.txt"); err != nil {
+		t.Fatalf("expected the file in the other namespace, %v", err)
+	}
+}
+
+func TestMkdirChtimes(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lfs.Mkdir(charmID, "/foo/empty", 0o750); err != nil {
+		t.Fatalf("expected no error creating a directory, %v", err)
+	}
+	fi, err := lfs.Stat(charmID, "/foo/empty")
+	if err != nil {
+		t.Fatalf("expected the directory to exist, %v", err)
+	}
+	if !fi.IsDir() || fi.Mode().Perm() != 0o750 {
+		t.Fatalf("expected a directory with mode %s, got %s", fs.FileMode(0o750), fi.Mode())
+	}
+	if err := lfs.Mkdir(charmID, "/foo/empty", 0o750); err != nil {
+		t.Fatalf("expected no error creating an existing directory, %v", err)
+	}
+	if err := lfs.Put(charmID, "/file", bytes.NewBufferString("file"), 0o644, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lfs.Mkdir(charmID, "/file", 0o750); err != fs.ErrExist {
+		t.Fatalf("expected fs.ErrExist creating a directory over a file, got %v", err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := lfs.Chtimes(charmID, "/file", mtime); err != nil {
+		t.Fatalf("expected no error setting the time, %v", err)
+	}
+	fi, err = lfs.Stat(charmID, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Fatalf("expected mtime %s, got %s", mtime, fi.ModTime())
+	}
+	if err := lfs.Chtimes(charmID, "/missing", mtime); err != fs.ErrNotExist {
+		t.Fatalf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	tdir := t.TempDir()
+	charmID := uuid.New().String()
+	lfs, err := NewLocalFileStore(tdir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/top/a.txt", "/top/a/b/c", "/top/a/d", "/top/z", "/top/a-b", "/top/a.b/x"} {
+		if err := lfs.Put(charmID, p, bytes.NewBufferString(p), 0o640, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lfs.Mkdir(charmID, "/top/e", 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	// Directory names sort with a slash after them, so a/ comes after a.txt.
+	expected := "a-b a.b/ a.b/x a.txt a/ a/b/ a/b/c a/d e/ z"
+	fis, err := lfs.List(charmID, "/top", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := listKeys(fis); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if fis[6].Size != int64(len("/top/a/b/c")) || fis[6].Mode != 0o640 || fis[6].ETag == "" {
+		t.Fatalf("unexpected file info %+v", fis[6])
+	}
+
+	// Paging through a few at a time lists the same files.
+	for _, limit := range []int{1, 2, 3} {
+		var paged []charm.FileInfo
+		after := ""
+		for {
+			fis, err := lfs.List(charmID, "/top", after, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(fis) > limit {
+				t.Fatalf("expected at most %d files, got %d", limit, len(fis))
+			}
+			if len(fis) == 0 {
+				break
+			}
+			paged = append(paged, fis...)
+			after = storage.ListKey(fis[len(fis)-1])
+		}
+		if got := listKeys(paged); got != expected {
+			t.Fatalf("expected %q paging %d at a time, got %q", expected, limit, got)
+		}
+	}
+
+	for _, p := range []string{"/missing", "/top/z"} {
+		if _, err := lfs.List(charmID, p, "", 0); err != fs.ErrNotExist {
+			t.Fatalf("expected fs.ErrNotExist listing %s, got %v", p, err)
+		}
+	}
+}
+
+func listKeys(fis []charm.FileInfo) string {
+	ks := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		ks = append(ks, storage.ListKey(fi))
+	}
+	return strings.Join(ks, " ")
+}
@@ -0,0 +1,197 @@
+package s3storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// partSize is the size of the parts of multipart uploads. Data larger than a
+// part is uploaded in parts, one at a time, so it's never held in full and
+// isn't bound by the 5GB limit of a single request.
+var partSize int64 = 16 * 1024 * 1024 // 16MB
+
+// maxCopySize is the largest object copied with a single request, larger
+// objects are copied in parts of copyPartSize.
+var (
+	maxCopySize  int64 = 5 * 1024 * 1024 * 1024 // 5GB
+	copyPartSize int64 = 1024 * 1024 * 1024     // 1GB
+)
+
+// maxParts is the number of parts a multipart upload can have at most.
+const maxParts = 10000
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// upload stores the data read from r at key. Data that fits in a part is
+// stored with a single request, anything larger with a multipart upload.
+func (s *S3FileStore) upload(key string, r io.Reader, h http.Header) error {
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, partSize)
+	if err == io.EOF {
+		return s.put(key, &buf, n, h)
+	}
+	if err != nil {
+		return err
+	}
+	id, err := s.createMultipart(key, h)
+	if err != nil {
+		return err
+	}
+	var parts []completedPart
+	for num := 1; n > 0; num++ {
+		if num > maxParts {
+			s.abortMultipart(key, id)
+			return fmt.Errorf("s3 put %s: too many parts", key)
+		}
+		etag, err := s.uploadPart(key, id, num, &buf, n)
+		if err != nil {
+			s.abortMultipart(key, id)
+			return err
+		}
+		parts = append(parts, completedPart{PartNumber: num, ETag: etag})
+		buf.Reset()
+		n, err = io.CopyN(&buf, r, partSize)
+		if err != nil && err != io.EOF {
+			s.abortMultipart(key, id)
+			return err
+		}
+	}
+	return s.completeMultipart(key, id, parts)
+}
+
+// copyMultipart copies an object server-side in parts, which is needed for
+// objects larger than maxCopySize. The metadata isn't copied along with the
+// parts, it's the source's unless new metadata is provided.
+func (s *S3FileStore) copyMultipart(from string, to string, size int64, meta http.Header) error {
+	if meta == nil {
+		fi, err := s.head(from)
+		if err != nil {
+			return err
+		}
+		meta = metaHeader(fi.Mode(), fi.ModTime())
+	}
+	id, err := s.createMultipart(to, meta)
+	if err != nil {
+		return err
+	}
+	var parts []completedPart
+	for off, num := int64(0), 1; off < size; off, num = off+copyPartSize, num+1 {
+		last := off + copyPartSize - 1
+		if last >= size {
+			last = size - 1
+		}
+		etag, err := s.uploadPartCopy(to, id, num, from, off, last)
+		if err != nil {
+			s.abortMultipart(to, id)
+			return err
+		}
+		parts = append(parts, completedPart{PartNumber: num, ETag: etag})
+	}
+	return s.completeMultipart(to, id, parts)
+}
+
+func (s *S3FileStore) createMultipart(key string, h http.Header) (string, error) {
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, 0, h)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("s3 create multipart upload %s: %s", key, resp.Status)
+	}
+	var res struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+	return res.UploadID, nil
+}
+
+func (s *S3FileStore) uploadPart(key string, id string, num int, r io.Reader, size int64) (string, error) {
+	resp, err := s.do(http.MethodPut, key, partQuery(id, num), r, size)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("s3 upload part %d of %s: %s", num, key, resp.Status)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+func (s *S3FileStore) uploadPartCopy(key string, id string, num int, from string, first int64, last int64) (string, error) {
+	h := http.Header{
+		copySourceHeader: {escape("/"+s.cfg.Bucket+"/"+from, false)},
+		copyRangeHeader:  {fmt.Sprintf("bytes=%d-%d", first, last)},
+	}
+	resp, err := s.do(http.MethodPut, key, partQuery(id, num), nil, 0, h)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("s3 copy part %d of %s to %s: %s", num, from, key, resp.Status)
+	}
+	var res struct {
+		ETag string `xml:"ETag"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+	return res.ETag, nil
+}
+
+func (s *S3FileStore) completeMultipart(key string, id string, parts []completedPart) error {
+	b, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		s.abortMultipart(key, id)
+		return err
+	}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {id}}, bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		s.abortMultipart(key, id)
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	// Completing can fail after the response status was sent, the error is
+	// in the body then.
+	var res struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+	}
+	if resp.StatusCode == http.StatusOK {
+		if err := xml.NewDecoder(resp.Body).Decode(&res); err != nil {
+			return err
+		}
+	}
+	if resp.StatusCode != http.StatusOK || res.XMLName.Local == "Error" {
+		s.abortMultipart(key, id)
+		return fmt.Errorf("s3 complete multipart upload %s: %s %s", key, resp.Status, res.Code)
+	}
+	return nil
+}
+
+// abortMultipart aborts a multipart upload, deleting the parts uploaded so
+// far. It's best effort, a bucket lifecycle rule can clean up after it.
+func (s *S3FileStore) abortMultipart(key string, id string) {
+	resp, err := s.do(http.MethodDelete, key, url.Values{"uploadId": {id}}, nil, -1)
+	if err == nil {
+		resp.Body.Close() // nolint:errcheck
+	}
+}
+
+func partQuery(id string, num int) url.Values {
+	return url.Values{"partNumber": {strconv.Itoa(num)}, "uploadId": {id}}
+}
@@ -0,0 +1,670 @@
+// Package s3storage provides a FileStore implementation backed by any
+// S3-compatible object storage API.
+package s3storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	charmfs "github.com/charmbracelet/charm/fs"
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+const (
+	modeHeader       = "X-Amz-Meta-Mode"
+	mtimeHeader      = "X-Amz-Meta-Mtime"
+	copySourceHeader = "X-Amz-Copy-Source"
+	directiveHeader  = "X-Amz-Metadata-Directive"
+	copyRangeHeader  = "X-Amz-Copy-Source-Range"
+)
+
+// Config is the configuration for an S3FileStore.
+type Config struct {
+	// Endpoint is the base URL of the S3 API, e.g. https://s3.amazonaws.com
+	// or http://localhost:9000 for MinIO.
+	Endpoint        string
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket as part of the URL path instead of the
+	// host name. Most self-hosted S3-compatible servers require it.
+	PathStyle bool
+	// HTTPClient is used for requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// S3FileStore is a FileStore implementation that stores files as objects in
+// an S3 bucket. Objects are keyed by Charm ID and path.
+type S3FileStore struct {
+	cfg      Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3FileStore creates a FileStore for the configured bucket.
+func NewS3FileStore(cfg Config) (*S3FileStore, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("missing s3 bucket")
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3.amazonaws.com"
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	c := cfg.HTTPClient
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &S3FileStore{cfg: cfg, endpoint: u, client: c}, nil
+}
+
+// Stat returns the FileInfo for the given Charm ID and path. The size of a
+// directory is the total size of all of the files it contains.
+func (s *S3FileStore) Stat(charmID, name string) (fs.FileInfo, error) {
+	key := objectKey(charmID, name)
+	if !isRoot(name) {
+		fi, err := s.head(key)
+		if err == nil {
+			return fi, nil
+		}
+		if err != fs.ErrNotExist {
+			return nil, err
+		}
+	}
+	var size int64
+	var mt time.Time
+	found := false
+	err := s.list(key+"/", "", func(o object, _ string) {
+		found = true
+		size += o.Size
+		if o.LastModified.After(mt) {
+			mt = o.LastModified
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fs.ErrNotExist
+	}
+	return dirInfo(name, size, mt), nil
+}
+
+// Get returns an fs.File for the given Charm ID and path. Directories are
+// returned as a *charmfs.DirFile containing a JSON listing.
+func (s *S3FileStore) Get(charmID string, name string) (fs.File, error) {
+	key := objectKey(charmID, name)
+	if !isRoot(name) {
+		resp, err := s.do(http.MethodGet, key, nil, nil, -1)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return &file{store: s, key: key, body: resp.Body, info: infoFromHeader(key, resp.Header)}, nil
+		}
+		resp.Body.Close() // nolint:errcheck
+		if resp.StatusCode != http.StatusNotFound {
+			return nil, fmt.Errorf("s3 get %s: %s", key, resp.Status)
+		}
+	}
+
+	fis := make([]charm.FileInfo, 0)
+	var mt time.Time
+	found := false
+	prefix := key + "/"
+	err := s.list(prefix, "/", func(o object, cp string) {
+		found = true
+		if cp != "" {
+			fis = append(fis, charm.FileInfo{
+				Name:  path.Base(strings.TrimSuffix(cp, "/")),
+				IsDir: true,
+				Mode:  fs.ModeDir | 0o700,
+			})
+			return
+		}
+		// directory marker
+		if o.Key == prefix {
+			return
+		}
+		if o.LastModified.After(mt) {
+			mt = o.LastModified
+		}
+		fis = append(fis, charm.FileInfo{
+			Name:    path.Base(o.Key),
+			Size:    o.Size,
+			ModTime: o.LastModified,
+			Mode:    0o600,
+			ETag:    o.ETag,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fs.ErrNotExist
+	}
+	// ListObjects doesn't return the object metadata, fetch the modes and
+	// modification times.
+	for i, fi := range fis {
+		if fi.IsDir {
+			continue
+		}
+		hi, err := s.head(prefix + fi.Name)
+		if err == nil {
+			fis[i].Mode = hi.Mode()
+			fis[i].ModTime = hi.ModTime()
+		}
+	}
+	info := dirInfo(name, 0, mt)
+	dir := info.FileInfo
+	dir.Files = fis
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(dir); err != nil {
+		return nil, err
+	}
+	return &charmfs.DirFile{
+		Buffer:   buf,
+		FileInfo: info,
+	}, nil
+}
+
+// Put reads from the provided io.Reader and stores the data with the Charm ID
+// and path.
+func (s *S3FileStore) Put(charmID string, name string, r io.Reader, mode fs.FileMode, mtime time.Time) error {
+	if cpath := strings.Trim(name, "/"); cpath == "" || cpath == "." {
+		return fmt.Errorf("invalid path specified: %s", name)
+	}
+	key := objectKey(charmID, name)
+	h := http.Header{}
+	if !mtime.IsZero() {
+		h.Set(mtimeHeader, mtime.UTC().Format(time.RFC3339Nano))
+	}
+	if mode.IsDir() {
+		h.Set(modeHeader, strconv.FormatUint(uint64(mode), 10))
+		return s.put(key+"/", bytes.NewReader(nil), 0, h)
+	}
+	if mode != 0 {
+		h.Set(modeHeader, strconv.FormatUint(uint64(mode), 10))
+	}
+	return s.upload(key, r, h)
+}
+
+// Delete deletes the file at the given path for the provided Charm ID. If the
+// path is a directory everything in it is deleted.
+func (s *S3FileStore) Delete(charmID string, name string) error {
+	key := objectKey(charmID, name)
+	if !isRoot(name) {
+		if err := s.delete(key); err != nil {
+			return err
+		}
+	}
+	var keys []string
+	err := s.list(key+"/", "", func(o object, _ string) {
+		keys = append(keys, o.Key)
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move moves the file or directory at the given path to a new path, which can
+// belong to another Charm ID. S3 can't rename objects, so every object is
+// copied server-side and then deleted. It returns fs.ErrNotExist if there's
+// nothing to move and fs.ErrExist if there's already something at the new
+// path.
+func (s *S3FileStore) Move(fromID string, from string, toID string, to string) error {
+	if isRoot(to) {
+		return fmt.Errorf("invalid path specified: %s", to)
+	}
+	if _, err := s.Stat(toID, to); err == nil {
+		return fs.ErrExist
+	} else if err != fs.ErrNotExist {
+		return err
+	}
+	src := objectKey(fromID, from)
+	dst := objectKey(toID, to)
+	if !isRoot(from) {
+		fi, err := s.head(src)
+		if err == nil {
+			if err := s.copy(src, dst, fi.Size(), nil); err != nil {
+				return err
+			}
+			return s.delete(src)
+		}
+		if err != fs.ErrNotExist {
+			return err
+		}
+	}
+	var objs []object
+	err := s.list(src+"/", "", func(o object, _ string) {
+		objs = append(objs, o)
+	})
+	if err != nil {
+		return err
+	}
+	if len(objs) == 0 {
+		return fs.ErrNotExist
+	}
+	for _, o := range objs {
+		if err := s.copy(o.Key, dst+strings.TrimPrefix(o.Key, src), o.Size, nil); err != nil {
+			return err
+		}
+	}
+	for _, o := range objs {
+		if err := s.delete(o.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mkdir creates the directory at the given path by storing an empty directory
+// marker object. It returns fs.ErrExist if there's a file at the path.
+func (s *S3FileStore) Mkdir(charmID string, name string, mode fs.FileMode) error {
+	if isRoot(name) {
+		return nil
+	}
+	key := objectKey(charmID, name)
+	if _, err := s.head(key); err == nil {
+		return fs.ErrExist
+	} else if err != fs.ErrNotExist {
+		return err
+	}
+	if _, err := s.head(key + "/"); err == nil {
+		return nil
+	} else if err != fs.ErrNotExist {
+		return err
+	}
+	h := http.Header{}
+	h.Set(modeHeader, strconv.FormatUint(uint64(mode.Perm()|fs.ModeDir), 10))
+	return s.put(key+"/", bytes.NewReader(nil), 0, h)
+}
+
+// Chtimes sets the modification time of the file or directory at the given
+// path. Objects can't be modified, so the object is copied over itself with
+// the new time in its metadata. Directories without a marker object have no
+// time of their own and are left alone.
+func (s *S3FileStore) Chtimes(charmID string, name string, mtime time.Time) error {
+	key := objectKey(charmID, name)
+	fi, err := s.head(key)
+	if err == fs.ErrNotExist {
+		key += "/"
+		fi, err = s.head(key)
+	}
+	if err == fs.ErrNotExist {
+		_, err := s.Stat(charmID, name)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return s.copy(key, key, fi.Size(), metaHeader(fi.Mode(), mtime))
+}
+
+// List returns the files and directories below the directory at name,
+// recursively. S3 has no directories, they're the prefixes of the keys of the
+// files in them, or the keys ending with a slash that Mkdir leaves behind.
+// Recursive listings come from ListObjects alone, which doesn't return the
+// object metadata, so files have their upload time and a default mode,
+// unlike the listing of a single directory returned by Get.
+func (s *S3FileStore) List(charmID string, name string, after string, limit int) ([]charm.FileInfo, error) {
+	prefix := objectKey(charmID, name) + "/"
+	fis := make([]charm.FileInfo, 0)
+	last := after
+	found := false
+	add := func(fi charm.FileInfo) bool {
+		if limit > 0 && len(fis) >= limit {
+			return false
+		}
+		fis = append(fis, fi)
+		last = storage.ListKey(fi)
+		return true
+	}
+	err := s.listAfter(prefix, "", prefix+after, func(o object, _ string) bool {
+		found = true
+		rel := strings.TrimPrefix(o.Key, prefix)
+		parts := strings.Split(rel, "/")
+		for i := 1; i < len(parts); i++ {
+			dir := strings.Join(parts[:i], "/")
+			if dir+"/" <= last {
+				continue
+			}
+			if !add(charm.FileInfo{Name: dir, IsDir: true, Mode: fs.ModeDir | 0o700}) {
+				return false
+			}
+		}
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			return true
+		}
+		return add(charm.FileInfo{
+			Name:    rel,
+			Size:    o.Size,
+			ModTime: o.LastModified,
+			Mode:    0o600,
+			ETag:    o.ETag,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found && after == "" {
+		return nil, fs.ErrNotExist
+	}
+	return fis, nil
+}
+
+func (s *S3FileStore) head(key string) (*charmfs.FileInfo, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return infoFromHeader(key, resp.Header), nil
+	case http.StatusNotFound:
+		return nil, fs.ErrNotExist
+	default:
+		return nil, fmt.Errorf("s3 head %s: %s", key, resp.Status)
+	}
+}
+
+func (s *S3FileStore) put(key string, r io.Reader, size int64, h http.Header) error {
+	resp, err := s.do(http.MethodPut, key, nil, r, size, h)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 put %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+// copy copies an object of the given size server-side. The metadata is
+// copied along with it unless new metadata is provided.
+func (s *S3FileStore) copy(from string, to string, size int64, meta http.Header) error {
+	if size > maxCopySize {
+		return s.copyMultipart(from, to, size, meta)
+	}
+	h := http.Header{copySourceHeader: {escape("/"+s.cfg.Bucket+"/"+from, false)}}
+	if meta != nil {
+		h.Set(directiveHeader, "REPLACE")
+		for k, v := range meta {
+			h[k] = v
+		}
+	}
+	resp, err := s.do(http.MethodPut, to, nil, nil, 0, h)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 copy %s to %s: %s", from, to, resp.Status)
+	}
+	return nil
+}
+
+func (s *S3FileStore) delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, -1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("s3 delete %s: %s", key, resp.Status)
+	}
+}
+
+type object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+}
+
+type listResult struct {
+	Contents       []object `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list calls fn for every object with the given prefix. If a delimiter is
+// provided fn is also called for each common prefix with an empty object.
+func (s *S3FileStore) list(prefix string, delimiter string, fn func(o object, commonPrefix string)) error {
+	return s.listAfter(prefix, delimiter, "", func(o object, cp string) bool {
+		fn(o, cp)
+		return true
+	})
+}
+
+// listAfter is like list, but only lists the keys after startAfter and stops
+// as soon as fn returns false.
+func (s *S3FileStore) listAfter(prefix string, delimiter string, startAfter string, fn func(o object, commonPrefix string) bool) error {
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", prefix)
+		if delimiter != "" {
+			q.Set("delimiter", delimiter)
+		}
+		if startAfter != "" {
+			q.Set("start-after", startAfter)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", q, nil, -1)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close() // nolint:errcheck
+			return fmt.Errorf("s3 list %s: %s", prefix, resp.Status)
+		}
+		var lr listResult
+		err = xml.NewDecoder(resp.Body).Decode(&lr)
+		resp.Body.Close() // nolint:errcheck
+		if err != nil {
+			return err
+		}
+		for _, o := range lr.Contents {
+			if !fn(o, "") {
+				return nil
+			}
+		}
+		for _, cp := range lr.CommonPrefixes {
+			if !fn(object{}, cp.Prefix) {
+				return nil
+			}
+		}
+		if !lr.IsTruncated || lr.NextContinuationToken == "" {
+			return nil
+		}
+		token = lr.NextContinuationToken
+	}
+}
+
+func (s *S3FileStore) do(method string, key string, q url.Values, body io.Reader, size int64, hs ...http.Header) (*http.Response, error) {
+	u := *s.endpoint
+	p := strings.TrimSuffix(u.Path, "/")
+	if s.cfg.PathStyle {
+		p += "/" + s.cfg.Bucket
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	p += "/" + key
+	u.Path = p
+	u.RawPath = escape(p, false)
+	if q != nil {
+		u.RawQuery = canonicalQuery(q)
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hs {
+		for k, v := range h {
+			req.Header[k] = v
+		}
+	}
+	if size >= 0 {
+		req.ContentLength = size
+		req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	}
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	signV4(req, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.Region, time.Now())
+	return s.client.Do(req)
+}
+
+// file is an object being read from S3. It implements io.Seeker, seeking
+// closes the current response and the next read fetches the rest of the
+// object from the new offset with a range request.
+type file struct {
+	store *S3FileStore
+	key   string
+	body  io.ReadCloser
+	info  fs.FileInfo
+	off   int64
+}
+
+// Stat returns the object's FileInfo.
+func (f *file) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Read reads from the object.
+func (f *file) Read(p []byte) (int, error) {
+	if f.body == nil {
+		if f.off >= f.info.Size() {
+			return 0, io.EOF
+		}
+		h := http.Header{"Range": {fmt.Sprintf("bytes=%d-", f.off)}}
+		resp, err := f.store.do(http.MethodGet, f.key, nil, nil, -1, h)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close() // nolint:errcheck
+			return 0, fmt.Errorf("s3 get %s: %s", f.key, resp.Status)
+		}
+		f.body = resp.Body
+	}
+	n, err := f.body.Read(p)
+	f.off += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("s3 seek %s: negative position", f.key)
+	}
+	if offset != f.off && f.body != nil {
+		f.body.Close() // nolint:errcheck
+		f.body = nil
+	}
+	f.off = offset
+	return offset, nil
+}
+
+// Close closes the object.
+func (f *file) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	return f.body.Close()
+}
+
+func objectKey(charmID string, p string) string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return charmID
+	}
+	return charmID + "/" + p
+}
+
+func isRoot(p string) bool {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	return p == ""
+}
+
+func dirInfo(p string, size int64, mt time.Time) *charmfs.FileInfo {
+	return &charmfs.FileInfo{
+		FileInfo: charm.FileInfo{
+			Name:    path.Base(path.Clean("/" + p)),
+			IsDir:   true,
+			Size:    size,
+			ModTime: mt,
+			Mode:    fs.ModeDir | 0o700,
+		},
+	}
+}
+
+func infoFromHeader(key string, h http.Header) *charmfs.FileInfo {
+	size, _ := strconv.ParseInt(h.Get("Content-Length"), 10, 64)
+	mt, _ := http.ParseTime(h.Get("Last-Modified"))
+	if t, err := time.Parse(time.RFC3339Nano, h.Get(mtimeHeader)); err == nil {
+		mt = t
+	}
+	mode := fs.FileMode(0o600)
+	if m, err := strconv.ParseUint(h.Get(modeHeader), 10, 32); err == nil && m != 0 {
+		mode = fs.FileMode(m)
+	}
+	return &charmfs.FileInfo{
+		FileInfo: charm.FileInfo{
+			Name:    path.Base(key),
+			Size:    size,
+			ModTime: mt,
+			Mode:    mode,
+			ETag:    h.Get("ETag"),
+		},
+	}
+}
+
+// metaHeader returns the metadata of an object with the given mode and
+// modification time.
+func metaHeader(mode fs.FileMode, mtime time.Time) http.Header {
+	h := http.Header{}
+	h.Set(modeHeader, strconv.FormatUint(uint64(mode), 10))
+	h.Set(mtimeHeader, mtime.UTC().Format(time.RFC3339Nano))
+	return h
+}
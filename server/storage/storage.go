@@ -1,9 +1,14 @@
 package storage
 
 import (
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"time"
+
+	charmfs "github.com/charmbracelet/charm/fs"
+	charm "github.com/charmbracelet/charm/proto"
 )
 
 // FileStore is the interface storage backends need to implement to act as a
@@ -11,8 +16,38 @@ import (
 type FileStore interface {
 	Stat(charmID string, path string) (fs.FileInfo, error)
 	Get(charmID string, path string) (fs.File, error)
-	Put(charmID string, path string, r io.Reader, mode fs.FileMode) error
+	// Put stores the file or directory at path. A zero mtime means the time
+	// of the write.
+	Put(charmID string, path string, r io.Reader, mode fs.FileMode, mtime time.Time) error
 	Delete(charmID string, path string) error
+	Move(fromID string, from string, toID string, to string) error
+	Mkdir(charmID string, path string, mode fs.FileMode) error
+	Chtimes(charmID string, path string, mtime time.Time) error
+	// List returns up to limit of the files and directories below the
+	// directory at path, at any depth, named by their path relative to it.
+	// They're in ListKey order, starting after the given key. It returns
+	// fs.ErrNotExist if there's no directory at path.
+	List(charmID string, path string, after string, limit int) ([]charm.FileInfo, error)
+}
+
+// ListKey returns the key a listed file is ordered by, which is its path with
+// a trailing slash for directories. That puts a directory right before the
+// files in it, the order object stores list keys in.
+func ListKey(fi charm.FileInfo) string {
+	if fi.IsDir {
+		return fi.Name + "/"
+	}
+	return fi.Name
+}
+
+// ETag returns a strong entity tag for a stored file. It's the ETag reported
+// by the FileStore if there is one, otherwise it's derived from the file's
+// modification time and size.
+func ETag(fi fs.FileInfo) string {
+	if cfi, ok := fi.(*charmfs.FileInfo); ok && cfi.FileInfo.ETag != "" {
+		return cfi.FileInfo.ETag
+	}
+	return fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size())
 }
 
 // EnsureDir will create the directory for the provided path on the server
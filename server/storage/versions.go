@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// VersionsID is the FileStore namespace previous versions of files are kept
+// in. The versions of a user's file are stored in a directory named after the
+// user's Charm ID and the path of the file.
+const VersionsID = ".versions"
+
+// VersionedFileStore is a FileStore that keeps previous versions of a file
+// when it's overwritten. At most Keep versions are kept per file and, when
+// MaxAge is set, versions replaced longer ago than that are deleted. Deleting
+// a file deletes its versions as well. Namespaces starting with a dot, like
+// VersionsID itself, aren't versioned.
+type VersionedFileStore struct {
+	FileStore
+	Keep   int
+	MaxAge time.Duration
+}
+
+// NewVersionedFileStore returns a VersionedFileStore keeping previous versions
+// of the files stored in fstore.
+func NewVersionedFileStore(fstore FileStore, keep int, maxAge time.Duration) *VersionedFileStore {
+	return &VersionedFileStore{FileStore: fstore, Keep: keep, MaxAge: maxAge}
+}
+
+// Put stores the current version of the file, if there is one, before
+// writing the new one and pruning the versions that are no longer kept. The
+// current version is put back if the new one can't be written.
+func (vfs *VersionedFileStore) Put(charmID string, name string, r io.Reader, mode fs.FileMode, mtime time.Time) error {
+	if mode.IsDir() || strings.HasPrefix(charmID, ".") {
+		return vfs.FileStore.Put(charmID, name, r, mode, mtime)
+	}
+	v, err := vfs.saveVersion(charmID, name)
+	if err != nil {
+		return err
+	}
+	if err := vfs.FileStore.Put(charmID, name, r, mode, mtime); err != nil {
+		if v != "" {
+			_ = vfs.FileStore.Delete(charmID, name)
+			_ = vfs.FileStore.Move(VersionsID, v, charmID, name)
+		}
+		return err
+	}
+	return vfs.Prune(charmID, name)
+}
+
+// Delete deletes the file or directory along with the versions of the files
+// in it.
+func (vfs *VersionedFileStore) Delete(charmID string, name string) error {
+	if err := vfs.FileStore.Delete(charmID, name); err != nil {
+		return err
+	}
+	if strings.HasPrefix(charmID, ".") {
+		return nil
+	}
+	err := vfs.FileStore.Delete(VersionsID, VersionsDir(charmID, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Move moves the file or directory and, between users' namespaces, the
+// versions of the files in it.
+func (vfs *VersionedFileStore) Move(fromID string, from string, toID string, to string) error {
+	if err := vfs.FileStore.Move(fromID, from, toID, to); err != nil {
+		return err
+	}
+	if strings.HasPrefix(fromID, ".") || strings.HasPrefix(toID, ".") {
+		return nil
+	}
+	// Nothing was at the new path, so any versions there are stale.
+	err := vfs.FileStore.Delete(VersionsID, VersionsDir(toID, to))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	err = vfs.FileStore.Move(VersionsID, VersionsDir(fromID, from), VersionsID, VersionsDir(toID, to))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Prune deletes the versions of the file that are beyond Keep or older than
+// MaxAge.
+func (vfs *VersionedFileStore) Prune(charmID string, name string) error {
+	vs, err := Versions(vfs.FileStore, charmID, name)
+	if err != nil {
+		return err
+	}
+	for i, v := range vs {
+		if i < vfs.Keep && (vfs.MaxAge <= 0 || time.Since(v.ModTime) <= vfs.MaxAge) {
+			continue
+		}
+		err := vfs.FileStore.Delete(VersionsID, path.Join(VersionsDir(charmID, name), v.ID))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneExpired deletes all of the user's versions that are older than
+// MaxAge. It's a no-op when MaxAge isn't set.
+func (vfs *VersionedFileStore) PruneExpired(charmID string) error {
+	if vfs.MaxAge <= 0 {
+		return nil
+	}
+	var expired []string
+	err := Walk(vfs.FileStore, VersionsID, charmID, func(p string, _ fs.FileInfo) error {
+		ns, err := strconv.ParseInt(path.Base(p), 10, 64)
+		if err == nil && time.Since(time.Unix(0, ns)) > vfs.MaxAge {
+			expired = append(expired, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range expired {
+		if err := vfs.FileStore.Delete(VersionsID, p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveVersion moves the current file at name, if it exists, to its versions
+// and returns the path of the version.
+func (vfs *VersionedFileStore) saveVersion(charmID string, name string) (string, error) {
+	fi, err := vfs.FileStore.Stat(charmID, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if fi.IsDir() {
+		return "", nil
+	}
+	v := path.Join(VersionsDir(charmID, name), strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := vfs.FileStore.Move(charmID, name, VersionsID, v); err != nil {
+		return "", err
+	}
+	return v, nil
+}
+
+// Versions returns the previous versions of a file, newest first.
+func Versions(fstore FileStore, charmID string, name string) ([]charm.FileVersion, error) {
+	vs := make([]charm.FileVersion, 0)
+	f, err := fstore.Get(VersionsID, VersionsDir(charmID, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return vs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return vs, nil
+	}
+	var dir charm.FileInfo
+	if err := json.NewDecoder(f).Decode(&dir); err != nil {
+		return nil, err
+	}
+	for _, fi := range dir.Files {
+		// Directories hold the versions of files in a directory of the
+		// same name.
+		if fi.IsDir {
+			continue
+		}
+		ns, err := strconv.ParseInt(fi.Name, 10, 64)
+		if err != nil {
+			continue
+		}
+		vs = append(vs, charm.FileVersion{
+			ID:      fi.Name,
+			Size:    fi.Size,
+			ModTime: time.Unix(0, ns),
+		})
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		return vs[i].ModTime.After(vs[j].ModTime)
+	})
+	return vs, nil
+}
+
+// GetVersion returns a previous version of a file.
+func GetVersion(fstore FileStore, charmID string, name string, id string) (fs.File, error) {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, fs.ErrNotExist
+	}
+	return fstore.Get(VersionsID, path.Join(VersionsDir(charmID, name), id))
+}
+
+// VersionsSize returns the total size of the versions of the files at name,
+// which can be a file or a directory.
+func VersionsSize(fstore FileStore, charmID string, name string) (int64, error) {
+	fi, err := fstore.Stat(VersionsID, VersionsDir(charmID, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// VersionsDir returns the directory in the VersionsID namespace the versions
+// of a file are kept in.
+func VersionsDir(charmID string, name string) string {
+	return path.Join(charmID, name)
+}
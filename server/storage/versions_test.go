@@ -0,0 +1,116 @@
+package storage_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/charmbracelet/charm/server/storage"
+	localstorage "github.com/charmbracelet/charm/server/storage/local"
+	"github.com/google/uuid"
+)
+
+func TestVersionedFileStore(t *testing.T) {
+	lfs, err := localstorage.NewLocalFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vfs := storage.NewVersionedFileStore(lfs, 2, 0)
+	charmID := uuid.New().String()
+	for _, content := range []string{"one", "two", "three", "four"} {
+		if err := vfs.Put(charmID, "/file", bytes.NewBufferString(content), 0o644, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vs, err := storage.Versions(vfs, charmID, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(vs))
+	}
+	for i, want := range []string{"three", "two"} {
+		f, err := storage.GetVersion(vfs, charmID, "/file", vs[i].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(f)
+		_ = f.Close()
+		if string(b) != want {
+			t.Fatalf("expected version %d to be %q, got %q", i, want, b)
+		}
+	}
+	n, err := storage.VersionsSize(vfs, charmID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len("three")+len("two")) {
+		t.Fatalf("expected versions to take %d bytes, got %d", len("three")+len("two"), n)
+	}
+
+	if err := vfs.Move(charmID, "/file", charmID, "/moved"); err != nil {
+		t.Fatal(err)
+	}
+	if vs, _ := storage.Versions(vfs, charmID, "/moved"); len(vs) != 2 {
+		t.Fatalf("expected versions to move with the file, got %d", len(vs))
+	}
+	if err := vfs.Move(charmID, "/moved", charmID, "/file"); err != nil {
+		t.Fatal(err)
+	}
+
+	vfs.MaxAge = time.Nanosecond
+	if err := vfs.PruneExpired(charmID); err != nil {
+		t.Fatal(err)
+	}
+	if vs, _ := storage.Versions(vfs, charmID, "/file"); len(vs) != 0 {
+		t.Fatalf("expected expired versions to be deleted, got %d", len(vs))
+	}
+
+	vfs.MaxAge = 0
+	if err := vfs.Put(charmID, "/file", bytes.NewBufferString("five"), 0o644, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := vfs.Delete(charmID, "/file"); err != nil {
+		t.Fatal(err)
+	}
+	if vs, _ := storage.Versions(vfs, charmID, "/file"); len(vs) != 0 {
+		t.Fatalf("expected versions to be deleted with the file, got %d", len(vs))
+	}
+}
+
+func TestVersionedFileStoreFailedPut(t *testing.T) {
+	lfs, err := localstorage.NewLocalFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vfs := storage.NewVersionedFileStore(lfs, 2, 0)
+	charmID := uuid.New().String()
+	if err := vfs.Put(charmID, "/file", bytes.NewBufferString("one"), 0o644, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	r := io.MultiReader(bytes.NewBufferString("tw"), iotest.ErrReader(errors.New("broken")))
+	if err := vfs.Put(charmID, "/file", r, 0o644, time.Time{}); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+
+	f, err := vfs.Get(charmID, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(f)
+	_ = f.Close()
+	if string(b) != "one" {
+		t.Fatalf("expected the current version to be kept, got %q", b)
+	}
+	vs, err := storage.Versions(vfs, charmID, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 0 {
+		t.Fatalf("expected no versions, got %+v", vs)
+	}
+}
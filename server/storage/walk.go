@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+
+	charm "github.com/charmbracelet/charm/proto"
+)
+
+// WalkFunc is called by Walk for every file in a user's storage. The path is
+// relative to the user's storage root.
+type WalkFunc func(path string, info fs.FileInfo) error
+
+// Walk calls fn for every regular file stored for charmID under root, using
+// the directory listings returned by FileStore.Get. A missing root is not an
+// error.
+func Walk(fstore FileStore, charmID string, root string, fn WalkFunc) error {
+	f, err := fstore.Get(charmID, root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	if !info.IsDir() {
+		f.Close() // nolint:errcheck
+		return fn(root, info)
+	}
+	var dir charm.FileInfo
+	err = json.NewDecoder(f).Decode(&dir)
+	f.Close() // nolint:errcheck
+	if err != nil {
+		return err
+	}
+	for _, fi := range dir.Files {
+		if err := Walk(fstore, charmID, path.Join(root, fi.Name), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
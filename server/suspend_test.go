@@ -0,0 +1,49 @@
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestSuspendedAccount(t *testing.T) {
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+	u, err := cfg.DB.GetUserWithID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.Auth(); err != nil {
+		t.Fatalf("auth error: %s", err)
+	}
+	if err := cfg.DB.SetUserSuspended(u, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens issued before the suspension stop working.
+	resp, err := cl.AuthedRawRequest("GET", "/v1/activity")
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected suspended account to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v", err)
+	}
+	cl.InvalidateAuth()
+	if _, err := cl.Auth(); !errors.Is(err, charm.ErrAccountSuspended) {
+		t.Fatalf("expected ErrAccountSuspended, got %v", err)
+	}
+
+	if err := cfg.DB.SetUserSuspended(u, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.Auth(); err != nil {
+		t.Fatalf("auth error after reinstating: %s", err)
+	}
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"goji.io/pat"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+// trashID is the FileStore namespace deleted files are kept in until they're
+// purged. It can't clash with a Charm ID.
+const trashID = ".trash"
+
+// trashDir returns the path a trash item's files are kept at.
+func trashDir(item *charm.TrashItem) string {
+	return path.Join(item.CharmID, strconv.Itoa(item.ID))
+}
+
+// trashVersionsDir returns the path the previous versions of a trash item's
+// files are kept at. It's next to the files, so the trash directory of a user
+// holds everything that counts toward their quota.
+func trashVersionsDir(item *charm.TrashItem) string {
+	return trashDir(item) + storage.VersionsID
+}
+
+// trashLockKey returns the path lock key of a trash item.
+func trashLockKey(item *charm.TrashItem) string {
+	return trashID + "/" + trashDir(item)
+}
+
+// trashFile moves the file or directory at path, along with the previous
+// versions of its files, to the user's trash. It returns the new trash item,
+// or nil when there's nothing at path.
+func (cfg *Config) trashFile(u *charm.User, name string) (*charm.TrashItem, error) {
+	fi, err := cfg.FileStore.Stat(u.CharmID, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	vs, err := storage.VersionsSize(cfg.FileStore, u.CharmID, name)
+	if err != nil {
+		return nil, err
+	}
+	item := &charm.TrashItem{Path: name, Size: fi.Size() + vs, IsDir: fi.IsDir(), CharmID: u.CharmID}
+	if err := cfg.DB.AddTrashItem(u, item); err != nil {
+		return nil, err
+	}
+	if err := cfg.FileStore.Move(u.CharmID, name, trashID, trashDir(item)); err != nil {
+		if perr := deleteTrashItem(cfg, item); perr != nil {
+			log.Error("cannot delete partial trash item", "id", item.ID, "err", perr)
+		}
+		return nil, err
+	}
+	err = cfg.FileStore.Move(storage.VersionsID, storage.VersionsDir(u.CharmID, name), trashID, trashVersionsDir(item))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		if rerr := cfg.FileStore.Move(trashID, trashDir(item), u.CharmID, name); rerr != nil {
+			log.Error("cannot move trashed files back", "id", item.ID, "err", rerr)
+			return nil, err
+		}
+		if perr := deleteTrashItem(cfg, item); perr != nil {
+			log.Error("cannot delete partial trash item", "id", item.ID, "err", perr)
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+// restoreTrashItem moves a trash item and its versions back to where it was
+// deleted from. It returns fs.ErrExist if something's there now.
+func restoreTrashItem(cfg *Config, item *charm.TrashItem) error {
+	unlock := cfg.locks.lock(item.CharmID+item.Path, trashLockKey(item))
+	defer unlock()
+	return untrashFile(cfg, item)
+}
+
+// untrashFile restores a trash item like restoreTrashItem. The caller holds
+// the lock on the item's path.
+func untrashFile(cfg *Config, item *charm.TrashItem) error {
+	if err := cfg.FileStore.Move(trashID, trashDir(item), item.CharmID, item.Path); err != nil {
+		return err
+	}
+	// Nothing was at the path, so any versions there are stale.
+	vd := storage.VersionsDir(item.CharmID, item.Path)
+	if err := cfg.FileStore.Delete(storage.VersionsID, vd); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	err := cfg.FileStore.Move(trashID, trashVersionsDir(item), storage.VersionsID, vd)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return deleteTrashItem(cfg, item)
+}
+
+// purgeTrashItem deletes a trash item and its versions for good.
+func purgeTrashItem(cfg *Config, item *charm.TrashItem) error {
+	unlock := cfg.locks.lock(trashLockKey(item))
+	defer unlock()
+	return deleteTrashItem(cfg, item)
+}
+
+// deleteTrashItem deletes a trash item and its versions. The caller holds
+// the item's lock or is still creating it.
+func deleteTrashItem(cfg *Config, item *charm.TrashItem) error {
+	for _, dir := range []string{trashDir(item), trashVersionsDir(item)} {
+		err := cfg.FileStore.Delete(trashID, dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return cfg.DB.DeleteTrashItem(item.ID)
+}
+
+// PurgeTrash deletes the trash items that have been kept longer than
+// TrashRetention. Items that can't be purged are logged and left for the
+// next run.
+func PurgeTrash(cfg *Config) error {
+	items, err := cfg.DB.GetTrashItemsBefore(time.Now().Add(-cfg.TrashRetention))
+	if err != nil {
+		return err
+	}
+	n := 0
+	for _, item := range items {
+		u, err := cfg.DB.GetUserWithID(item.CharmID)
+		if err != nil {
+			log.Error("cannot get trash item user", "id", item.ID, "err", err)
+			continue
+		}
+		if err := purgeTrashItem(cfg, item); err != nil {
+			log.Error("cannot purge trash item", "id", item.ID, "err", err)
+			cfg.resetStorageUsed(u)
+			continue
+		}
+		cfg.addStorageUsed(u, -item.Size)
+		n++
+	}
+	if n > 0 {
+		log.Debug("Purged trash", "count", n)
+	}
+	return nil
+}
+
+// purgeTrash runs PurgeTrash, logging any error. It does nothing when the
+// trash is disabled.
+func (srv *Server) purgeTrash() {
+	if srv.Config.TrashRetention <= 0 {
+		return
+	}
+	if err := PurgeTrash(srv.Config); err != nil {
+		log.Error("cannot purge trash", "err", err)
+	}
+}
+
+func (s *HTTPServer) handleGetTrash(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	items, err := s.db.GetTrashItems(u)
+	if err != nil {
+		log.Error("cannot get trash", "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+func (s *HTTPServer) handleRestoreTrashItem(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	item := s.trashItem(w, r, u)
+	if item == nil {
+		return
+	}
+	err := restoreTrashItem(s.cfg, item)
+	if errors.Is(err, fs.ErrExist) {
+		s.renderCustomError(w, "a file already exists at the restore path", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		// It was purged in the meantime.
+		s.renderCustomError(w, charm.ErrMissingTrashItem.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error("cannot restore trash item", "err", err)
+		s.cfg.resetStorageUsed(u)
+		s.renderError(w)
+		return
+	}
+}
+
+func (s *HTTPServer) handleDeleteTrashItem(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	item := s.trashItem(w, r, u)
+	if item == nil {
+		return
+	}
+	if err := purgeTrashItem(s.cfg, item); err != nil {
+		log.Error("cannot delete trash item", "err", err)
+		s.cfg.resetStorageUsed(u)
+		s.renderError(w)
+		return
+	}
+	s.cfg.addStorageUsed(u, -item.Size)
+}
+
+func (s *HTTPServer) handleEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	items, err := s.db.GetTrashItems(u)
+	if err != nil {
+		log.Error("cannot get trash", "err", err)
+		s.renderError(w)
+		return
+	}
+	for _, item := range items {
+		if err := purgeTrashItem(s.cfg, item); err != nil {
+			log.Error("cannot delete trash item", "err", err)
+			s.cfg.resetStorageUsed(u)
+			s.renderError(w)
+			return
+		}
+		s.cfg.addStorageUsed(u, -item.Size)
+	}
+}
+
+// trashItem returns the user's trash item from the request, otherwise it
+// renders an error and returns nil.
+func (s *HTTPServer) trashItem(w http.ResponseWriter, r *http.Request, u *charm.User) *charm.TrashItem {
+	id, err := strconv.Atoi(pat.Param(r, "id"))
+	if err != nil {
+		s.renderCustomError(w, "invalid trash item id", http.StatusBadRequest)
+		return nil
+	}
+	item, err := s.db.GetTrashItem(u, id)
+	if errors.Is(err, charm.ErrMissingTrashItem) {
+		s.renderCustomError(w, err.Error(), http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		log.Error("cannot get trash item", "err", err)
+		s.renderError(w)
+		return nil
+	}
+	return item
+}
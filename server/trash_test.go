@@ -0,0 +1,180 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestTrash(t *testing.T) {
+	t.Setenv("CHARM_SERVER_TRASH_RETENTION", "720h")
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+	for _, name := range []string{"file", "dir/a", "dir/b"} {
+		resp, err := postFile(t, cl, name, []byte("hello"))
+		if err != nil {
+			t.Fatalf("post file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+	for _, name := range []string{"file", "dir"} {
+		resp, err := cl.AuthedRequest("DELETE", "/v1/fs/"+name, nil, nil)
+		if err != nil {
+			t.Fatalf("delete file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+	if resp, err := cl.AuthedRawRequest("GET", "/v1/fs/dir"); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the deleted directory to be gone, got %v", err)
+	}
+
+	var items []charm.TrashItem
+	if err := cl.AuthedJSONRequest("GET", "/v1/trash", nil, &items); err != nil {
+		t.Fatalf("get trash error: %s", err)
+	}
+	if len(items) != 2 || items[0].Path != "/dir" || !items[0].IsDir || items[0].Size != 10 || items[1].Path != "/file" {
+		t.Fatalf("unexpected trash: %+v", items)
+	}
+	u, err := cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != 15 {
+		t.Fatalf("expected the trash to count toward usage, got %d bytes used", u.Used)
+	}
+
+	resp, err := cl.AuthedRequest("POST", "/v1/trash/"+strconv.Itoa(items[0].ID)+"/restore", nil, nil)
+	if err != nil {
+		t.Fatalf("restore error: %s", err)
+	}
+	_ = resp.Body.Close()
+	resp, err = cl.AuthedRawRequest("GET", "/v1/fs/dir/b")
+	if err != nil {
+		t.Fatalf("get restored file error: %s", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "hello" {
+		t.Fatalf("expected the restored file to be %q, got %q", "hello", b)
+	}
+
+	// Restoring over an existing file is refused.
+	resp, err = postFile(t, cl, "file", []byte("new"))
+	if err != nil {
+		t.Fatalf("post file error: %s", err)
+	}
+	_ = resp.Body.Close()
+	resp, err = cl.AuthedRequest("POST", "/v1/trash/"+strconv.Itoa(items[1].ID)+"/restore", nil, nil)
+	if err == nil || resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected restoring over a file to conflict, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// Purging drops items older than the retention.
+	pcfg := *cfg
+	pcfg.TrashRetention = -time.Minute
+	if err := server.PurgeTrash(&pcfg); err != nil {
+		t.Fatal(err)
+	}
+	items = nil
+	if err := cl.AuthedJSONRequest("GET", "/v1/trash", nil, &items); err != nil {
+		t.Fatalf("get trash error: %s", err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("expected the trash to be purged, got %+v", items)
+	}
+	u, err = cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != 13 {
+		t.Fatalf("expected 13 bytes used after purging, got %d", u.Used)
+	}
+}
+
+func TestTrashVersions(t *testing.T) {
+	t.Setenv("CHARM_SERVER_TRASH_RETENTION", "720h")
+	t.Setenv("CHARM_SERVER_FILE_VERSIONS", "2")
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+	for _, content := range []string{"one", "two"} {
+		resp, err := postFile(t, cl, "file", []byte(content))
+		if err != nil {
+			t.Fatalf("post file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+	versions := func() []charm.FileVersion {
+		var vs []charm.FileVersion
+		if err := cl.AuthedJSONRequest("GET", "/v1/versions/file", nil, &vs); err != nil {
+			t.Fatalf("list versions error: %s", err)
+		}
+		return vs
+	}
+	used := func() int64 {
+		u, err := cl.Usage()
+		if err != nil {
+			t.Fatalf("usage error: %s", err)
+		}
+		return u.Used
+	}
+	trash := func() []charm.TrashItem {
+		var items []charm.TrashItem
+		if err := cl.AuthedJSONRequest("GET", "/v1/trash", nil, &items); err != nil {
+			t.Fatalf("get trash error: %s", err)
+		}
+		return items
+	}
+	del := func() {
+		resp, err := cl.AuthedRequest("DELETE", "/v1/fs/file", nil, nil)
+		if err != nil {
+			t.Fatalf("delete file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	del()
+	if vs := versions(); len(vs) != 0 {
+		t.Fatalf("expected the versions to go to the trash, got %+v", vs)
+	}
+	items := trash()
+	if len(items) != 1 || items[0].Size != 6 {
+		t.Fatalf("expected the trash item to count its versions, got %+v", items)
+	}
+	if n := used(); n != 6 {
+		t.Fatalf("expected 6 bytes used, got %d", n)
+	}
+
+	resp, err := cl.AuthedRequest("POST", "/v1/trash/"+strconv.Itoa(items[0].ID)+"/restore", nil, nil)
+	if err != nil {
+		t.Fatalf("restore error: %s", err)
+	}
+	_ = resp.Body.Close()
+	if vs := versions(); len(vs) != 1 || vs[0].Size != 3 {
+		t.Fatalf("expected the version to be restored, got %+v", vs)
+	}
+
+	del()
+	pcfg := *cfg
+	pcfg.TrashRetention = -time.Minute
+	if err := server.PurgeTrash(&pcfg); err != nil {
+		t.Fatal(err)
+	}
+	if vs := versions(); len(vs) != 0 {
+		t.Fatalf("expected no versions after purging, got %+v", vs)
+	}
+	if n := used(); n != 0 {
+		t.Fatalf("expected nothing used after purging, got %d", n)
+	}
+	// Recounting from the file store finds nothing left behind either.
+	if err := server.ReconcileStorage(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := used(); n != 0 {
+		t.Fatalf("expected nothing stored after purging, got %d", n)
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+	"goji.io/pat"
+)
+
+// UploadChunkSize is the size of the chunks of a chunked upload.
+var UploadChunkSize int64 = 16 * 1024 * 1024 // 16MB
+
+// uploadsID is the FileStore namespace chunks are staged in until an upload
+// is committed. It can't clash with a Charm ID.
+const uploadsID = ".uploads"
+
+// uploadDir returns the directory an upload session's chunks are staged in.
+func uploadDir(charmID string, id string) string {
+	return filepath.Join(charmID, id)
+}
+
+// numChunks returns the number of chunks of the upload session. An empty
+// file is uploaded as a single empty chunk.
+func numChunks(us *charm.UploadSession) int {
+	if us.Size == 0 {
+		return 1
+	}
+	return int((us.Size + us.ChunkSize - 1) / us.ChunkSize)
+}
+
+// chunkSize returns the size of chunk n of the upload session.
+func chunkSize(us *charm.UploadSession, n int) int64 {
+	if n < numChunks(us)-1 {
+		return us.ChunkSize
+	}
+	return us.Size - int64(numChunks(us)-1)*us.ChunkSize
+}
+
+// deleteUpload deletes an upload session along with its staged chunks.
+func deleteUpload(cfg *Config, us *charm.UploadSession) error {
+	err := cfg.FileStore.Delete(uploadsID, uploadDir(us.CharmID, us.ID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return cfg.DB.DeleteUploadSession(us.ID)
+}
+
+// expireUploads deletes the upload sessions older than UploadExpiry.
+func expireUploads(cfg *Config) error {
+	uss, err := cfg.DB.GetUploadSessionsBefore(time.Now().Add(-cfg.UploadExpiry))
+	if err != nil {
+		return err
+	}
+	for _, us := range uss {
+		if err := deleteUpload(cfg, us); err != nil {
+			return err
+		}
+	}
+	if len(uss) > 0 {
+		log.Debug("Deleted expired uploads", "count", len(uss))
+	}
+	return nil
+}
+
+// expireUploads runs expireUploads, logging any error.
+func (srv *Server) expireUploads() {
+	if err := expireUploads(srv.Config); err != nil {
+		log.Error("cannot expire uploads", "err", err)
+	}
+}
+
+// chunkReader reads the staged chunks of an upload session in order, opening
+// one chunk at a time.
+type chunkReader struct {
+	fstore storage.FileStore
+	us     *charm.UploadSession
+	n      int
+	cur    io.ReadCloser
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if cr.cur == nil {
+			if cr.n == numChunks(cr.us) {
+				return 0, io.EOF
+			}
+			f, err := cr.fstore.Get(uploadsID, filepath.Join(uploadDir(cr.us.CharmID, cr.us.ID), strconv.Itoa(cr.n)))
+			if err != nil {
+				return 0, fmt.Errorf("cannot open chunk %d: %w", cr.n, err)
+			}
+			cr.cur = f
+			cr.n++
+		}
+		n, err := cr.cur.Read(p)
+		if err == io.EOF {
+			_ = cr.cur.Close()
+			cr.cur = nil
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (cr *chunkReader) Close() error {
+	if cr.cur == nil {
+		return nil
+	}
+	return cr.cur.Close()
+}
+
+// uploadSession returns the upload session from the request if it belongs to
+// the user, otherwise it renders an error and returns nil.
+func (s *HTTPServer) uploadSession(w http.ResponseWriter, r *http.Request, u *charm.User) *charm.UploadSession {
+	us, err := s.db.GetUploadSession(pat.Param(r, "id"))
+	if err == nil && us.CharmID != u.CharmID {
+		err = charm.ErrMissingUpload
+	}
+	if errors.Is(err, charm.ErrMissingUpload) {
+		s.renderCustomError(w, err.Error(), http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		log.Error("cannot get upload session", "err", err)
+		s.renderError(w)
+		return nil
+	}
+	return us
+}
+
+func (s *HTTPServer) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	us := &charm.UploadSession{}
+	if err := json.NewDecoder(r.Body).Decode(us); err != nil {
+		s.renderCustomError(w, "cannot decode upload session", http.StatusBadRequest)
+		return
+	}
+	us.Path = filepath.Clean("/" + us.Path)
+	if us.Path == "/" || us.Size < 0 || us.Mode.IsDir() {
+		s.renderCustomError(w, "invalid upload session", http.StatusBadRequest)
+		return
+	}
+	// Open sessions hold on to their share of the quota until they're
+	// committed or expire, so staged chunks can't go over it.
+	reserved, err := s.db.GetUploadSessionsSize(u)
+	if err != nil {
+		log.Error("cannot get upload sessions size", "err", err)
+		s.renderError(w)
+		return
+	}
+	if _, ok := s.checkQuota(w, u, us.Path, us.Size+reserved, us.Mode); !ok {
+		return
+	}
+	us.ID = uuid.New().String()
+	us.ChunkSize = UploadChunkSize
+	us.CharmID = u.CharmID
+	if err := s.db.CreateUploadSession(u, us); err != nil {
+		log.Error("cannot create upload session", "err", err)
+		s.renderError(w)
+		return
+	}
+	us.Chunks = []int{}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(us)
+}
+
+func (s *HTTPServer) handleGetUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	us := s.uploadSession(w, r, u)
+	if us == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(us)
+}
+
+func (s *HTTPServer) handlePutUploadChunk(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	us := s.uploadSession(w, r, u)
+	if us == nil {
+		return
+	}
+	n, err := strconv.Atoi(pat.Param(r, "n"))
+	if err != nil || n < 0 || n >= numChunks(us) {
+		s.renderCustomError(w, "invalid chunk number", http.StatusBadRequest)
+		return
+	}
+	size := chunkSize(us, n)
+	if r.ContentLength >= 0 && r.ContentLength != size {
+		s.renderCustomError(w, fmt.Sprintf("chunk %d must be %d bytes", n, size), http.StatusBadRequest)
+		return
+	}
+	cp := filepath.Join(uploadDir(us.CharmID, us.ID), strconv.Itoa(n))
+	cr := &countingReader{r: io.LimitReader(r.Body, size+1)}
+	if err := s.cfg.FileStore.Put(uploadsID, cp, cr, 0o600, time.Time{}); err != nil {
+		log.Error("cannot store upload chunk", "err", err)
+		s.renderError(w)
+		return
+	}
+	if cr.n != size {
+		_ = s.cfg.FileStore.Delete(uploadsID, cp)
+		s.renderCustomError(w, fmt.Sprintf("chunk %d must be %d bytes", n, size), http.StatusBadRequest)
+		return
+	}
+	if err := s.db.AddUploadChunk(us.ID, n, size); err != nil {
+		log.Error("cannot record upload chunk", "err", err)
+		s.renderError(w)
+		return
+	}
+}
+
+func (s *HTTPServer) handleCommitUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	us := s.uploadSession(w, r, u)
+	if us == nil {
+		return
+	}
+	if len(us.Chunks) != numChunks(us) {
+		s.renderCustomError(w, fmt.Sprintf("upload is missing chunks, received %d of %d", len(us.Chunks), numChunks(us)), http.StatusConflict)
+		return
+	}
+	// The chunks are streamed to the file store, which stores large files
+	// in parts, so they aren't buffered in full.
+	cr := &chunkReader{fstore: s.cfg.FileStore, us: us}
+	defer cr.Close() // nolint:errcheck
+	if !s.putFile(w, r, u, us.Path, cr, us.Size, us.Mode) {
+		return
+	}
+	if err := deleteUpload(s.cfg, us); err != nil {
+		log.Error("cannot delete upload session", "err", err)
+	}
+	s.cfg.Stats.FSFileWritten(u.CharmID, us.Size)
+}
+
+func (s *HTTPServer) handleDeleteUpload(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	us := s.uploadSession(w, r, u)
+	if us == nil {
+		return
+	}
+	if err := deleteUpload(s.cfg, us); err != nil {
+		log.Error("cannot delete upload session", "err", err)
+		s.renderError(w)
+		return
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
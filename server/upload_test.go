@@ -0,0 +1,139 @@
+package server_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/charmbracelet/charm/client"
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/server/db/dbtest"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func putChunk(cl *client.Client, id string, n int, data []byte) (*http.Response, error) {
+	headers := http.Header{"Content-Length": {strconv.Itoa(len(data))}}
+	return cl.AuthedRequest("PUT", fmt.Sprintf("/v1/uploads/%s/%d", id, n), headers, bytes.NewReader(data))
+}
+
+func TestChunkedUpload(t *testing.T) {
+	chunkSize := server.UploadChunkSize
+	t.Cleanup(func() { server.UploadChunkSize = chunkSize })
+	server.UploadChunkSize = 4
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+
+	data := []byte("hello world")
+	us := &charm.UploadSession{}
+	err := cl.AuthedJSONRequest("POST", "/v1/uploads", &charm.UploadSession{Path: "big", Mode: 0o644, Size: int64(len(data))}, us)
+	if err != nil {
+		t.Fatalf("create upload error: %s", err)
+	}
+	if us.ID == "" || us.ChunkSize != 4 || us.Path != "/big" {
+		t.Fatalf("unexpected upload session: %+v", us)
+	}
+
+	resp, err := putChunk(cl, us.ID, 0, []byte("hel"))
+	if err == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a short chunk to be rejected, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// Chunks can arrive in any order.
+	for _, n := range []int{2, 0} {
+		end := (n + 1) * 4
+		if end > len(data) {
+			end = len(data)
+		}
+		resp, err := putChunk(cl, us.ID, n, data[n*4:end])
+		if err != nil {
+			t.Fatalf("put chunk %d error: %s", n, err)
+		}
+		_ = resp.Body.Close()
+	}
+	resp, err = cl.AuthedRequest("POST", "/v1/uploads/"+us.ID+"/commit", nil, nil)
+	if err == nil || resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected commit with a missing chunk to fail, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// Resuming starts from the chunks the server has.
+	if err := cl.AuthedJSONRequest("GET", "/v1/uploads/"+us.ID, nil, us); err != nil {
+		t.Fatalf("get upload error: %s", err)
+	}
+	if len(us.Chunks) != 2 || us.Chunks[0] != 0 || us.Chunks[1] != 2 {
+		t.Fatalf("expected chunks 0 and 2, got %v", us.Chunks)
+	}
+	resp, err = putChunk(cl, us.ID, 1, data[4:8])
+	if err != nil {
+		t.Fatalf("put chunk 1 error: %s", err)
+	}
+	_ = resp.Body.Close()
+	resp, err = cl.AuthedRequest("POST", "/v1/uploads/"+us.ID+"/commit", nil, nil)
+	if err != nil {
+		t.Fatalf("commit error: %s", err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = cl.AuthedRawRequest("GET", "/v1/fs/big")
+	if err != nil {
+		t.Fatalf("get file error: %s", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !bytes.Equal(b, data) {
+		t.Fatalf("expected %q, got %q", data, b)
+	}
+	u, err := cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != int64(len(data)) {
+		t.Fatalf("expected %d bytes used, got %d", len(data), u.Used)
+	}
+	if _, err := cfg.DB.GetUploadSession(us.ID); err != charm.ErrMissingUpload {
+		t.Fatalf("expected the upload session to be deleted, got %v", err)
+	}
+
+	// Upload sessions of other users can't be seen.
+	other := dbtest.NewUser(t, cfg.DB)
+	ous := &charm.UploadSession{ID: "other", Path: "/other", Mode: 0o644, Size: 1, ChunkSize: 4}
+	if err := cfg.DB.CreateUploadSession(other, ous); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = putChunk(cl, ous.ID, 0, []byte("x"))
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected another user's upload to be not found, got %v", err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestUploadQuota(t *testing.T) {
+	t.Setenv("CHARM_SERVER_USER_MAX_STORAGE", "10")
+	cl := testserver.SetupTestServer(t)
+
+	create := func(name string, size int64) (*charm.UploadSession, error) {
+		us := &charm.UploadSession{}
+		err := cl.AuthedJSONRequest("POST", "/v1/uploads", &charm.UploadSession{Path: name, Mode: 0o644, Size: size}, us)
+		return us, err
+	}
+	us, err := create("a", 8)
+	if err != nil {
+		t.Fatalf("create upload error: %s", err)
+	}
+	// The first session holds on to its share of the quota.
+	if _, err := create("b", 8); err == nil {
+		t.Fatal("expected open upload sessions to count toward the quota")
+	}
+	resp, err := cl.AuthedRequest("DELETE", "/v1/uploads/"+us.ID, nil, nil)
+	if err != nil {
+		t.Fatalf("delete upload error: %s", err)
+	}
+	_ = resp.Body.Close()
+	if _, err := create("b", 8); err != nil {
+		t.Fatalf("create upload error after deleting the other session: %s", err)
+	}
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+// storageQuota returns the storage limit in bytes for the user, falling back
+// to the server default. Zero means there's no limit.
+func (cfg *Config) storageQuota(u *charm.User) int64 {
+	if u.StorageQuota != nil {
+		return *u.StorageQuota
+	}
+	return cfg.UserMaxStorage
+}
+
+// storageUsed returns the number of bytes the user stores. It uses the
+// counter cached in the database and only walks the user's files when the
+// counter is unknown.
+func (cfg *Config) storageUsed(u *charm.User) (int64, error) {
+	used, err := cfg.DB.GetStorageUsed(u)
+	if err != nil {
+		return 0, err
+	}
+	if used != nil {
+		return *used, nil
+	}
+	n, err := cfg.storageSize(u.CharmID)
+	if err != nil {
+		return 0, err
+	}
+	if err := cfg.DB.SetStorageUsed(u, &n); err != nil {
+		log.Error("cannot cache storage usage", "id", u.CharmID, "err", err)
+	}
+	return n, nil
+}
+
+// fileSize returns the size of the file or directory at path, or zero if it
+// doesn't exist. Previous versions of the files count toward the size.
+func (cfg *Config) fileSize(charmID string, path string) (int64, error) {
+	var size int64
+	fi, err := cfg.FileStore.Stat(charmID, path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	if err == nil {
+		size = fi.Size()
+	}
+	vs, err := storage.VersionsSize(cfg.FileStore, charmID, path)
+	if err != nil {
+		return 0, err
+	}
+	return size + vs, nil
+}
+
+// storageSize returns the number of bytes stored for the user, counting their
+// files, file versions and trash.
+func (cfg *Config) storageSize(charmID string) (int64, error) {
+	n, err := cfg.fileSize(charmID, "")
+	if err != nil {
+		return 0, err
+	}
+	fi, err := cfg.FileStore.Stat(trashID, charmID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return n, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return n + fi.Size(), nil
+}
+
+// addStorageUsed adjusts the user's cached storage usage by delta bytes.
+func (cfg *Config) addStorageUsed(u *charm.User, delta int64) {
+	if delta == 0 {
+		return
+	}
+	if err := cfg.DB.AddStorageUsed(u, delta); err != nil {
+		log.Error("cannot update storage usage", "id", u.CharmID, "err", err)
+		cfg.resetStorageUsed(u)
+	}
+}
+
+// resetStorageUsed marks the user's cached storage usage as unknown, so it's
+// recomputed the next time it's needed. It's used when a file operation
+// failed halfway and the change in size isn't known.
+func (cfg *Config) resetStorageUsed(u *charm.User) {
+	if err := cfg.DB.SetStorageUsed(u, nil); err != nil {
+		log.Error("cannot reset storage usage", "id", u.CharmID, "err", err)
+	}
+}
+
+// ReconcileStorage recomputes the cached storage usage of every user from the
+// file store, correcting any drift in the counters. Expired file versions are
+// deleted first.
+func ReconcileStorage(cfg *Config) error {
+	for offset := 0; ; offset += resultsPerPage {
+		us, err := cfg.DB.ListUsers("", offset)
+		if err != nil {
+			return err
+		}
+		for _, u := range us {
+			if vfs, ok := cfg.FileStore.(*storage.VersionedFileStore); ok {
+				if err := vfs.PruneExpired(u.CharmID); err != nil {
+					return fmt.Errorf("cannot prune versions of %s: %w", u.CharmID, err)
+				}
+			}
+			n, err := cfg.storageSize(u.CharmID)
+			if err != nil {
+				return fmt.Errorf("cannot stat storage of %s: %w", u.CharmID, err)
+			}
+			if err := cfg.DB.SetStorageUsed(u, &n); err != nil {
+				return err
+			}
+		}
+		if len(us) < resultsPerPage {
+			return nil
+		}
+	}
+}
+
+// reconcileStorage runs ReconcileStorage, logging any error.
+func (srv *Server) reconcileStorage() {
+	start := time.Now()
+	if err := ReconcileStorage(srv.Config); err != nil {
+		log.Error("cannot reconcile storage usage", "err", err)
+		return
+	}
+	log.Debug("Reconciled storage usage", "took", time.Since(start))
+}
+
+// every calls fn every interval until the server is stopped. A zero interval
+// disables it.
+func (srv *Server) every(interval time.Duration, fn func()) {
+	if interval <= 0 {
+		return
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			fn()
+		case <-srv.done:
+			return
+		}
+	}
+}
+
+func (srv *Server) stop() {
+	srv.closeOnce.Do(func() { close(srv.done) })
+}
+
+func (s *HTTPServer) handleGetUsage(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	used, err := s.cfg.storageUsed(u)
+	if err != nil {
+		log.Error("cannot stat user storage", "id", u.CharmID, "err", err)
+		s.renderError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(charm.Usage{
+		Used:  used,
+		Quota: s.cfg.storageQuota(u),
+	})
+	s.cfg.Stats.GetUsage()
+}
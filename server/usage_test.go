@@ -0,0 +1,173 @@
+package server_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/charmbracelet/charm/client"
+	"github.com/charmbracelet/charm/server"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func postFile(t *testing.T, cl *client.Client, name string, data []byte, hs ...http.Header) (*http.Response, error) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	fw, err := w.CreateFormFile("data", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write(data)
+	_ = w.Close()
+	headers := http.Header{"Content-Type": {w.FormDataContentType()}}
+	for _, h := range hs {
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	return cl.AuthedRequest("POST", "/v1/fs/"+name+"?mode=420", headers, buf)
+}
+
+func TestStorageQuota(t *testing.T) {
+	t.Setenv("CHARM_SERVER_USER_MAX_STORAGE", "10")
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+
+	resp, err := postFile(t, cl, "small", []byte("hello"))
+	if err != nil {
+		t.Fatalf("post file error: %s", err)
+	}
+	_ = resp.Body.Close()
+	u, err := cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != 5 || u.Quota != 10 {
+		t.Fatalf("expected 5 of 10 bytes used, got %+v", u)
+	}
+
+	resp, err = postFile(t, cl, "big", []byte("hello world"))
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected the default quota to be enforced")
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v", err)
+	}
+
+	user, err := cfg.DB.GetUserWithID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := int64(500)
+	if err := cfg.DB.SetUserStorageQuota(user, &q); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = postFile(t, cl, "big", []byte("hello world"))
+	if err != nil {
+		t.Fatalf("post file error with a raised quota: %s", err)
+	}
+	_ = resp.Body.Close()
+	u, err = cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != 16 || u.Quota != q {
+		t.Fatalf("expected 16 of %d bytes used, got %+v", q, u)
+	}
+}
+
+func TestStorageUsedCounter(t *testing.T) {
+	// Deleted files would count toward usage while they're in the trash.
+	t.Setenv("CHARM_SERVER_TRASH_RETENTION", "0")
+	cl, cfg := testserver.SetupTestServerWithConfig(t)
+	id, err := cl.ID()
+	if err != nil {
+		t.Fatalf("id error: %s", err)
+	}
+	user, err := cfg.DB.GetUserWithID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != 0 {
+		t.Fatalf("expected no usage, got %d", u.Used)
+	}
+
+	// Write two files, overwrite one of them and delete the other, then move
+	// a third one over the first.
+	for _, f := range []struct{ name, data string }{
+		{"a", "hello"},
+		{"b", "hello world"},
+		{"a", "hi"},
+		{"m", "moved"},
+	} {
+		resp, err := postFile(t, cl, f.name, []byte(f.data))
+		if err != nil {
+			t.Fatalf("post file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+	resp, err := cl.AuthedRequest("DELETE", "/v1/fs/b", nil, nil)
+	if err != nil {
+		t.Fatalf("delete file error: %s", err)
+	}
+	_ = resp.Body.Close()
+	headers := http.Header{"Destination": []string{"a"}, "Overwrite": []string{"T"}}
+	resp, err = cl.AuthedRequest("MOVE", "/v1/fs/m", headers, nil)
+	if err != nil {
+		t.Fatalf("move file error: %s", err)
+	}
+	_ = resp.Body.Close()
+
+	used, err := cfg.DB.GetStorageUsed(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used == nil || *used != 5 {
+		t.Fatalf("expected the counter to track writes, got %v", used)
+	}
+
+	// Quota checks use the counter.
+	drift := int64(1000)
+	if err := cfg.DB.SetStorageUsed(user, &drift); err != nil {
+		t.Fatal(err)
+	}
+	q := int64(1000)
+	if err := cfg.DB.SetUserStorageQuota(user, &q); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = postFile(t, cl, "c", []byte("hello"))
+	if err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected the cached usage to be used for the quota check")
+	}
+
+	if err := server.ReconcileStorage(cfg); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := cfg.FileStore.Stat(id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	used, err = cfg.DB.GetStorageUsed(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used == nil || *used != fi.Size() {
+		t.Fatalf("expected reconciled usage %d, got %v", fi.Size(), used)
+	}
+	resp, err = postFile(t, cl, "c", []byte("hello"))
+	if err != nil {
+		t.Fatalf("post file error after reconciling: %s", err)
+	}
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"goji.io/pattern"
+
+	"github.com/charmbracelet/charm/server/storage"
+)
+
+// handleGetVersions lists the previous versions of a file, or returns the
+// content of one of them when the id query parameter is set.
+func (s *HTTPServer) handleGetVersions(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	path := filepath.Clean(pattern.Path(r.Context()))
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		vs, err := storage.Versions(s.cfg.FileStore, u.CharmID, path)
+		if err != nil {
+			log.Error("cannot list file versions", "err", err)
+			s.renderError(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(vs)
+		return
+	}
+	f, fi, ok := s.openVersion(w, u.CharmID, path, id)
+	if !ok {
+		return
+	}
+	defer f.Close() // nolint:errcheck
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-File-Mode", fmt.Sprintf("%d", fi.Mode()))
+	w.Header().Set("Last-Modified", fi.ModTime().UTC().Format(http.TimeFormat))
+	s.cfg.Stats.FSFileRead(u.CharmID, fi.Size())
+	if _, err := io.Copy(w, f); err != nil {
+		log.Error("cannot copy file version", "err", err)
+	}
+}
+
+// handleRestoreVersion replaces a file with one of its previous versions. The
+// replaced file is kept as a version itself.
+func (s *HTTPServer) handleRestoreVersion(w http.ResponseWriter, r *http.Request) {
+	u := s.charmUserFromRequest(w, r)
+	path := filepath.Clean(pattern.Path(r.Context()))
+	f, fi, ok := s.openVersion(w, u.CharmID, path, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+	defer f.Close() // nolint:errcheck
+	if s.putFile(w, r, u, path, f, fi.Size(), fi.Mode()) {
+		s.cfg.Stats.FSFileWritten(u.CharmID, fi.Size())
+	}
+}
+
+// openVersion opens a version of a file, rendering an error and returning
+// false if it can't be opened.
+func (s *HTTPServer) openVersion(w http.ResponseWriter, charmID string, path string, id string) (fs.File, fs.FileInfo, bool) {
+	f, err := storage.GetVersion(s.cfg.FileStore, charmID, path, id)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.renderCustomError(w, "file version not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		log.Error("cannot get file version", "err", err)
+		s.renderError(w)
+		return nil, nil, false
+	}
+	fi, err := f.Stat()
+	if err == nil && fi.IsDir() {
+		err = fs.ErrInvalid
+	}
+	if err != nil {
+		f.Close() // nolint:errcheck
+		log.Error("cannot get file version info", "err", err)
+		s.renderError(w)
+		return nil, nil, false
+	}
+	return f, fi, true
+}
@@ -0,0 +1,76 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	charm "github.com/charmbracelet/charm/proto"
+	"github.com/charmbracelet/charm/testserver"
+)
+
+func TestFileVersions(t *testing.T) {
+	t.Setenv("CHARM_SERVER_FILE_VERSIONS", "2")
+	cl := testserver.SetupTestServer(t)
+	for _, content := range []string{"one", "two", "three"} {
+		resp, err := postFile(t, cl, "file", []byte(content))
+		if err != nil {
+			t.Fatalf("post file error: %s", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	var vs []charm.FileVersion
+	if err := cl.AuthedJSONRequest("GET", "/v1/versions/file", nil, &vs); err != nil {
+		t.Fatalf("list versions error: %s", err)
+	}
+	if len(vs) != 2 || vs[0].Size != 3 || vs[1].Size != 3 {
+		t.Fatalf("expected versions two and one, got %+v", vs)
+	}
+	u, err := cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != int64(len("three")+len("two")+len("one")) {
+		t.Fatalf("expected versions to count toward usage, got %d bytes used", u.Used)
+	}
+
+	resp, err := cl.AuthedRawRequest("GET", "/v1/versions/file?id="+vs[1].ID)
+	if err != nil {
+		t.Fatalf("get version error: %s", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "one" {
+		t.Fatalf("expected version %q, got %q", "one", b)
+	}
+
+	resp, err = cl.AuthedRequest("POST", "/v1/versions/file?id="+vs[1].ID, nil, nil)
+	if err != nil {
+		t.Fatalf("restore version error: %s", err)
+	}
+	_ = resp.Body.Close()
+	resp, err = cl.AuthedRawRequest("GET", "/v1/fs/file")
+	if err != nil {
+		t.Fatalf("get file error: %s", err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(b) != "one" {
+		t.Fatalf("expected the restored file to be %q, got %q", "one", b)
+	}
+	// Restoring keeps the replaced file and prunes the oldest version.
+	u, err = cl.Usage()
+	if err != nil {
+		t.Fatalf("usage error: %s", err)
+	}
+	if u.Used != int64(len("one")+len("three")+len("two")) {
+		t.Fatalf("expected %d bytes used after restoring, got %d", len("one")+len("three")+len("two"), u.Used)
+	}
+
+	resp, err = cl.AuthedRawRequest("GET", "/v1/versions/file?id=nope")
+	if err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a missing version to be not found, got %v", err)
+	}
+	_ = resp.Body.Close()
+}
@@ -23,6 +23,14 @@ import (
 // to setting a bunch of environment variables.
 func SetupTestServer(tb testing.TB) *client.Client {
 	tb.Helper()
+	cl, _ := SetupTestServerWithConfig(tb)
+	return cl
+}
+
+// SetupTestServerWithConfig is like SetupTestServer but also returns the
+// server Config, giving tests access to the server's DB and FileStore.
+func SetupTestServerWithConfig(tb testing.TB) (*client.Client, *server.Config) {
+	tb.Helper()
 
 	td := tb.TempDir()
 	sp := filepath.Join(td, ".ssh")
@@ -84,7 +92,7 @@ func SetupTestServer(tb testing.TB) *client.Client {
 	if err != nil {
 		tb.Fatalf("new client error: %s", err)
 	}
-	return cl
+	return cl, s.Config
 }
 
 // Fetch the given URL with N retries.
@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
+	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/dustin/go-humanize"
 )
 
 // State is a general UI state used to help style components.
@@ -180,3 +182,27 @@ func styledButton(str string, underlined, focused bool) string {
 	}
 	return st.Render(str)
 }
+
+// MergeReportView renders a short summary of the data that was moved when two
+// accounts were merged during linking.
+func MergeReportView(r *charm.MergeReport) string {
+	if r == nil {
+		return ""
+	}
+	files := "files"
+	if r.Files == 1 {
+		files = "file"
+	}
+	seqs := "sequences"
+	if r.Seqs == 1 {
+		seqs = "sequence"
+	}
+	s := fmt.Sprintf("Moved %d %s (%s) and %d %s from the other account.",
+		r.Files, files, humanize.Bytes(uint64(r.Bytes)), r.Seqs, seqs)
+	if n := len(r.Conflicts); n == 1 {
+		s += " 1 conflict was resolved by keeping the newest copy."
+	} else if n > 1 {
+		s += fmt.Sprintf(" %d conflicts were resolved by keeping the newest copies.", n)
+	}
+	return s
+}
@@ -0,0 +1,218 @@
+// Package deleteaccount implements the confirmation UI for permanently
+// deleting a Charm account.
+package deleteaccount
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/charm/client"
+	"github.com/charmbracelet/charm/ui/charmclient"
+	"github.com/charmbracelet/charm/ui/common"
+	"github.com/muesli/reflow/indent"
+)
+
+type state int
+
+const (
+	stateInitCharmClient state = iota
+	stateReady
+	stateConfirming
+	stateDeleting
+	stateDeleted
+	stateCancelled
+)
+
+type (
+	// DeletedMsg is sent when the account has been deleted.
+	DeletedMsg struct{}
+	errMsg     struct{ err error }
+)
+
+// NewProgram creates a new standalone Tea program.
+func NewProgram(cfg *client.Config) *tea.Program {
+	m := NewModel(cfg)
+	m.standalone = true
+	m.state = stateInitCharmClient
+	return tea.NewProgram(m)
+}
+
+// Model is the Tea state model for this user interface.
+type Model struct {
+	Deleted bool // true when the account has been deleted
+	Exit    bool // true when it's time to exit this view
+	Quit    bool // true when the user wants to quit the whole program
+
+	cc         *client.Client
+	cfg        *client.Config
+	styles     common.Styles
+	state      state
+	standalone bool
+	err        error
+	spinner    spinner.Model
+}
+
+// NewModel creates a new model with defaults.
+func NewModel(cfg *client.Config) Model {
+	return Model{
+		cfg:     cfg,
+		styles:  common.DefaultStyles(),
+		state:   stateReady,
+		spinner: common.NewSpinner(),
+	}
+}
+
+// SetCharmClient sets a pointer to the charm client on the model. The Charm
+// Client is necessary for all network-related operations.
+func (m *Model) SetCharmClient(cc *client.Client) {
+	if cc == nil {
+		panic("charm client is nil")
+	}
+	m.cc = cc
+}
+
+// Init is the Tea initialization function.
+func (m Model) Init() tea.Cmd {
+	if m.standalone {
+		return tea.Batch(
+			charmclient.NewClient(m.cfg),
+			m.spinner.Tick,
+		)
+	}
+	return nil
+}
+
+// Update is the tea update function which handles incoming messages.
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m.cancel(true)
+		}
+		switch m.state {
+		case stateReady:
+			if msg.String() == "y" {
+				// This can't be undone. Double confirm.
+				m.state = stateConfirming
+				return m, nil
+			}
+			return m.cancel(msg.String() == "q")
+		case stateConfirming:
+			if msg.String() == "y" {
+				m.state = stateDeleting
+				return m, tea.Batch(deleteAccount(m.cc), m.spinner.Tick)
+			}
+			return m.cancel(msg.String() == "q")
+		case stateDeleted:
+			m.Exit = true
+			if m.standalone {
+				return m, tea.Quit
+			}
+		}
+
+	case charmclient.ErrMsg:
+		m.err = msg.Err
+		return m, tea.Quit
+
+	case charmclient.SSHAuthErrorMsg:
+		m.err = msg.Err
+		return m, tea.Quit
+
+	case charmclient.NewClientMsg:
+		m.cc = msg
+		m.state = stateReady
+
+	case errMsg:
+		m.err = msg.err
+		m.state = stateReady
+		if m.standalone {
+			return m, tea.Quit
+		}
+
+	case DeletedMsg:
+		m.Deleted = true
+		m.state = stateDeleted
+		if m.standalone {
+			return m, tea.Quit
+		}
+
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		if m.state == stateInitCharmClient || m.state == stateDeleting {
+			m.spinner, cmd = m.spinner.Update(msg)
+		}
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+// cancel leaves the view without deleting anything.
+func (m Model) cancel(quit bool) (tea.Model, tea.Cmd) {
+	m.state = stateCancelled
+	if m.standalone {
+		return m, tea.Quit
+	}
+	if quit {
+		m.Quit = true
+	} else {
+		m.Exit = true
+	}
+	return m, nil
+}
+
+// View renders the current UI into a string.
+func (m Model) View() string {
+	var s string
+
+	switch m.state {
+	case stateInitCharmClient:
+		s = m.spinner.View() + " Initializing..."
+	case stateReady, stateConfirming:
+		s = "Deleting your account removes your linked keys, your encryption keys and all of your files from the Charm Cloud. This can’t be undone.\n\n"
+		s += "Your local keys won’t be touched. If you keep using them, a brand new, empty account will be created.\n"
+		if m.state == stateReady {
+			s += m.promptView("Delete your account?")
+		} else {
+			s += m.promptView("Are you absolutely positive? Everything will be gone for good.")
+		}
+		if m.err != nil {
+			s += "\n\n" + m.styles.Error.Render("Error: ") + m.styles.Subtle.Render(m.err.Error())
+		}
+	case stateDeleting:
+		s = m.spinner.View() + " Deleting account..."
+	case stateDeleted:
+		s = "Your account has been deleted. Bye!"
+		if !m.standalone {
+			s += "\n\n" + common.HelpView("press any key to exit")
+		}
+	case stateCancelled:
+		s = "Ok, we won’t do anything. Bye!"
+	}
+
+	if m.err != nil && m.standalone {
+		s = m.err.Error()
+	}
+
+	if m.standalone {
+		return indent.String(fmt.Sprintf("\n%s\n\n", m.styles.Wrap.Render(s)), 2)
+	}
+	return m.styles.Wrap.Render(s)
+}
+
+func (m Model) promptView(prompt string) string {
+	st := m.styles.Delete.Copy().MarginTop(1).MarginRight(1)
+	return st.Render(prompt) +
+		m.styles.DeleteDim.Render("(y/N)")
+}
+
+// deleteAccount deletes the account via the charm client.
+func deleteAccount(cc *client.Client) tea.Cmd {
+	return func() tea.Msg {
+		if err := cc.DeleteAccount(); err != nil {
+			return errMsg{err}
+		}
+		return DeletedMsg{}
+	}
+}
@@ -7,12 +7,21 @@ import (
 	"github.com/charmbracelet/charm/client"
 	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/charm/ui/common"
+	"github.com/dustin/go-humanize"
 )
 
 // GotBioMsg is sent when we've successfully fetched the user's bio. It
 // contains the user's profile data.
 type GotBioMsg *charm.User
 
+// GotUsageMsg is sent when we've successfully fetched the user's storage
+// usage.
+type GotUsageMsg *charm.Usage
+
+// usageErrMsg is sent when the storage usage couldn't be fetched, for example
+// because the server doesn't support it. It isn't fatal.
+type usageErrMsg struct{}
+
 type errMsg struct {
 	err error
 }
@@ -27,6 +36,7 @@ type Model struct {
 	Quit   bool // signals it's time to exit the whole application
 	Err    error
 	User   *charm.User
+	Usage  *charm.Usage
 	cc     *client.Client
 	styles common.Styles
 }
@@ -54,6 +64,8 @@ func Update(msg tea.Msg, m Model) (Model, tea.Cmd) {
 		}
 	case GotBioMsg:
 		m.User = msg
+	case GotUsageMsg:
+		m.Usage = msg
 	case errMsg:
 		// If there's an error we print the error and exit
 		m.Err = msg
@@ -81,11 +93,23 @@ func (m Model) bioView() string {
 	} else {
 		username = m.styles.Subtle.Render("(none set)")
 	}
-	return common.KeyValueView(
+	kv := []string{
 		"Host", m.cc.Config.Host,
 		"Username", username,
 		"Joined", m.User.CreatedAt.Format("02 Jan 2006"),
-	)
+	}
+	if m.Usage != nil {
+		kv = append(kv, "Storage", m.usageView())
+	}
+	return common.KeyValueView(kv...)
+}
+
+func (m Model) usageView() string {
+	used := humanize.Bytes(uint64(m.Usage.Used))
+	if m.Usage.Quota <= 0 {
+		return used
+	}
+	return used + " of " + humanize.Bytes(uint64(m.Usage.Quota))
 }
 
 // GetBio fetches the authenticated user's bio.
@@ -99,3 +123,15 @@ func GetBio(cc *client.Client) tea.Cmd {
 		return GotBioMsg(user)
 	}
 }
+
+// GetUsage fetches the authenticated user's storage usage.
+func GetUsage(cc *client.Client) tea.Cmd {
+	return func() tea.Msg {
+		u, err := cc.Usage()
+		if err != nil {
+			return usageErrMsg{}
+		}
+
+		return GotUsageMsg(u)
+	}
+}
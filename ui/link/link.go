@@ -153,6 +153,9 @@ func (m model) View() string {
 		s += fmt.Sprintf("Token %s. Waiting for authorization...", m.styles.Keyword.Render("valid"))
 	case linkTokenInvalid:
 		s = fmt.Sprintf("%s token. Goodbye.", m.styles.Keyword.Render("Invalid"))
+		if m.lh.tooMany {
+			s = fmt.Sprintf("%s. Please try again later.", m.styles.Keyword.Render("Too many attempts"))
+		}
 	case linkRequestDenied:
 		s = fmt.Sprintf("Link request %s. Sorry, kid.", m.styles.Keyword.Render("denied"))
 	case linkSuccess:
@@ -160,6 +163,9 @@ func (m model) View() string {
 		if m.alreadyLinked {
 			s += " You already linked this key, btw."
 		}
+		if m.lh.merge != nil {
+			s += "\n\n" + common.MergeReportView(m.lh.merge)
+		}
 	case linkTimeout:
 		s = fmt.Sprintf("Link request %s. Sorry.", m.styles.Keyword.Render("timed out"))
 	case linkErr:
@@ -13,6 +13,8 @@ type linkHandler struct {
 	requestDenied chan struct{}
 	timeout       chan struct{}
 	err           chan error
+	merge         *charm.MergeReport // set if accounts were merged
+	tooMany       bool               // set if there were too many link attempts
 }
 
 func newLinkHandler() *linkHandler {
@@ -38,7 +40,8 @@ func (lh *linkHandler) ValidToken(_ *charm.Link) {
 	lh.validToken <- true
 }
 
-func (lh *linkHandler) InvalidToken(_ *charm.Link) {
+func (lh *linkHandler) InvalidToken(l *charm.Link) {
+	lh.tooMany = l.Status == charm.LinkStatusTooManyAttempts
 	lh.validToken <- false
 }
 
@@ -55,7 +58,8 @@ func (lh *linkHandler) SameUser(_ *charm.Link) {
 	lh.success <- true
 }
 
-func (lh *linkHandler) Success(_ *charm.Link) {
+func (lh *linkHandler) Success(l *charm.Link) {
+	lh.merge = l.Merge
 	lh.success <- false
 }
 
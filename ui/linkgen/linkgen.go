@@ -280,6 +280,9 @@ func (m Model) View() string {
 		if m.alreadyLinked {
 			s += " This key is already linked, btw."
 		}
+		if m.lh.merge != nil {
+			s += "\n\n" + common.MergeReportView(m.lh.merge)
+		}
 		if m.standalone {
 			s += "\n"
 		} else {
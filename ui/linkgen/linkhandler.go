@@ -20,6 +20,7 @@ type linkHandler struct {
 	response chan bool
 	success  chan bool
 	timeout  chan struct{}
+	merge    *charm.MergeReport // set if accounts were merged
 }
 
 func (lh *linkHandler) TokenCreated(l *charm.Link) {
@@ -47,7 +48,8 @@ func (lh *linkHandler) SameUser(_ *charm.Link) {
 	lh.success <- true
 }
 
-func (lh *linkHandler) Success(_ *charm.Link) {
+func (lh *linkHandler) Success(l *charm.Link) {
+	lh.merge = l.Merge
 	lh.success <- false
 }
 
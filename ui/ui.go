@@ -10,6 +10,7 @@ import (
 	charm "github.com/charmbracelet/charm/proto"
 	"github.com/charmbracelet/charm/ui/charmclient"
 	"github.com/charmbracelet/charm/ui/common"
+	"github.com/charmbracelet/charm/ui/deleteaccount"
 	"github.com/charmbracelet/charm/ui/info"
 	"github.com/charmbracelet/charm/ui/keys"
 	"github.com/charmbracelet/charm/ui/linkgen"
@@ -44,6 +45,7 @@ const (
 	statusBrowsingKeys
 	statusSettingUsername
 	statusShowBackupInfo
+	statusDeletingAccount
 	statusQuitting
 	statusError
 )
@@ -59,6 +61,7 @@ func (s status) String() string {
 		"browsing keys",
 		"setting username",
 		"showing backup info",
+		"deleting account",
 		"quitting",
 		"error",
 	}[s]
@@ -73,17 +76,19 @@ const (
 	keysChoice
 	setUsernameChoice
 	backupChoice
+	deleteAccountChoice
 	exitChoice
 	unsetChoice // set when no choice has been made
 )
 
 // menu text corresponding to menu choices. these are presented to the user.
 var menuChoices = map[menuChoice]string{
-	linkChoice:        "Link a machine",
-	keysChoice:        "Manage linked keys",
-	setUsernameChoice: "Set Username",
-	backupChoice:      "Backup",
-	exitChoice:        "Exit",
+	linkChoice:          "Link a machine",
+	keysChoice:          "Manage linked keys",
+	setUsernameChoice:   "Set Username",
+	backupChoice:        "Backup",
+	deleteAccountChoice: "Delete account",
+	exitChoice:          "Exit",
 }
 
 // Model holds the state for this program.
@@ -102,8 +107,10 @@ type model struct {
 	linkgen  linkgen.Model
 	username username.Model
 	keys     keys.Model
+	delete   deleteaccount.Model
 
-	terminalWidth int
+	terminalWidth  int
+	accountDeleted bool
 }
 
 func initialModel(cfg *client.Config) model {
@@ -205,6 +212,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case info.GotBioMsg:
 		m.status = statusReady
 		m.user = m.info.User
+		m.info, cmd = info.Update(msg, m.info)
+		cmds = append(cmds, cmd, info.GetUsage(m.cc))
+
+	case info.GotUsageMsg:
 		m.info, cmd = info.Update(msg, m.info)
 		cmds = append(cmds, cmd)
 
@@ -284,6 +295,27 @@ func updateChilden(msg tea.Msg, m model) (model, tea.Cmd) {
 			return m, tea.Quit
 		}
 
+	// Account deletion
+	case statusDeletingAccount:
+		newModel, newCmd := m.delete.Update(msg)
+		deleteModel, ok := newModel.(deleteaccount.Model)
+		if !ok {
+			panic("could not perform assertion on delete account model")
+		}
+		m.delete = deleteModel
+		cmd = newCmd
+
+		if m.delete.Deleted && (m.delete.Exit || m.delete.Quit) {
+			m.status = statusQuitting
+			m.accountDeleted = true
+			return m, tea.Quit
+		} else if m.delete.Exit {
+			m.status = statusReady
+		} else if m.delete.Quit {
+			m.status = statusQuitting
+			return m, tea.Quit
+		}
+
 	case statusShowBackupInfo:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -321,6 +353,12 @@ func updateChilden(msg tea.Msg, m model) (model, tea.Cmd) {
 		m.status = statusShowBackupInfo
 		m.menuChoice = unsetChoice
 
+	case deleteAccountChoice:
+		m.status = statusDeletingAccount
+		m.menuChoice = unsetChoice
+		m.delete = deleteaccount.NewModel(m.cfg)
+		m.delete.SetCharmClient(m.cc)
+
 	case exitChoice:
 		m.status = statusQuitting
 		cmd = tea.Quit
@@ -353,6 +391,8 @@ func (m model) View() string {
 		s += username.View(m.username)
 	case statusShowBackupInfo:
 		s += m.backupView()
+	case statusDeletingAccount:
+		s += m.delete.View()
 	case statusQuitting:
 		s += m.quitView()
 	case statusError:
@@ -405,6 +445,9 @@ func (m model) quitView() string {
 	if m.err != nil {
 		return fmt.Sprintf("Uh oh, there’s been an error: %s\n", m.err)
 	}
+	if m.accountDeleted {
+		return "Your account has been deleted. Thanks for using Charm!\n"
+	}
 	return "Thanks for using Charm!\n"
 }
 